@@ -0,0 +1,112 @@
+package main
+
+import (
+	"btp-transfer/config"
+	"btp-transfer/ledger"
+	"btp-transfer/logging"
+	"btp-transfer/service"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// principalAnonymous is recorded for requests without the principal header
+const principalAnonymous = "anonymous"
+
+// withActor stores who sent the request in its context, so the ledger can audit what it does.
+// It must run inside logging.RequestID.
+func withActor(next http.Handler, cfg *config.Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := r.Header.Get(cfg.AuditPrincipalHeader)
+		if principal == "" {
+			principal = principalAnonymous
+		}
+		ctx := ledger.WithActor(r.Context(), ledger.Actor{
+			Principal: principal,
+			IP:        clientIP(r, cfg.TrustForwardedFor),
+			UserAgent: r.UserAgent(),
+			RequestID: logging.RequestIDFrom(r.Context()),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// operatorsOnly answers 403 Forbidden to requests not sent by an operator (see service.RequireOperator).
+// It must run inside withActor.
+func operatorsOnly(next http.Handler, api *service.Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := api.RequireOperator(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the address of the client; behind a trusted proxy, the first X-Forwarded-For entry.
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// auditConfiguration records in the audit log that the configuration differs from the last recorded
+// one, so every configuration or limit change shows up there once. Values are not recorded: the log
+// is append-only, a secret slipping into it could never be removed.
+func auditConfiguration(ctx context.Context, pg *ledger.PostgresStore, cfg *config.Config) error {
+	last, err := pg.AuditEvents(ctx, ledger.AuditFilter{Operation: ledger.OpConfigure, Outcome: ledger.OutcomeSuccess}, 0, 1)
+	if err != nil {
+		return err
+	}
+	var previous *ledger.AuditEvent
+	if len(last) == 1 {
+		previous = &last[0]
+	}
+	arguments := configurationChange(cfg, previous)
+	if arguments == nil {
+		return nil
+	}
+	return ledger.RecordAudit(ctx, pg.DB, ledger.OpConfigure, arguments, nil, nil)
+}
+
+// configurationChange returns the arguments of the configure event telling how cfg differs from the
+// previous one, nil if it doesn't: the hash of the configuration, a fingerprint of each setting that
+// holds no secret, and the names of the ones whose fingerprint changed.
+func configurationChange(cfg *config.Config, previous *ledger.AuditEvent) map[string]any {
+	hash := fingerprint(cfg.Redacted())
+	var fingerprints map[string]any
+	if previous != nil {
+		if previous.Arguments["sha256"] == hash {
+			return nil
+		}
+		fingerprints, _ = previous.Arguments["settings"].(map[string]any)
+	}
+
+	settings := map[string]any{}
+	changed := []string{}
+	for name, value := range cfg.Settings() {
+		settings[name] = fingerprint(value)
+		if fingerprints[name] != settings[name] {
+			changed = append(changed, name)
+		}
+	}
+	slices.Sort(changed)
+	return map[string]any{"sha256": hash, "settings": settings, "changed": changed}
+}
+
+func fingerprint(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,32 +1,611 @@
 package config
 
 import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Supported values of Config.Store
+const (
+	StorePostgres = "postgres"
+	StoreMemory   = "memory"
+)
+
+// Supported values of Config.TxMode
+const (
+	TxModeLocking      = "locking"
+	TxModeSerializable = "serializable"
+	TxModeSingle       = "single"
+)
+
+// Supported values of Config.TracingExporter
+const (
+	TracingNone   = "none"
+	TracingStdout = "stdout"
+	TracingOTLP   = "otlp"
+)
+
+// Supported values of Config.APQCache
+const (
+	APQCacheMemory   = "memory"
+	APQCachePostgres = "postgres"
+)
+
+// Supported values of Config.LogFormat
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
 )
 
+// Config is layered: Defaults, then the YAML file (CONFIG_FILE or --config), then environment
+// variables, then command line flags. Every layer only overrides the values it sets.
 type Config struct {
-	DatabaseURL string
-	Port        string
+	DatabaseURL string `yaml:"database_url"`
+	Port        string `yaml:"port"`
+
+	// Store selects the ledger implementation: "postgres" (default) or "memory" (demo mode, no database)
+	Store string `yaml:"store"`
+	// GenesisFile lists the initial wallets, see the seed command
+	GenesisFile string `yaml:"genesis_file"`
+	// MigrateOnStart applies pending schema migrations before serving (PostgreSQL only)
+	MigrateOnStart bool `yaml:"migrate_on_start"`
+
+	// Database connection pool, see database/sql.DB
+	DBMaxOpenConns    int           `yaml:"db_max_open_conns"`
+	DBMaxIdleConns    int           `yaml:"db_max_idle_conns"`
+	DBConnMaxLifetime time.Duration `yaml:"db_conn_max_lifetime"`
+	DBConnMaxIdleTime time.Duration `yaml:"db_conn_max_idle_time"`
+	// DBStatementTimeout makes PostgreSQL cancel statements running longer (0 = no limit)
+	DBStatementTimeout time.Duration `yaml:"db_statement_timeout"`
+	// Waiting for the database at startup
+	DBConnectAttempts   int           `yaml:"db_connect_attempts"`
+	DBConnectRetryDelay time.Duration `yaml:"db_connect_retry_delay"`
+
+	// TxMode selects how PostgreSQL transfers are executed: "locking" (default), "serializable" or "single"
+	TxMode string `yaml:"tx_mode"`
+	// Retry of transactions aborted by serialization failures or deadlocks
+	TxMaxAttempts    int           `yaml:"tx_max_attempts"`
+	TxRetryBaseDelay time.Duration `yaml:"tx_retry_base_delay"`
+	TxRetryMaxDelay  time.Duration `yaml:"tx_retry_max_delay"`
+	// HotWallets maps heavily used wallets to the number of balance slots they are split into
+	HotWallets map[string]int `yaml:"hot_wallets"`
+	// Group commit: queue transfers and apply them in batches (PostgreSQL only)
+	BatchEnabled bool          `yaml:"batch_enabled"`
+	BatchMaxSize int           `yaml:"batch_max_size"`
+	BatchMaxWait time.Duration `yaml:"batch_max_wait"`
+
+	// HTTP server
+	HTTPReadTimeout   time.Duration `yaml:"http_read_timeout"`
+	HTTPWriteTimeout  time.Duration `yaml:"http_write_timeout"`
+	HTTPIdleTimeout   time.Duration `yaml:"http_idle_timeout"`
+	PlaygroundEnabled bool          `yaml:"playground_enabled"`
+	// ShutdownTimeout bounds waiting for in-flight requests and background workers on SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// ShutdownDrainDelay keeps serving while /readyz reports draining, so load balancers can notice
+	ShutdownDrainDelay time.Duration `yaml:"shutdown_drain_delay"`
+	// HealthCheckTimeout bounds the checks of one /readyz request
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout"`
+
+	// AuditPrincipalHeader names the request header with the authenticated caller, recorded in the audit log.
+	// It must be set by a trusted gateway that drops the value sent by clients.
+	AuditPrincipalHeader string `yaml:"audit_principal_header"`
+	// TrustForwardedFor takes the client IP from X-Forwarded-For (first entry), for instances behind a proxy
+	TrustForwardedFor bool `yaml:"trust_forwarded_for"`
+	// TrustPrincipalHeader states that the gateway authenticates callers and sets AuditPrincipalHeader.
+	// Owners change the metadata of their wallets only with it, updateWallet is refused otherwise.
+	TrustPrincipalHeader bool `yaml:"trust_principal_header"`
+	// OperatorPrincipals may use the operator queries and routes: auditEvents, verifyLedger and /export.
+	// Needs TrustPrincipalHeader.
+	OperatorPrincipals []string `yaml:"operator_principals"`
+
+	// VerifyInterval runs the ledger verification in the background this often, 0 (default) disables it
+	VerifyInterval time.Duration `yaml:"verify_interval"`
+
+	// LogLevel is the minimum level logged: "debug", "info" (default), "warn" or "error"
+	LogLevel string `yaml:"log_level"`
+	// LogFormat is "json" (default, one object per line) or "text" (key=value)
+	LogFormat string `yaml:"log_format"`
+
+	// TracingExporter sends OpenTelemetry spans nowhere ("none", default), to stdout or to an OTLP/HTTP collector
+	TracingExporter string `yaml:"tracing_exporter"`
+	// TracingEndpoint is the URL of the OTLP/HTTP collector, e.g. http://localhost:4318
+	TracingEndpoint string `yaml:"tracing_endpoint"`
+
+	// EthChainID enables the Ethereum JSON-RPC facade under /rpc on this chain ID (PostgreSQL only), 0 (default) disables it
+	EthChainID int `yaml:"eth_chain_id"`
+	// EthTokenAddress is the contract address the ledger poses as, transactions must be sent to it
+	EthTokenAddress string `yaml:"eth_token_address"`
+
+	// GraphQL request limits
+	MaxQueryDepth      int   `yaml:"graphql_max_depth"`
+	MaxQueryComplexity int   `yaml:"graphql_max_complexity"`
+	MaxRequestBytes    int64 `yaml:"max_request_bytes"`
+
+	// APQCache holds automatic persisted queries: "memory" (default, per instance) or "postgres" (shared by the instances)
+	APQCache string `yaml:"apq_cache"`
+	// APQCacheSize is the number of operations each instance keeps in memory
+	APQCacheSize int `yaml:"apq_cache_size"`
+	// APQCacheTTL expires operations stored in PostgreSQL this long after their registration, 0 keeps them forever
+	APQCacheTTL time.Duration `yaml:"apq_cache_ttl"`
+	// PersistedQueriesFile lists operations registered ahead of time (hash to query, or an Apollo manifest)
+	PersistedQueriesFile string `yaml:"persisted_queries_file"`
+	// PersistedQueriesOnly only accepts the operations of PersistedQueriesFile (allow-list mode)
+	PersistedQueriesOnly bool `yaml:"persisted_queries_only"`
+	// GraphQLGetMaxAge lets CDNs cache successful GET queries of public fields for this long, 0 (default) disables it
+	GraphQLGetMaxAge time.Duration `yaml:"graphql_get_max_age"`
 }
 
-// Load function reads environment variables and validates them.
+// Defaults returns the configuration used when nothing is set.
+func Defaults() *Config {
+	return &Config{
+		Port:           "8080",
+		Store:          StorePostgres,
+		MigrateOnStart: true,
+
+		DBMaxOpenConns:      25,
+		DBMaxIdleConns:      25,
+		DBConnMaxLifetime:   30 * time.Minute,
+		DBConnMaxIdleTime:   5 * time.Minute,
+		DBStatementTimeout:  30 * time.Second,
+		DBConnectAttempts:   10,
+		DBConnectRetryDelay: 2 * time.Second,
+
+		TxMode:           TxModeLocking,
+		TxMaxAttempts:    5,
+		TxRetryBaseDelay: 5 * time.Millisecond,
+		TxRetryMaxDelay:  200 * time.Millisecond,
+		HotWallets:       map[string]int{},
+		BatchMaxSize:     100,
+		BatchMaxWait:     5 * time.Millisecond,
+
+		HTTPReadTimeout:    10 * time.Second,
+		HTTPWriteTimeout:   30 * time.Second,
+		HTTPIdleTimeout:    2 * time.Minute,
+		PlaygroundEnabled:  true,
+		ShutdownTimeout:    30 * time.Second,
+		HealthCheckTimeout: 2 * time.Second,
+
+		AuditPrincipalHeader: "X-Principal",
+
+		LogLevel:  "info",
+		LogFormat: LogFormatJSON,
+
+		TracingExporter: TracingNone,
+		TracingEndpoint: "http://localhost:4318",
+
+		EthTokenAddress: "0x00000000000000000000000000000000000000b7",
+
+		MaxQueryDepth:      10,
+		MaxQueryComplexity: 200,
+		MaxRequestBytes:    1 << 20,
+
+		APQCache:     APQCacheMemory,
+		APQCacheSize: 1000,
+		APQCacheTTL:  7 * 24 * time.Hour,
+	}
+}
+
+// Load function reads the configuration file (CONFIG_FILE, optional) and environment variables and validates them.
 func Load() (*Config, error) {
-	// Database URL
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		// Jeśli brak zmiennej - zwracamy błąd. Aplikacja nie może bez tego działać.
-		return nil, fmt.Errorf("required environment variable DATABASE_URL is missing")
+	cfg, err := FromEnv(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// FromEnv builds the configuration from defaults, the YAML file at path (if any) and environment variables,
+// without checking the result. Malformed values are reported right away; missing or unsupported ones are
+// left for Validate, so that command line flags can still fill them in.
+func FromEnv(path string) (*Config, error) {
+	cfg := Defaults()
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile overrides the values set in a YAML file. Unknown keys are errors, so typos don't go unnoticed.
+func (c *Config) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := yaml.UnmarshalWithOptions(content, c, yaml.Strict()); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	// Addresses are lowercased the same way the API does it
+	hot := make(map[string]int, len(c.HotWallets))
+	for address, slots := range c.HotWallets {
+		hot[strings.ToLower(address)] = slots
 	}
+	c.HotWallets = hot
+	return nil
+}
 
+// loadEnv overrides the values set in environment variables.
+func (c *Config) loadEnv() error {
+	stringFromEnv("STORE", &c.Store)
+	stringFromEnv("DATABASE_URL", &c.DatabaseURL)
+	stringFromEnv("GENESIS_FILE", &c.GenesisFile)
+	stringFromEnv("TX_MODE", &c.TxMode)
+	stringFromEnv("AUDIT_PRINCIPAL_HEADER", &c.AuditPrincipalHeader)
+	stringFromEnv("LOG_LEVEL", &c.LogLevel)
+	stringFromEnv("LOG_FORMAT", &c.LogFormat)
+	stringFromEnv("TRACING_EXPORTER", &c.TracingExporter)
+	stringFromEnv("TRACING_ENDPOINT", &c.TracingEndpoint)
+	stringFromEnv("ETH_TOKEN_ADDRESS", &c.EthTokenAddress)
+	stringFromEnv("APQ_CACHE", &c.APQCache)
+	stringFromEnv("PERSISTED_QUERIES_FILE", &c.PersistedQueriesFile)
 	// POST
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	stringFromEnv("PORT", &c.Port)
+
+	// Comma separated, e.g. "ops@example.com,oncall"
+	if raw := os.Getenv("OPERATOR_PRINCIPALS"); raw != "" {
+		c.OperatorPrincipals = nil
+		for _, principal := range strings.Split(raw, ",") {
+			if principal = strings.TrimSpace(principal); principal != "" {
+				c.OperatorPrincipals = append(c.OperatorPrincipals, principal)
+			}
+		}
 	}
 
-	return &Config{
-		DatabaseURL: dbURL,
-		Port:        port,
-	}, nil
+	// Sharded hot wallets, e.g. "0x0000000000000000000000000000000000000000:16"
+	if raw := os.Getenv("HOT_WALLETS"); raw != "" {
+		hotWallets, err := parseHotWallets(raw)
+		if err != nil {
+			return err
+		}
+		c.HotWallets = hotWallets
+	}
+
+	var errs []error
+	for _, v := range []struct {
+		name string
+		ptr  *bool
+	}{
+		{"MIGRATE_ON_START", &c.MigrateOnStart},
+		{"BATCH_ENABLED", &c.BatchEnabled},
+		{"PLAYGROUND_ENABLED", &c.PlaygroundEnabled},
+		{"TRUST_FORWARDED_FOR", &c.TrustForwardedFor},
+		{"TRUST_PRINCIPAL_HEADER", &c.TrustPrincipalHeader},
+		{"PERSISTED_QUERIES_ONLY", &c.PersistedQueriesOnly},
+	} {
+		errs = append(errs, boolFromEnv(v.name, v.ptr))
+	}
+	for _, v := range []struct {
+		name string
+		ptr  *int
+	}{
+		{"DB_MAX_OPEN_CONNS", &c.DBMaxOpenConns},
+		{"DB_MAX_IDLE_CONNS", &c.DBMaxIdleConns},
+		{"DB_CONNECT_ATTEMPTS", &c.DBConnectAttempts},
+		{"TX_MAX_ATTEMPTS", &c.TxMaxAttempts},
+		{"BATCH_MAX_SIZE", &c.BatchMaxSize},
+		{"ETH_CHAIN_ID", &c.EthChainID},
+		// Limits protecting the API from expensive queries
+		{"GRAPHQL_MAX_DEPTH", &c.MaxQueryDepth},
+		{"GRAPHQL_MAX_COMPLEXITY", &c.MaxQueryComplexity},
+		{"APQ_CACHE_SIZE", &c.APQCacheSize},
+	} {
+		errs = append(errs, intFromEnv(v.name, v.ptr))
+	}
+	for _, v := range []struct {
+		name string
+		ptr  *time.Duration
+	}{
+		{"DB_CONN_MAX_LIFETIME", &c.DBConnMaxLifetime},
+		{"DB_CONN_MAX_IDLE_TIME", &c.DBConnMaxIdleTime},
+		{"DB_STATEMENT_TIMEOUT", &c.DBStatementTimeout},
+		{"DB_CONNECT_RETRY_DELAY", &c.DBConnectRetryDelay},
+		{"TX_RETRY_BASE_DELAY", &c.TxRetryBaseDelay},
+		{"TX_RETRY_MAX_DELAY", &c.TxRetryMaxDelay},
+		{"BATCH_MAX_WAIT", &c.BatchMaxWait},
+		{"HTTP_READ_TIMEOUT", &c.HTTPReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", &c.HTTPWriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", &c.HTTPIdleTimeout},
+		{"SHUTDOWN_TIMEOUT", &c.ShutdownTimeout},
+		{"SHUTDOWN_DRAIN_DELAY", &c.ShutdownDrainDelay},
+		{"HEALTH_CHECK_TIMEOUT", &c.HealthCheckTimeout},
+		{"VERIFY_INTERVAL", &c.VerifyInterval},
+		{"APQ_CACHE_TTL", &c.APQCacheTTL},
+		{"GRAPHQL_GET_MAX_AGE", &c.GraphQLGetMaxAge},
+	} {
+		errs = append(errs, durationFromEnv(v.name, v.ptr))
+	}
+
+	maxBytes := int(c.MaxRequestBytes)
+	errs = append(errs, intFromEnv("MAX_REQUEST_BYTES", &maxBytes))
+	c.MaxRequestBytes = int64(maxBytes)
+
+	return errors.Join(errs...)
+}
+
+// Validate checks the whole configuration and reports every problem at once.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Store != StorePostgres && c.Store != StoreMemory {
+		add("STORE must be %q or %q, got: %q", StorePostgres, StoreMemory, c.Store)
+	}
+	if c.DatabaseURL == "" && c.Store == StorePostgres {
+		// Jeśli brak zmiennej - zwracamy błąd. Aplikacja nie może bez tego działać.
+		add("required environment variable DATABASE_URL is missing")
+	}
+	if c.TxMode != TxModeLocking && c.TxMode != TxModeSerializable && c.TxMode != TxModeSingle {
+		add("TX_MODE must be %q, %q or %q, got: %q", TxModeLocking, TxModeSerializable, TxModeSingle, c.TxMode)
+	}
+	if c.AuditPrincipalHeader == "" {
+		add("AUDIT_PRINCIPAL_HEADER must not be empty")
+	}
+	// Only the database keeps wallet metadata
+	if c.TrustPrincipalHeader && c.Store != StorePostgres {
+		add("TRUST_PRINCIPAL_HEADER requires STORE=%s", StorePostgres)
+	}
+	// Anyone could claim to be an operator otherwise
+	if len(c.OperatorPrincipals) > 0 && !c.TrustPrincipalHeader {
+		add("OPERATOR_PRINCIPALS requires TRUST_PRINCIPAL_HEADER")
+	}
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		add("LOG_LEVEL must be debug, info, warn or error, got: %q", c.LogLevel)
+	}
+	if c.LogFormat != LogFormatJSON && c.LogFormat != LogFormatText {
+		add("LOG_FORMAT must be %q or %q, got: %q", LogFormatJSON, LogFormatText, c.LogFormat)
+	}
+	if c.TracingExporter != TracingNone && c.TracingExporter != TracingStdout && c.TracingExporter != TracingOTLP {
+		add("TRACING_EXPORTER must be %q, %q or %q, got: %q", TracingNone, TracingStdout, TracingOTLP, c.TracingExporter)
+	}
+	if c.TracingExporter == TracingOTLP {
+		if u, err := url.Parse(c.TracingEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("TRACING_ENDPOINT must be an http(s) URL, got: %q", c.TracingEndpoint)
+		}
+	}
+	if c.APQCache != APQCacheMemory && c.APQCache != APQCachePostgres {
+		add("APQ_CACHE must be %q or %q, got: %q", APQCacheMemory, APQCachePostgres, c.APQCache)
+	}
+	if c.APQCache == APQCachePostgres && c.Store != StorePostgres {
+		add("APQ_CACHE=%s requires STORE=%s", APQCachePostgres, StorePostgres)
+	}
+	if c.PersistedQueriesOnly && c.PersistedQueriesFile == "" {
+		add("PERSISTED_QUERIES_ONLY requires PERSISTED_QUERIES_FILE")
+	}
+	if c.EthChainID < 0 {
+		add("ETH_CHAIN_ID must not be negative, got: %d", c.EthChainID)
+	}
+	if c.EthChainID > 0 && !isEthAddress(c.EthTokenAddress) {
+		add("ETH_TOKEN_ADDRESS must be 0x followed by 40 hex digits, got: %q", c.EthTokenAddress)
+	}
+	if port, err := strconv.Atoi(c.Port); err != nil || port <= 0 || port > 65535 {
+		add("PORT must be a port number, got: %q", c.Port)
+	}
+
+	for _, v := range []struct {
+		name  string
+		value int
+	}{
+		{"DB_CONNECT_ATTEMPTS", c.DBConnectAttempts},
+		{"TX_MAX_ATTEMPTS", c.TxMaxAttempts},
+		{"BATCH_MAX_SIZE", c.BatchMaxSize},
+		{"GRAPHQL_MAX_DEPTH", c.MaxQueryDepth},
+		{"GRAPHQL_MAX_COMPLEXITY", c.MaxQueryComplexity},
+		{"MAX_REQUEST_BYTES", int(c.MaxRequestBytes)},
+		{"APQ_CACHE_SIZE", c.APQCacheSize},
+	} {
+		if v.value <= 0 {
+			add("%s must be positive, got: %d", v.name, v.value)
+		}
+	}
+	if c.DBMaxOpenConns < 0 || c.DBMaxIdleConns < 0 {
+		add("DB_MAX_OPEN_CONNS and DB_MAX_IDLE_CONNS must not be negative")
+	}
+	if c.DBMaxOpenConns > 0 && c.DBMaxIdleConns > c.DBMaxOpenConns {
+		add("DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", c.DBMaxIdleConns, c.DBMaxOpenConns)
+	}
+	for _, v := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"DB_CONN_MAX_LIFETIME", c.DBConnMaxLifetime},
+		{"DB_CONN_MAX_IDLE_TIME", c.DBConnMaxIdleTime},
+		{"DB_STATEMENT_TIMEOUT", c.DBStatementTimeout},
+		{"DB_CONNECT_RETRY_DELAY", c.DBConnectRetryDelay},
+		{"TX_RETRY_BASE_DELAY", c.TxRetryBaseDelay},
+		{"TX_RETRY_MAX_DELAY", c.TxRetryMaxDelay},
+		{"BATCH_MAX_WAIT", c.BatchMaxWait},
+		{"HTTP_READ_TIMEOUT", c.HTTPReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", c.HTTPWriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", c.HTTPIdleTimeout},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+		{"SHUTDOWN_DRAIN_DELAY", c.ShutdownDrainDelay},
+		{"VERIFY_INTERVAL", c.VerifyInterval},
+		{"APQ_CACHE_TTL", c.APQCacheTTL},
+		{"GRAPHQL_GET_MAX_AGE", c.GraphQLGetMaxAge},
+	} {
+		if v.value < 0 {
+			add("%s must not be negative, got: %s", v.name, v.value)
+		}
+	}
+	if c.HealthCheckTimeout <= 0 {
+		add("HEALTH_CHECK_TIMEOUT must be positive, got: %s", c.HealthCheckTimeout)
+	}
+	if c.TxRetryBaseDelay > c.TxRetryMaxDelay {
+		add("TX_RETRY_BASE_DELAY (%s) must not exceed TX_RETRY_MAX_DELAY (%s)", c.TxRetryBaseDelay, c.TxRetryMaxDelay)
+	}
+	for address, slots := range c.HotWallets {
+		if address == "" || slots <= 0 {
+			add("HOT_WALLETS must map addresses to a positive number of slots, got: %q: %d", address, slots)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// secretSettings may hold credentials, they are left out of Settings
+var secretSettings = []string{"database_url", "tracing_endpoint"}
+
+// Settings returns the settings that hold no secret by YAML key, each value encoded as JSON, e.g. to
+// tell which ones changed.
+func (c *Config) Settings() map[string]string {
+	// Marshaling plain structs can't fail, nor decoding what was just encoded
+	content, _ := yaml.Marshal(c)
+	var values map[string]any
+	_ = yaml.Unmarshal(content, &values)
+
+	settings := make(map[string]string, len(values))
+	for name, value := range values {
+		if slices.Contains(secretSettings, name) {
+			continue
+		}
+		encoded, _ := json.Marshal(value)
+		settings[name] = string(encoded)
+	}
+	return settings
+}
+
+// Redacted returns the configuration as YAML with the passwords of the database and tracing URLs masked,
+// e.g. for logs.
+func (c *Config) Redacted() string {
+	redacted := *c
+	redacted.DatabaseURL = redactURL(c.DatabaseURL)
+	redacted.TracingEndpoint = redactURL(c.TracingEndpoint)
+	// Marshaling plain structs can't fail
+	content, _ := yaml.Marshal(redacted)
+	return string(content)
+}
+
+// passwordParams are the connection parameters holding a password, in the query of a URL or as key=value
+var passwordParams = []string{"password", "sslpassword"}
+
+// passwordField matches a password parameter of a key=value connection string, its value quoted or not
+var passwordField = regexp.MustCompile(`\b(password|sslpassword)\s*=\s*('(?:[^'\\]|\\.)*'|\S*)`)
+
+// redactURL masks the passwords of a connection string or URL: the one of the user info and the password
+// parameters, both in the query of a URL and in the key=value form.
+func redactURL(raw string) string {
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			// Can't tell where the password is
+			return "xxxxx"
+		}
+		query := u.Query()
+		masked := false
+		for _, param := range passwordParams {
+			if query.Has(param) {
+				query.Set(param, "xxxxx")
+				masked = true
+			}
+		}
+		if masked {
+			u.RawQuery = query.Encode()
+		}
+		return u.Redacted()
+	}
+	return passwordField.ReplaceAllString(raw, "$1=xxxxx")
+}
+
+// stringFromEnv overrides *dst when the variable is set.
+func stringFromEnv(name string, dst *string) {
+	if raw := os.Getenv(name); raw != "" {
+		*dst = raw
+	}
+}
+
+// intFromEnv overrides *dst when the variable is set. Ranges are checked by Validate.
+func intFromEnv(name string, dst *int) error {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return fmt.Errorf("environment variable %s must be an integer, got: %q", name, raw)
+	}
+	*dst = v
+	return nil
+}
+
+// boolFromEnv overrides *dst when the variable ("true", "1", "false"...) is set.
+func boolFromEnv(name string, dst *bool) error {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return fmt.Errorf("environment variable %s must be a boolean, got: %q", name, raw)
+	}
+	*dst = v
+	return nil
+}
+
+// durationFromEnv overrides *dst when the variable (e.g. "250ms") is set.
+func durationFromEnv(name string, dst *time.Duration) error {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return nil
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil {
+		return fmt.Errorf("environment variable %s must be a duration, got: %q", name, raw)
+	}
+	*dst = v
+	return nil
+}
+
+// isEthAddress reports whether s is an Ethereum address, in any case.
+func isEthAddress(s string) bool {
+	digits, ok := strings.CutPrefix(s, "0x")
+	if !ok || len(digits) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(digits)
+	return err == nil
+}
+
+// parseHotWallets parses a comma separated list of "address:slots" pairs.
+// Addresses are lowercased the same way the API does it.
+func parseHotWallets(raw string) (map[string]int, error) {
+	hot := map[string]int{}
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		address, rawSlots, ok := strings.Cut(item, ":")
+		slots, err := strconv.Atoi(rawSlots)
+		if !ok || address == "" || err != nil || slots <= 0 {
+			return nil, fmt.Errorf("environment variable HOT_WALLETS must be a list of address:slots pairs, got: %q", item)
+		}
+		hot[strings.ToLower(address)] = slots
+	}
+	return hot, nil
 }
@@ -0,0 +1,104 @@
+package eth
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Function selectors of the ERC-20 interface served by the token: the first 4 bytes of the
+// keccak-256 of the signature
+var (
+	selectorName        = selector("name()")
+	selectorSymbol      = selector("symbol()")
+	selectorDecimals    = selector("decimals()")
+	selectorTotalSupply = selector("totalSupply()")
+	selectorBalanceOf   = selector("balanceOf(address)")
+	selectorTransfer    = selector("transfer(address,uint256)")
+)
+
+// transferTopic is the first topic of Transfer(address,address,uint256) event logs
+var transferTopic = hexBytes(keccak256([]byte("Transfer(address,address,uint256)")))
+
+var errBadCallData = errors.New("invalid call data")
+
+func selector(signature string) string {
+	return hex.EncodeToString(keccak256([]byte(signature))[:4])
+}
+
+// callSelector splits call data into its selector (hex) and arguments.
+func callSelector(data []byte) (string, []byte, error) {
+	if len(data) < 4 {
+		return "", nil, fmt.Errorf("%w: no function selector", errBadCallData)
+	}
+	return hex.EncodeToString(data[:4]), data[4:], nil
+}
+
+// abiAddress reads the address argument at index i.
+func abiAddress(args []byte, i int) (string, error) {
+	word, err := abiWord(args, i)
+	if err != nil {
+		return "", err
+	}
+	for _, b := range word[:12] {
+		if b != 0 {
+			return "", fmt.Errorf("%w: argument %d is not an address", errBadCallData, i)
+		}
+	}
+	return hexBytes(word[12:]), nil
+}
+
+// abiUint reads the uint256 argument at index i.
+func abiUint(args []byte, i int) (*big.Int, error) {
+	word, err := abiWord(args, i)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(word), nil
+}
+
+func abiWord(args []byte, i int) ([]byte, error) {
+	if len(args) < 32*(i+1) {
+		return nil, fmt.Errorf("%w: missing argument %d", errBadCallData, i)
+	}
+	return args[32*i : 32*(i+1)], nil
+}
+
+// word32 left-pads b to a 32-byte ABI word.
+func word32(b []byte) []byte {
+	word := make([]byte, 32)
+	copy(word[32-len(b):], b)
+	return word
+}
+
+// encodeUint returns n as an ABI uint256.
+func encodeUint(n int64) []byte {
+	return word32(big.NewInt(n).Bytes())
+}
+
+// encodeAddress returns address as an ABI word, or a log topic.
+func encodeAddress(address string) []byte {
+	b, _ := hex.DecodeString(address[2:])
+	return word32(b)
+}
+
+// encodeString returns s as the only, dynamic, return value: offset, length, then the padded bytes.
+func encodeString(s string) []byte {
+	padded := make([]byte, (len(s)+31)/32*32)
+	copy(padded, s)
+	out := append(encodeUint(32), encodeUint(int64(len(s)))...)
+	return append(out, padded...)
+}
+
+// bloom is the 2048-bit filter of a block or receipt (yellow paper, section 4.3.1): each address
+// and topic sets 3 bits chosen from its keccak-256.
+type bloom [256]byte
+
+func (b *bloom) add(data []byte) {
+	h := keccak256(data)
+	for i := 0; i < 6; i += 2 {
+		bit := (uint(h[i])<<8 | uint(h[i+1])) & 2047
+		b[255-bit/8] |= 1 << (bit % 8)
+	}
+}
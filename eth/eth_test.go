@@ -0,0 +1,375 @@
+package eth
+
+import (
+	"btp-transfer/ledger"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+const (
+	testChainID = 1337
+	testToken   = "0x00000000000000000000000000000000000000b7"
+)
+
+// fakeLedger keeps balances, nonces and entries in memory, following the rules of TransferSigned
+type fakeLedger struct {
+	balances map[string]int64
+	nonces   map[string]uint64
+	entries  []ledger.Entry
+	hashes   map[string]int64
+}
+
+func newFakeLedger(balances map[string]int64) *fakeLedger {
+	return &fakeLedger{balances: balances, nonces: map[string]uint64{}, hashes: map[string]int64{}}
+}
+
+func (f *fakeLedger) Balance(_ context.Context, address string) (int64, error) {
+	balance, ok := f.balances[address]
+	if !ok {
+		return 0, &ledger.WalletNotFoundError{Address: address}
+	}
+	return balance, nil
+}
+
+func (f *fakeLedger) TotalSupply(context.Context) (int64, error) {
+	var supply int64
+	for _, balance := range f.balances {
+		supply += balance
+	}
+	return supply, nil
+}
+
+func (f *fakeLedger) Nonce(_ context.Context, address string) (uint64, error) {
+	return f.nonces[address], nil
+}
+
+func (f *fakeLedger) TransferSigned(_ context.Context, t ledger.SignedTransfer) (int64, error) {
+	if _, ok := f.hashes[t.Hash]; ok {
+		return f.balances[t.From], nil
+	}
+	if t.Nonce != f.nonces[t.From] {
+		return 0, &ledger.InvalidNonceError{Address: t.From, Expected: f.nonces[t.From], Got: t.Nonce}
+	}
+	balance, ok := f.balances[t.From]
+	if !ok {
+		return 0, &ledger.WalletNotFoundError{Address: t.From}
+	}
+	if balance < t.Amount {
+		return 0, &ledger.InsufficientBalanceError{Address: t.From, Available: balance, Requested: t.Amount}
+	}
+	f.balances[t.From] -= t.Amount
+	f.balances[t.To] += t.Amount
+	f.nonces[t.From]++
+	id := int64(len(f.entries) + 1)
+	f.entries = append(f.entries, ledger.Entry{ID: id, CreatedAt: time.Unix(1700000000, 0), Kind: ledger.EntryTransfer, From: t.From, To: t.To, Amount: t.Amount})
+	f.hashes[t.Hash] = id
+	return f.balances[t.From], nil
+}
+
+func (f *fakeLedger) SignedEntry(_ context.Context, hash string) (*ledger.Entry, error) {
+	id, ok := f.hashes[hash]
+	if !ok {
+		return nil, nil
+	}
+	return &f.entries[id-1], nil
+}
+
+func (f *fakeLedger) Entry(_ context.Context, id int64) (*ledger.Entry, error) {
+	if id < 1 || id > int64(len(f.entries)) {
+		return nil, nil
+	}
+	return &f.entries[id-1], nil
+}
+
+func (f *fakeLedger) LastEntryID(context.Context) (int64, error) {
+	return int64(len(f.entries)), nil
+}
+
+// signTransfer returns a raw EIP-1559 transaction calling transfer(to, amount) on the token
+func signTransfer(t *testing.T, key *secp256k1.PrivateKey, chainID int64, nonce uint64, to string, amount int64) string {
+	t.Helper()
+	data := append(mustHex(t, "0x"+selectorTransfer), encodeAddress(to)...)
+	data = append(data, encodeUint(amount)...)
+	fields := [][]byte{
+		encodeRLPInt(big.NewInt(chainID)),
+		encodeRLPInt(new(big.Int).SetUint64(nonce)),
+		encodeRLPInt(big.NewInt(0)),
+		encodeRLPInt(big.NewInt(0)),
+		encodeRLPInt(big.NewInt(gasPerTransfer)),
+		encodeRLPBytes(mustHex(t, testToken)),
+		encodeRLPInt(big.NewInt(0)),
+		encodeRLPBytes(data),
+		encodeRLPList(),
+	}
+	hash := keccak256([]byte{TxDynamicFee}, encodeRLPList(fields...))
+	sig := ecdsa.SignCompact(key, hash, false)
+	fields = append(fields,
+		encodeRLPInt(big.NewInt(int64(sig[0]-27))),
+		encodeRLPInt(new(big.Int).SetBytes(sig[1:33])),
+		encodeRLPInt(new(big.Int).SetBytes(sig[33:])),
+	)
+	return hexBytes(append([]byte{TxDynamicFee}, encodeRLPList(fields...)...))
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := parseHexBytes(s)
+	if err != nil {
+		t.Fatalf("Invalid hex %q: %v", s, err)
+	}
+	return b
+}
+
+// rpc sends one call and decodes its response
+func rpc(t *testing.T, h http.Handler, method string, params ...any) response {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(string(body))))
+	var res response
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("Failed to decode %s response %q: %v", method, rec.Body.String(), err)
+	}
+	return res
+}
+
+// result decodes the result of a successful call into v
+func result(t *testing.T, res response, v any) {
+	t.Helper()
+	if res.Error != nil {
+		t.Fatalf("Expected a result, got error %+v", res.Error)
+	}
+	if err := json.Unmarshal(res.Result, v); err != nil {
+		t.Fatalf("Failed to decode result %s: %v", res.Result, err)
+	}
+}
+
+func newServer(l Ledger) *Server {
+	return NewServer(l, Token{Address: testToken, Name: "BTP Token", Symbol: "BTP", Decimals: 0, ChainID: testChainID}, 1<<16)
+}
+
+// 1. Known answers
+// Goal: Hashes, addresses and signatures match the ones of Ethereum.
+func TestKnownAnswers(t *testing.T) {
+	if transferTopic != "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef" {
+		t.Errorf("Unexpected Transfer topic %s", transferTopic)
+	}
+	if selectorTransfer != "a9059cbb" || selectorBalanceOf != "70a08231" {
+		t.Errorf("Unexpected selectors %s, %s", selectorTransfer, selectorBalanceOf)
+	}
+
+	var one secp256k1.ModNScalar
+	one.SetInt(1)
+	if address := PublicKeyAddress(secp256k1.NewPrivateKey(&one).PubKey()); address != "0x7e5f4552091a69125d5dfcb7b8c2659029395bdf" {
+		t.Errorf("Unexpected address of private key 1: %s", address)
+	}
+
+	// Example transaction of EIP-155
+	raw := mustHex(t, "0xf86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83")
+	tx, err := DecodeTransaction(raw)
+	if err != nil {
+		t.Fatalf("Failed to decode EIP-155 transaction: %v", err)
+	}
+	if tx.From != "0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f" || tx.ChainID.Int64() != 1 || tx.Nonce != 9 || tx.To != "0x3535353535353535353535353535353535353535" {
+		t.Errorf("Unexpected transaction %+v", tx)
+	}
+}
+
+// 2. Malformed transactions
+// Goal: Truncated, unprotected and tampered transactions are refused.
+func TestDecodeTransaction_Invalid(t *testing.T) {
+	key, _ := secp256k1.GeneratePrivateKey()
+	valid := mustHex(t, signTransfer(t, key, testChainID, 0, "0x00000000000000000000000000000000000000aa", 5))
+
+	if _, err := DecodeTransaction(valid[:len(valid)-1]); !errors.Is(err, errRLP) {
+		t.Errorf("Expected malformed RLP for a truncated transaction, got %v", err)
+	}
+	// Legacy transaction without chain ID (v = 27)
+	unprotected := mustHex(t, "0xf85f800182520894095e7baea6a6c7c4c2dfeb977efac326af552d870a801ba048b55bfa915ac795c431978d8a6a992b628d557da5ff759b307d495a36649353a01fffd310ac743f371de3b9f7f9cb56c0b28ad43601b4ab949f53faa07bd2c804")
+	if _, err := DecodeTransaction(unprotected); !errors.Is(err, ErrNoChainID) {
+		t.Errorf("Expected ErrNoChainID, got %v", err)
+	}
+	if _, err := DecodeTransaction([]byte{0x05, 0xc0}); !errors.Is(err, ErrUnsupportedTx) {
+		t.Errorf("Expected ErrUnsupportedTx, got %v", err)
+	}
+	// A legacy transaction behind type 0x00
+	if _, err := DecodeTransaction(append([]byte{0x00}, valid...)); !errors.Is(err, ErrUnsupportedTx) {
+		t.Errorf("Expected ErrUnsupportedTx for type 0x00, got %v", err)
+	}
+	s := newServer(newFakeLedger(map[string]int64{PublicKeyAddress(key.PubKey()): 10}))
+	if res := rpc(t, s, "eth_sendRawTransaction", "0x00"+hex.EncodeToString(valid)); res.Error == nil || res.Error.Code != codeInvalidParams {
+		t.Errorf("Expected invalid params for type 0x00, got %+v", res.Error)
+	}
+
+	// Changing the amount changes the recovered sender
+	tampered := append([]byte(nil), valid...)
+	tampered[len(tampered)-70] ^= 1
+	tx, err := DecodeTransaction(tampered)
+	if err == nil && tx.From == PublicKeyAddress(key.PubKey()) {
+		t.Error("Expected a tampered transaction not to recover the signer")
+	}
+}
+
+// 3. Token calls
+// Goal: eth_call answers the ERC-20 read functions from the ledger.
+func TestCall(t *testing.T) {
+	s := newServer(newFakeLedger(map[string]int64{"0x00000000000000000000000000000000000000aa": 70, "0x00000000000000000000000000000000000000bb": 30}))
+	call := func(data string) response {
+		return rpc(t, s, "eth_call", map[string]string{"to": testToken, "data": data}, "latest")
+	}
+	word := func(n int64) string { return hexBytes(encodeUint(n)) }
+
+	tests := []struct {
+		name, data, want string
+	}{
+		{"totalSupply", "0x" + selectorTotalSupply, word(100)},
+		{"decimals", "0x" + selectorDecimals, word(0)},
+		{"balanceOf", "0x" + selectorBalanceOf + hex.EncodeToString(encodeAddress("0x00000000000000000000000000000000000000aa")), word(70)},
+		{"balanceOf unknown", "0x" + selectorBalanceOf + hex.EncodeToString(encodeAddress("0x00000000000000000000000000000000000000cc")), word(0)},
+		{"symbol", "0x" + selectorSymbol, hexBytes(encodeString("BTP"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			result(t, call(tt.data), &got)
+			if got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	if res := call("0xdeadbeef"); res.Error == nil || res.Error.Code != codeReverted {
+		t.Errorf("Expected a revert for an unknown function, got %+v", res)
+	}
+	var other string
+	result(t, rpc(t, s, "eth_call", map[string]string{"to": "0x00000000000000000000000000000000000000aa", "data": "0x" + selectorTotalSupply}), &other)
+	if other != "0x" {
+		t.Errorf("Expected empty result for another address, got %s", other)
+	}
+}
+
+// 4. Signed transfer
+// Goal: A signed transfer moves tokens, increments the nonce and has a receipt with a Transfer log;
+// sending it again changes nothing and a replayed nonce is refused.
+func TestSendRawTransaction(t *testing.T) {
+	key, _ := secp256k1.GeneratePrivateKey()
+	sender := PublicKeyAddress(key.PubKey())
+	receiver := "0x00000000000000000000000000000000000000bb"
+	l := newFakeLedger(map[string]int64{sender: 100})
+	s := newServer(l)
+
+	raw := signTransfer(t, key, testChainID, 0, receiver, 40)
+	var hash string
+	result(t, rpc(t, s, "eth_sendRawTransaction", raw), &hash)
+	if l.balances[sender] != 60 || l.balances[receiver] != 40 {
+		t.Fatalf("Expected balances 60 and 40, got %v", l.balances)
+	}
+	var nonce string
+	result(t, rpc(t, s, "eth_getTransactionCount", sender, "latest"), &nonce)
+	if nonce != "0x1" {
+		t.Errorf("Expected nonce 0x1, got %s", nonce)
+	}
+
+	var receipt struct {
+		Status      string `json:"status"`
+		BlockNumber string `json:"blockNumber"`
+		From        string `json:"from"`
+		Logs        []struct {
+			Address string   `json:"address"`
+			Topics  []string `json:"topics"`
+			Data    string   `json:"data"`
+		} `json:"logs"`
+	}
+	result(t, rpc(t, s, "eth_getTransactionReceipt", hash), &receipt)
+	if receipt.Status != "0x1" || receipt.BlockNumber != "0x1" || receipt.From != sender || len(receipt.Logs) != 1 {
+		t.Fatalf("Unexpected receipt %+v", receipt)
+	}
+	log := receipt.Logs[0]
+	wantTopics := []string{transferTopic, hexBytes(encodeAddress(sender)), hexBytes(encodeAddress(receiver))}
+	if log.Address != testToken || strings.Join(log.Topics, ",") != strings.Join(wantTopics, ",") || log.Data != hexBytes(encodeUint(40)) {
+		t.Errorf("Unexpected Transfer log %+v", log)
+	}
+
+	// Sending the same transaction again is not an error, nor a second transfer
+	result(t, rpc(t, s, "eth_sendRawTransaction", raw), &hash)
+	if l.balances[sender] != 60 {
+		t.Errorf("Expected the transaction to be applied once, balance is %d", l.balances[sender])
+	}
+
+	tests := []struct {
+		name string
+		raw  string
+		code string
+	}{
+		{"replayed nonce", signTransfer(t, key, testChainID, 0, receiver, 1), ledger.CodeInvalidNonce},
+		{"insufficient balance", signTransfer(t, key, testChainID, 1, receiver, 1000), ledger.CodeInsufficientBalance},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := rpc(t, s, "eth_sendRawTransaction", tt.raw)
+			if res.Error == nil {
+				t.Fatalf("Expected %s, got result %s", tt.code, res.Result)
+			}
+			data, _ := res.Error.Data.(map[string]any)
+			if res.Error.Code != codeServer || data["code"] != tt.code {
+				t.Errorf("Expected %s, got %+v", tt.code, res.Error)
+			}
+		})
+	}
+	if res := rpc(t, s, "eth_sendRawTransaction", signTransfer(t, key, 1, 1, receiver, 1)); res.Error == nil {
+		t.Error("Expected an error for another chain ID")
+	}
+
+	var missing json.RawMessage
+	result(t, rpc(t, s, "eth_getTransactionReceipt", hexBytes(make([]byte, 32))), &missing)
+	if string(missing) != "null" {
+		t.Errorf("Expected null receipt for an unknown hash, got %s", missing)
+	}
+}
+
+// 5. Protocol
+// Goal: Batches are answered in order, unknown methods and malformed requests get JSON-RPC errors.
+func TestServeHTTP(t *testing.T) {
+	s := newServer(newFakeLedger(map[string]int64{}))
+
+	rec := httptest.NewRecorder()
+	body := `[{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},{"jsonrpc":"2.0","id":2,"method":"eth_nope"},{"jsonrpc":"2.0","method":"eth_chainId"}]`
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body)))
+	var batch []response
+	if err := json.Unmarshal(rec.Body.Bytes(), &batch); err != nil {
+		t.Fatalf("Failed to decode batch response %q: %v", rec.Body.String(), err)
+	}
+	if len(batch) != 2 {
+		t.Fatalf("Expected 2 responses (no response to the notification), got %d", len(batch))
+	}
+	if string(batch[0].ID) != "1" || string(batch[0].Result) != `"0x539"` {
+		t.Errorf("Expected chain ID 0x539, got %+v", batch[0])
+	}
+	if string(batch[1].ID) != "2" || batch[1].Error == nil || batch[1].Error.Code != codeMethodNotFound {
+		t.Errorf("Expected method not found, got %+v", batch[1])
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(`{"jsonrpc":`)))
+	var res response
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || res.Error == nil || res.Error.Code != codeParse {
+		t.Errorf("Expected parse error, got %q", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rpc", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET, got %d", rec.Code)
+	}
+}
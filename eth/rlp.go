@@ -0,0 +1,158 @@
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// rlpItem is a decoded RLP item: a byte string, or a list of items.
+type rlpItem struct {
+	// raw is the whole encoding of the item, header included
+	raw    []byte
+	str    []byte
+	list   []rlpItem
+	isList bool
+}
+
+var errRLP = errors.New("malformed RLP")
+
+// decodeRLP decodes one item that must span the whole input.
+func decodeRLP(b []byte) (rlpItem, error) {
+	item, rest, err := decodeRLPItem(b)
+	if err != nil {
+		return rlpItem{}, err
+	}
+	if len(rest) > 0 {
+		return rlpItem{}, fmt.Errorf("%w: %d trailing bytes", errRLP, len(rest))
+	}
+	return item, nil
+}
+
+func decodeRLPItem(b []byte) (rlpItem, []byte, error) {
+	if len(b) == 0 {
+		return rlpItem{}, nil, fmt.Errorf("%w: unexpected end of input", errRLP)
+	}
+	prefix := b[0]
+	var offset, size int
+	isList := prefix >= 0xc0
+	switch {
+	case prefix < 0x80:
+		return rlpItem{raw: b[:1], str: b[:1]}, b[1:], nil
+	case prefix <= 0xb7:
+		offset, size = 1, int(prefix-0x80)
+	case prefix < 0xc0:
+		n, err := rlpLength(b, int(prefix-0xb7))
+		if err != nil {
+			return rlpItem{}, nil, err
+		}
+		offset, size = 1+int(prefix-0xb7), n
+	case prefix <= 0xf7:
+		offset, size = 1, int(prefix-0xc0)
+	default:
+		n, err := rlpLength(b, int(prefix-0xf7))
+		if err != nil {
+			return rlpItem{}, nil, err
+		}
+		offset, size = 1+int(prefix-0xf7), n
+	}
+	if size > len(b)-offset {
+		return rlpItem{}, nil, fmt.Errorf("%w: item of %d bytes exceeds the input", errRLP, size)
+	}
+	item := rlpItem{raw: b[:offset+size], isList: isList}
+	content := b[offset : offset+size]
+	if !isList {
+		// A single byte below 0x80 is its own encoding
+		if size == 1 && offset == 1 && content[0] < 0x80 {
+			return rlpItem{}, nil, fmt.Errorf("%w: non-canonical single byte", errRLP)
+		}
+		item.str = content
+		return item, b[offset+size:], nil
+	}
+	for len(content) > 0 {
+		child, rest, err := decodeRLPItem(content)
+		if err != nil {
+			return rlpItem{}, nil, err
+		}
+		item.list = append(item.list, child)
+		content = rest
+	}
+	return item, b[offset+size:], nil
+}
+
+// rlpLength reads the big-endian length of a long string or list, which follows the prefix.
+func rlpLength(b []byte, lenOfLen int) (int, error) {
+	if lenOfLen > 4 || len(b) < 1+lenOfLen {
+		return 0, fmt.Errorf("%w: invalid length", errRLP)
+	}
+	if b[1] == 0 {
+		return 0, fmt.Errorf("%w: length with leading zeros", errRLP)
+	}
+	n := 0
+	for _, c := range b[1 : 1+lenOfLen] {
+		n = n<<8 | int(c)
+	}
+	if n < 56 {
+		return 0, fmt.Errorf("%w: non-canonical length", errRLP)
+	}
+	return n, nil
+}
+
+// uint64 reads an item as an unsigned integer (big-endian, no leading zeros).
+func (it rlpItem) uint64() (uint64, error) {
+	n, err := it.bigInt()
+	if err != nil {
+		return 0, err
+	}
+	if !n.IsUint64() {
+		return 0, fmt.Errorf("%w: integer overflows 64 bits", errRLP)
+	}
+	return n.Uint64(), nil
+}
+
+func (it rlpItem) bigInt() (*big.Int, error) {
+	if it.isList {
+		return nil, fmt.Errorf("%w: expected an integer, got a list", errRLP)
+	}
+	if len(it.str) > 32 || (len(it.str) > 0 && it.str[0] == 0) {
+		return nil, fmt.Errorf("%w: invalid integer", errRLP)
+	}
+	return new(big.Int).SetBytes(it.str), nil
+}
+
+// encodeRLPList wraps already encoded items in a list.
+func encodeRLPList(items ...[]byte) []byte {
+	size := 0
+	for _, item := range items {
+		size += len(item)
+	}
+	out := rlpHeader(0xc0, size)
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+// encodeRLPInt encodes a non-negative integer.
+func encodeRLPInt(n *big.Int) []byte {
+	return encodeRLPBytes(n.Bytes())
+}
+
+// encodeRLPBytes encodes a byte string.
+func encodeRLPBytes(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return b
+	}
+	return append(rlpHeader(0x80, len(b)), b...)
+}
+
+func rlpHeader(base byte, size int) []byte {
+	if size < 56 {
+		return []byte{base + byte(size)}
+	}
+	var length []byte
+	for n := size; n > 0; n >>= 8 {
+		length = append([]byte{byte(n)}, length...)
+	}
+	return append([]byte{base + 55 + byte(len(length))}, length...)
+}
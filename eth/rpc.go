@@ -0,0 +1,535 @@
+// Package eth serves the ledger as an ERC-20 token over Ethereum JSON-RPC 2.0, so tools written for
+// Ethereum (ethers.js, web3.py, wallets) can read balances and send transfers unchanged.
+//
+// The ledger poses as a single token contract at a configured address on a configured chain.
+// Transfers are ERC-20 transfer(address,uint256) transactions signed by the sender: the sender is
+// recovered from the signature, and the wallet's nonce protects against replays. Every ledger entry
+// is presented as a block of its own, so receipts point at a block that exists.
+package eth
+
+import (
+	"btp-transfer/ledger"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Ledger is the part of the store the facade reads and writes; *ledger.PostgresStore implements it.
+type Ledger interface {
+	Balance(ctx context.Context, address string) (int64, error)
+	TotalSupply(ctx context.Context) (int64, error)
+	Nonce(ctx context.Context, address string) (uint64, error)
+	TransferSigned(ctx context.Context, t ledger.SignedTransfer) (int64, error)
+	SignedEntry(ctx context.Context, hash string) (*ledger.Entry, error)
+	Entry(ctx context.Context, id int64) (*ledger.Entry, error)
+	LastEntryID(ctx context.Context) (int64, error)
+}
+
+// Token describes the contract the ledger poses as.
+type Token struct {
+	// Address of the contract, transactions must be sent to it
+	Address  string
+	Name     string
+	Symbol   string
+	Decimals int
+	ChainID  int64
+}
+
+// JSON-RPC error codes. Ledger errors use codeServer, like Ethereum clients do for rejected
+// transactions, with the ledger code (INSUFFICIENT_BALANCE...) in data.
+const (
+	codeParse          = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternal       = -32603
+	codeServer         = -32000
+	// codeReverted is returned by eth_call for functions the token doesn't have
+	codeReverted = 3
+)
+
+// gasPerTransfer is reported for every transfer; gas is not charged, but clients expect a value
+const gasPerTransfer = 50000
+
+// maxBatch caps the number of calls in a batch request
+const maxBatch = 100
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func invalidParams(format string, args ...any) *Error {
+	return &Error{Code: codeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Server answers JSON-RPC requests.
+type Server struct {
+	ledger       Ledger
+	token        Token
+	maxBodyBytes int64
+	methods      map[string]func(ctx context.Context, params []json.RawMessage) (any, error)
+}
+
+// NewServer returns the JSON-RPC handler of the token. Request bodies are capped at maxBodyBytes.
+func NewServer(l Ledger, token Token, maxBodyBytes int64) *Server {
+	s := &Server{ledger: l, token: token, maxBodyBytes: maxBodyBytes}
+	s.token.Address = strings.ToLower(token.Address)
+	s.methods = map[string]func(ctx context.Context, params []json.RawMessage) (any, error){
+		"eth_chainId":               s.chainID,
+		"net_version":               s.netVersion,
+		"eth_blockNumber":           s.blockNumber,
+		"eth_getBlockByNumber":      s.getBlockByNumber,
+		"eth_gasPrice":              zero,
+		"eth_maxPriorityFeePerGas":  zero,
+		"eth_getBalance":            zero,
+		"eth_estimateGas":           s.estimateGas,
+		"eth_getTransactionCount":   s.getTransactionCount,
+		"eth_call":                  s.call,
+		"eth_sendRawTransaction":    s.sendRawTransaction,
+		"eth_getTransactionReceipt": s.getTransactionReceipt,
+	}
+	return s
+}
+
+// ServeHTTP handles a single call or a batch.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxBodyBytes)).Decode(&body); err != nil {
+		writeJSON(w, response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: codeParse, Message: err.Error()}})
+		return
+	}
+
+	if body = bytes.TrimSpace(body); len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil || len(batch) == 0 || len(batch) > maxBatch {
+			message := fmt.Sprintf("a batch must hold 1 to %d calls", maxBatch)
+			writeJSON(w, response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: codeInvalidRequest, Message: message}})
+			return
+		}
+		responses := make([]response, 0, len(batch))
+		for _, raw := range batch {
+			if res, ok := s.handle(r.Context(), raw); ok {
+				responses = append(responses, res)
+			}
+		}
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, responses)
+		return
+	}
+
+	res, ok := s.handle(r.Context(), body)
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, res)
+}
+
+// handle runs one call. It reports false for notifications (calls without an ID), which get no response.
+func (s *Server) handle(ctx context.Context, raw json.RawMessage) (response, bool) {
+	res := response{JSONRPC: "2.0", ID: json.RawMessage("null")}
+	var req request
+	if err := json.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+		res.Error = &Error{Code: codeInvalidRequest, Message: "invalid JSON-RPC 2.0 request"}
+		return res, true
+	}
+	if req.ID != nil {
+		res.ID = req.ID
+	}
+
+	method, ok := s.methods[req.Method]
+	if !ok {
+		res.Error = &Error{Code: codeMethodNotFound, Message: fmt.Sprintf("method %s is not supported", req.Method)}
+		return res, req.ID != nil
+	}
+	var params []json.RawMessage
+	if len(req.Params) > 0 && string(req.Params) != "null" {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			res.Error = invalidParams("params must be an array")
+			return res, req.ID != nil
+		}
+	}
+
+	result, err := method(ctx, params)
+	if err != nil {
+		res.Error = s.rpcError(ctx, req.Method, err)
+		return res, req.ID != nil
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		res.Error = s.rpcError(ctx, req.Method, err)
+		return res, req.ID != nil
+	}
+	res.Result = encoded
+	return res, req.ID != nil
+}
+
+// rpcError maps ledger errors to -32000 with the ledger code in data, keeps JSON-RPC errors as they are
+// and logs anything else, answering -32603 with INTERNAL.
+func (s *Server) rpcError(ctx context.Context, method string, err error) *Error {
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	if code, details, ok := ledger.Code(err); ok {
+		data := map[string]any{"code": code}
+		if details != nil {
+			data["details"] = details
+		}
+		return &Error{Code: codeServer, Message: err.Error(), Data: data}
+	}
+	slog.ErrorContext(ctx, "eth: internal error", "method", method, "error", err)
+	return &Error{Code: codeInternal, Message: "internal server error", Data: map[string]any{"code": ledger.CodeInternal}}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// param decodes the i-th parameter into dst; a missing optional parameter leaves dst unchanged.
+func param(params []json.RawMessage, i int, required bool, dst any) error {
+	if i >= len(params) {
+		if required {
+			return invalidParams("missing parameter %d", i)
+		}
+		return nil
+	}
+	if err := json.Unmarshal(params[i], dst); err != nil {
+		return invalidParams("parameter %d: %v", i, err)
+	}
+	return nil
+}
+
+func (s *Server) chainID(context.Context, []json.RawMessage) (any, error) {
+	return hexUint(uint64(s.token.ChainID)), nil
+}
+
+func (s *Server) netVersion(context.Context, []json.RawMessage) (any, error) {
+	return strconv.FormatInt(s.token.ChainID, 10), nil
+}
+
+func zero(context.Context, []json.RawMessage) (any, error) {
+	return "0x0", nil
+}
+
+func (s *Server) estimateGas(context.Context, []json.RawMessage) (any, error) {
+	return hexUint(gasPerTransfer), nil
+}
+
+func (s *Server) blockNumber(ctx context.Context, _ []json.RawMessage) (any, error) {
+	last, err := s.ledger.LastEntryID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return hexUint(uint64(last)), nil
+}
+
+// getBlockByNumber returns the block of a ledger entry: its hash, time and parent. Blocks have no
+// transactions listed, their receipts are found by transaction hash.
+func (s *Server) getBlockByNumber(ctx context.Context, params []json.RawMessage) (any, error) {
+	var tag string
+	if err := param(params, 0, true, &tag); err != nil {
+		return nil, err
+	}
+	last, err := s.ledger.LastEntryID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var number int64
+	switch tag {
+	case "latest", "pending", "safe", "finalized":
+		number = last
+	case "earliest":
+		number = 0
+	default:
+		n, err := parseHexUint(tag)
+		if err != nil || n > math.MaxInt64 {
+			return nil, invalidParams("invalid block number %q", tag)
+		}
+		number = int64(n)
+	}
+	if number > last {
+		return nil, nil
+	}
+
+	var timestamp int64
+	if number > 0 {
+		entry, err := s.ledger.Entry(ctx, number)
+		if err != nil {
+			return nil, err
+		}
+		// IDs skipped by rolled back transactions have no entry, the block is empty
+		if entry != nil {
+			timestamp = entry.CreatedAt.Unix()
+		}
+	}
+	emptyHash := hexBytes(make([]byte, 32))
+	return map[string]any{
+		"number":           hexUint(uint64(number)),
+		"hash":             blockHash(number),
+		"parentHash":       parentHash(number),
+		"timestamp":        hexUint(uint64(timestamp)),
+		"nonce":            "0x0000000000000000",
+		"sha3Uncles":       emptyHash,
+		"logsBloom":        hexBytes(make([]byte, 256)),
+		"transactionsRoot": emptyHash,
+		"stateRoot":        emptyHash,
+		"receiptsRoot":     emptyHash,
+		"miner":            hexBytes(make([]byte, 20)),
+		"difficulty":       "0x0",
+		"extraData":        "0x",
+		"size":             "0x0",
+		"gasLimit":         hexUint(30_000_000),
+		"gasUsed":          "0x0",
+		"baseFeePerGas":    "0x0",
+		"transactions":     []string{},
+		"uncles":           []string{},
+	}, nil
+}
+
+func (s *Server) getTransactionCount(ctx context.Context, params []json.RawMessage) (any, error) {
+	var address string
+	if err := param(params, 0, true, &address); err != nil {
+		return nil, err
+	}
+	nonce, err := s.ledger.Nonce(ctx, strings.ToLower(address))
+	if err != nil {
+		return nil, err
+	}
+	return hexUint(nonce), nil
+}
+
+// call runs a read-only function of the token. Calls to any other address return nothing, like calls
+// to an account without code.
+func (s *Server) call(ctx context.Context, params []json.RawMessage) (any, error) {
+	var msg struct {
+		To    string `json:"to"`
+		Data  string `json:"data"`
+		Input string `json:"input"`
+	}
+	if err := param(params, 0, true, &msg); err != nil {
+		return nil, err
+	}
+	if strings.ToLower(msg.To) != s.token.Address {
+		return "0x", nil
+	}
+	// input is the newer name of data
+	raw := msg.Input
+	if raw == "" {
+		raw = msg.Data
+	}
+	data, err := parseHexBytes(raw)
+	if err != nil {
+		return nil, invalidParams("invalid call data: %v", err)
+	}
+	sel, args, err := callSelector(data)
+	if err != nil {
+		return nil, &Error{Code: codeReverted, Message: "execution reverted: " + err.Error()}
+	}
+
+	var result []byte
+	switch sel {
+	case selectorName:
+		result = encodeString(s.token.Name)
+	case selectorSymbol:
+		result = encodeString(s.token.Symbol)
+	case selectorDecimals:
+		result = encodeUint(int64(s.token.Decimals))
+	case selectorTotalSupply:
+		supply, err := s.ledger.TotalSupply(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result = encodeUint(supply)
+	case selectorBalanceOf:
+		address, err := abiAddress(args, 0)
+		if err != nil {
+			return nil, &Error{Code: codeReverted, Message: "execution reverted: " + err.Error()}
+		}
+		balance, err := s.ledger.Balance(ctx, address)
+		// ERC-20 has no unknown accounts, they hold nothing
+		if errors.Is(err, ledger.ErrWalletNotFound) {
+			balance, err = 0, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = encodeUint(balance)
+	default:
+		return nil, &Error{Code: codeReverted, Message: "execution reverted: unknown function selector 0x" + sel}
+	}
+	return hexBytes(result), nil
+}
+
+// sendRawTransaction applies a signed ERC-20 transfer to the token and returns its hash.
+// The transfer is applied before the response, so its receipt is available right away.
+func (s *Server) sendRawTransaction(ctx context.Context, params []json.RawMessage) (any, error) {
+	var rawHex string
+	if err := param(params, 0, true, &rawHex); err != nil {
+		return nil, err
+	}
+	raw, err := parseHexBytes(rawHex)
+	if err != nil {
+		return nil, invalidParams("invalid transaction: %v", err)
+	}
+	tx, err := DecodeTransaction(raw)
+	if err != nil {
+		return nil, invalidParams("invalid transaction: %v", err)
+	}
+
+	if !tx.ChainID.IsInt64() || tx.ChainID.Int64() != s.token.ChainID {
+		return nil, &Error{Code: codeServer, Message: fmt.Sprintf("invalid chain id %s, expected %d", tx.ChainID, s.token.ChainID)}
+	}
+	if tx.To != s.token.Address {
+		return nil, &Error{Code: codeServer, Message: fmt.Sprintf("transactions must be sent to the token contract %s", s.token.Address)}
+	}
+	if tx.Value.Sign() != 0 {
+		return nil, &Error{Code: codeServer, Message: "the ledger holds no native currency, value must be 0"}
+	}
+	sel, args, err := callSelector(tx.Data)
+	if err != nil || sel != selectorTransfer {
+		return nil, &Error{Code: codeServer, Message: "only transfer(address,uint256) transactions are supported"}
+	}
+	to, err := abiAddress(args, 0)
+	if err != nil {
+		return nil, invalidParams("%v", err)
+	}
+	amount, err := abiUint(args, 1)
+	if err != nil {
+		return nil, invalidParams("%v", err)
+	}
+	if !amount.IsInt64() {
+		return nil, invalidParams("amount %s exceeds the largest balance of the ledger", amount)
+	}
+
+	_, err = s.ledger.TransferSigned(ctx, ledger.SignedTransfer{
+		Hash:   tx.Hash,
+		From:   tx.From,
+		To:     to,
+		Amount: amount.Int64(),
+		Nonce:  tx.Nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tx.Hash, nil
+}
+
+// getTransactionReceipt returns the receipt of an applied transfer with its Transfer event, null for
+// unknown hashes. Rejected transactions are never applied, so every receipt has status 1.
+func (s *Server) getTransactionReceipt(ctx context.Context, params []json.RawMessage) (any, error) {
+	var hash string
+	if err := param(params, 0, true, &hash); err != nil {
+		return nil, err
+	}
+	hash = strings.ToLower(hash)
+	entry, err := s.ledger.SignedEntry(ctx, hash)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	number := hexUint(uint64(entry.ID))
+	topics := []string{transferTopic, hexBytes(encodeAddress(entry.From)), hexBytes(encodeAddress(entry.To))}
+	var filter bloom
+	filter.add(encodeAddress(s.token.Address)[12:])
+	for _, topic := range topics {
+		b, _ := parseHexBytes(topic)
+		filter.add(b)
+	}
+	return map[string]any{
+		"transactionHash":   hash,
+		"transactionIndex":  "0x0",
+		"blockHash":         blockHash(entry.ID),
+		"blockNumber":       number,
+		"from":              entry.From,
+		"to":                s.token.Address,
+		"contractAddress":   nil,
+		"cumulativeGasUsed": hexUint(gasPerTransfer),
+		"gasUsed":           hexUint(gasPerTransfer),
+		"effectiveGasPrice": "0x0",
+		"status":            "0x1",
+		"type":              "0x0",
+		"logsBloom":         hexBytes(filter[:]),
+		"logs": []map[string]any{{
+			"address":          s.token.Address,
+			"topics":           topics,
+			"data":             hexBytes(encodeUint(entry.Amount)),
+			"blockNumber":      number,
+			"blockHash":        blockHash(entry.ID),
+			"transactionHash":  hash,
+			"transactionIndex": "0x0",
+			"logIndex":         "0x0",
+			"removed":          false,
+		}},
+	}, nil
+}
+
+// blockHash is the made-up hash of the block of entry number: stable, and distinct per block.
+func blockHash(number int64) string {
+	return hexBytes(keccak256([]byte("btp-block"), encodeUint(number)))
+}
+
+func parentHash(number int64) string {
+	if number == 0 {
+		return hexBytes(make([]byte, 32))
+	}
+	return blockHash(number - 1)
+}
+
+func hexUint(n uint64) string {
+	return "0x" + strconv.FormatUint(n, 16)
+}
+
+func parseHexUint(s string) (uint64, error) {
+	digits, ok := strings.CutPrefix(s, "0x")
+	if !ok || digits == "" {
+		return 0, fmt.Errorf("expected 0x-prefixed hex, got %q", s)
+	}
+	return strconv.ParseUint(digits, 16, 64)
+}
+
+func parseHexBytes(s string) ([]byte, error) {
+	digits, ok := strings.CutPrefix(s, "0x")
+	if !ok {
+		return nil, fmt.Errorf("expected 0x-prefixed hex, got %q", s)
+	}
+	return hex.DecodeString(digits)
+}
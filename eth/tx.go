@@ -0,0 +1,174 @@
+package eth
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/sha3"
+)
+
+// Types of transactions accepted by DecodeTransaction (EIP-2718)
+const (
+	TxLegacy     = 0
+	TxAccessList = 1
+	TxDynamicFee = 2
+)
+
+// Transaction is a decoded signed transaction. Gas fields are read but play no part in the ledger.
+type Transaction struct {
+	Type    int
+	ChainID *big.Int
+	Nonce   uint64
+	// To is empty for contract creations
+	To    string
+	Value *big.Int
+	Data  []byte
+	// From is recovered from the signature
+	From string
+	// Hash is the keccak-256 of the raw transaction, as Ethereum clients compute it
+	Hash string
+}
+
+var (
+	ErrUnsupportedTx = errors.New("unsupported transaction type")
+	ErrNoChainID     = errors.New("transaction is not replay-protected (EIP-155)")
+	ErrBadSignature  = errors.New("invalid signature")
+)
+
+// fieldCount of each transaction type, signature included; the signature is the last 3 fields
+var fieldCount = map[int]int{TxLegacy: 9, TxAccessList: 11, TxDynamicFee: 12}
+
+// DecodeTransaction decodes a raw signed transaction: legacy with an EIP-155 chain ID,
+// EIP-2930 (type 1) or EIP-1559 (type 2), and recovers its sender.
+func DecodeTransaction(raw []byte) (*Transaction, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("%w: empty transaction", errRLP)
+	}
+	tx := &Transaction{Type: TxLegacy, Hash: hexBytes(keccak256(raw))}
+	payload := raw
+	// Legacy transactions are a bare RLP list (0xc0 and up); 0x00 is not a typed legacy transaction,
+	// whose hash would differ from the one of Ethereum
+	if raw[0] < 0xc0 {
+		tx.Type = int(raw[0])
+		payload = raw[1:]
+		if tx.Type != TxAccessList && tx.Type != TxDynamicFee {
+			return nil, fmt.Errorf("%w: %d", ErrUnsupportedTx, tx.Type)
+		}
+	}
+
+	item, err := decodeRLP(payload)
+	if err != nil {
+		return nil, err
+	}
+	if !item.isList || len(item.list) != fieldCount[tx.Type] {
+		return nil, fmt.Errorf("%w: expected a list of %d fields", errRLP, fieldCount[tx.Type])
+	}
+	fields := item.list
+
+	// Legacy: nonce, gasPrice, gas, to, value, data, v, r, s
+	// Type 1: chainId, nonce, gasPrice, gas, to, value, data, accessList, yParity, r, s
+	// Type 2: chainId, nonce, maxPriorityFeePerGas, maxFeePerGas, gas, to, value, data, accessList, yParity, r, s
+	var nonce, to, value, data rlpItem
+	var recovery *big.Int
+	if tx.Type == TxLegacy {
+		nonce, to, value, data = fields[0], fields[3], fields[4], fields[5]
+		v, err := fields[6].bigInt()
+		if err != nil {
+			return nil, err
+		}
+		// v = chainId * 2 + 35 + recovery id
+		if v.Cmp(big.NewInt(35)) < 0 {
+			return nil, ErrNoChainID
+		}
+		v.Sub(v, big.NewInt(35))
+		recovery = new(big.Int).And(v, big.NewInt(1))
+		tx.ChainID = v.Rsh(v, 1)
+	} else {
+		n := len(fields)
+		nonce, to, value, data = fields[1], fields[n-7], fields[n-6], fields[n-5]
+		if tx.ChainID, err = fields[0].bigInt(); err != nil {
+			return nil, err
+		}
+		if recovery, err = fields[n-3].bigInt(); err != nil {
+			return nil, err
+		}
+	}
+
+	if tx.Nonce, err = nonce.uint64(); err != nil {
+		return nil, err
+	}
+	if tx.Value, err = value.bigInt(); err != nil {
+		return nil, err
+	}
+	if to.isList || data.isList || (len(to.str) != 0 && len(to.str) != 20) {
+		return nil, fmt.Errorf("%w: invalid to or data field", errRLP)
+	}
+	if len(to.str) == 20 {
+		tx.To = hexBytes(to.str)
+	}
+	tx.Data = data.str
+
+	if tx.From, err = recoverSender(signingHash(tx, fields), recovery, fields[len(fields)-2], fields[len(fields)-1]); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// signingHash is the hash the sender signed: the fields without the signature, plus the chain ID
+// and two empty values for legacy transactions (EIP-155).
+func signingHash(tx *Transaction, fields []rlpItem) []byte {
+	var items [][]byte
+	for _, f := range fields[:len(fields)-3] {
+		items = append(items, f.raw)
+	}
+	if tx.Type == TxLegacy {
+		items = append(items, encodeRLPInt(tx.ChainID), []byte{0x80}, []byte{0x80})
+		return keccak256(encodeRLPList(items...))
+	}
+	return keccak256(append([]byte{byte(tx.Type)}, encodeRLPList(items...)...))
+}
+
+// recoverSender returns the address of the key that produced the signature r, s of hash.
+// High s values are rejected like Ethereum does since Homestead, so signatures are not malleable.
+func recoverSender(hash []byte, recovery *big.Int, r, s rlpItem) (string, error) {
+	if !recovery.IsUint64() || recovery.Uint64() > 1 || r.isList || s.isList || len(r.str) > 32 || len(s.str) > 32 {
+		return "", ErrBadSignature
+	}
+	var sScalar secp256k1.ModNScalar
+	if overflow := sScalar.SetByteSlice(s.str); overflow || sScalar.IsOverHalfOrder() {
+		return "", ErrBadSignature
+	}
+
+	// Compact format of the secp256k1 package: recovery code, then r and s left-padded to 32 bytes
+	sig := make([]byte, 65)
+	sig[0] = 27 + byte(recovery.Uint64())
+	copy(sig[33-len(r.str):33], r.str)
+	copy(sig[65-len(s.str):], s.str)
+	pub, _, err := ecdsa.RecoverCompact(sig, hash)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	return PublicKeyAddress(pub), nil
+}
+
+// PublicKeyAddress returns the Ethereum address of a public key: the last 20 bytes of the
+// keccak-256 of its uncompressed form, in lowercase like every address of the ledger.
+func PublicKeyAddress(pub *secp256k1.PublicKey) string {
+	return hexBytes(keccak256(pub.SerializeUncompressed()[1:])[12:])
+}
+
+func keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+func hexBytes(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
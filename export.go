@@ -0,0 +1,95 @@
+package main
+
+import (
+	"btp-transfer/export"
+	"btp-transfer/ledger"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// runExport writes the ledger entries or a balance snapshot to stdout or a file.
+func runExport(args []string) error {
+	opts := export.Options{}
+	var since, until, output string
+
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.StringVar(&opts.Format, "format", export.FormatCSV, "csv or jsonl")
+	fs.StringVar(&opts.Type, "type", export.TypeEntries, "entries (transfer history) or balances (snapshot at --until)")
+	fs.StringVar(&since, "since", "", "first entry time, inclusive (RFC 3339 or 2006-01-02)")
+	fs.StringVar(&until, "until", "", "last entry time, exclusive (RFC 3339 or 2006-01-02)")
+	fs.StringVar(&opts.Wallet, "wallet", "", "only entries sent or received by this address")
+	fs.StringVar(&output, "output", "", "file the export is written to instead of stdout")
+	cfg, err := parseConfig(fs, args, nil)
+	if err != nil {
+		return err
+	}
+	for _, bound := range []struct {
+		value string
+		dst   *time.Time
+	}{{since, &opts.Since}, {until, &opts.Until}} {
+		if bound.value == "" {
+			continue
+		}
+		if *bound.dst, err = export.ParseTime(bound.value); err != nil {
+			return err
+		}
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	store, closeStore, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+	pg, ok := store.(*ledger.PostgresStore)
+	if !ok {
+		return fmt.Errorf("export needs the postgres store")
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	rows, err := export.Write(context.Background(), pg.DB, w, opts)
+	if err != nil {
+		return err
+	}
+	if output != "" {
+		fmt.Fprintf(os.Stderr, "exported %d rows to %s\n", rows, output)
+	}
+	return nil
+}
+
+// runCheckExport verifies an export file against its trailer.
+func runCheckExport(args []string) error {
+	fs := flag.NewFlagSet("check-export", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: check-export <file>")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	trailer, err := export.Check(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	fmt.Printf("%s: ok, %d rows, sha256 %s\n", fs.Arg(0), trailer.Rows, trailer.SHA256)
+	return nil
+}
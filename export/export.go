@@ -0,0 +1,230 @@
+// Package export writes the ledger history (ledger_entries) or balance snapshots as CSV or JSONL.
+//
+// Rows are read through a server-side cursor, a batch at a time, so memory stays bounded whatever
+// the size of the export. The last line is a trailer with the number of rows and the SHA-256 of
+// everything before it, so a truncated or edited file is detected by Check.
+package export
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Formats of an export
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// Types of an export
+const (
+	// TypeEntries exports ledger entries: transfers, and the mints and opening balances they start from
+	TypeEntries = "entries"
+	// TypeBalances exports the balance of every wallet at the end of the time range, computed from the entries
+	TypeBalances = "balances"
+)
+
+// DefaultFetchSize is the number of rows fetched from the cursor at a time.
+const DefaultFetchSize = 1000
+
+// Options select what is exported and how.
+type Options struct {
+	Format string
+	Type   string
+	// Since and Until bound created_at of the entries (Since inclusive, Until exclusive); zero means unbounded.
+	// A balance snapshot is taken at Until, Since must be zero.
+	Since time.Time
+	Until time.Time
+	// Wallet keeps the entries sent or received by one address, or the balance of that address
+	Wallet string
+	// FetchSize overrides DefaultFetchSize
+	FetchSize int
+	// AfterBatch is called after every batch has been written out, e.g. to flush an HTTP response
+	AfterBatch func() error
+}
+
+// Validate reports the first invalid option.
+func (o *Options) Validate() error {
+	if o.Format != FormatCSV && o.Format != FormatJSONL {
+		return fmt.Errorf("format must be %q or %q, got: %q", FormatCSV, FormatJSONL, o.Format)
+	}
+	if o.Type != TypeEntries && o.Type != TypeBalances {
+		return fmt.Errorf("type must be %q or %q, got: %q", TypeEntries, TypeBalances, o.Type)
+	}
+	if o.Type == TypeBalances && !o.Since.IsZero() {
+		return fmt.Errorf("a balance snapshot is taken at until, since is not supported")
+	}
+	if !o.Since.IsZero() && !o.Until.IsZero() && !o.Since.Before(o.Until) {
+		return fmt.Errorf("since (%s) must be before until (%s)", o.Since.Format(time.RFC3339), o.Until.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// ParseTime reads a filter bound, either RFC 3339 or a date (midnight UTC).
+func ParseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected RFC 3339 (2006-01-02T15:04:05Z) or a date (2006-01-02)", s)
+	}
+	return t, nil
+}
+
+// Write exports what opts select from db to w, ending with the trailer, and returns the number of rows.
+// Everything is read from one snapshot. When it fails, the trailer is not written.
+func Write(ctx context.Context, db *sql.DB, w io.Writer, opts Options) (int64, error) {
+	if err := opts.Validate(); err != nil {
+		return 0, err
+	}
+	fetchSize := opts.FetchSize
+	if fetchSize <= 0 {
+		fetchSize = DefaultFetchSize
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The end of the snapshot, when no upper bound is given
+	var asOf time.Time
+	if err := tx.QueryRowContext(ctx, "SELECT now()").Scan(&asOf); err != nil {
+		return 0, fmt.Errorf("failed to read snapshot time: %w", err)
+	}
+	if !opts.Until.IsZero() {
+		asOf = opts.Until
+	}
+
+	query, args := opts.query()
+	if _, err := tx.ExecContext(ctx, "DECLARE export_cursor NO SCROLL CURSOR FOR "+query, args...); err != nil {
+		return 0, fmt.Errorf("failed to open cursor: %w", err)
+	}
+
+	enc := newEncoder(w, opts.Format, opts.columns())
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM export_cursor", fetchSize)
+	for {
+		rows, err := tx.QueryContext(ctx, fetch)
+		if err != nil {
+			return enc.rows, fmt.Errorf("failed to fetch rows: %w", err)
+		}
+		fetched := 0
+		for rows.Next() {
+			rec, err := opts.scan(rows, asOf)
+			if err != nil {
+				rows.Close()
+				return enc.rows, fmt.Errorf("failed to read row: %w", err)
+			}
+			if err := enc.write(rec); err != nil {
+				rows.Close()
+				return enc.rows, err
+			}
+			fetched++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return enc.rows, fmt.Errorf("failed to fetch rows: %w", err)
+		}
+
+		if err := enc.flush(); err != nil {
+			return enc.rows, err
+		}
+		if opts.AfterBatch != nil {
+			if err := opts.AfterBatch(); err != nil {
+				return enc.rows, err
+			}
+		}
+		if fetched < fetchSize {
+			break
+		}
+	}
+
+	if err := enc.close(); err != nil {
+		return enc.rows, err
+	}
+	return enc.rows, nil
+}
+
+// query returns the SQL of the cursor and its arguments.
+func (o *Options) query() (string, []any) {
+	var where []string
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(condition, len(args)))
+	}
+
+	if o.Type == TypeBalances {
+		// Balances are rebuilt from the entries, so they match the entries exported for the same range
+		if !o.Until.IsZero() {
+			add("created_at < $%d", o.Until)
+		}
+		filter := ""
+		if len(where) > 0 {
+			filter = " AND " + strings.Join(where, " AND ")
+		}
+		query := `
+			SELECT address, SUM(amount)::bigint
+			FROM (
+				SELECT to_address AS address, amount FROM ledger_entries WHERE to_address IS NOT NULL` + filter + `
+				UNION ALL
+				SELECT from_address, -amount FROM ledger_entries WHERE from_address IS NOT NULL` + filter + `
+			) AS movements`
+		if o.Wallet != "" {
+			args = append(args, strings.ToLower(o.Wallet))
+			query += fmt.Sprintf(" WHERE address = $%d", len(args))
+		}
+		return query + " GROUP BY address ORDER BY address", args
+	}
+
+	if !o.Since.IsZero() {
+		add("created_at >= $%d", o.Since)
+	}
+	if !o.Until.IsZero() {
+		add("created_at < $%d", o.Until)
+	}
+	if o.Wallet != "" {
+		add("(from_address = $%[1]d OR to_address = $%[1]d)", strings.ToLower(o.Wallet))
+	}
+	query := "SELECT id, created_at, kind, from_address, to_address, amount FROM ledger_entries"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	return query + " ORDER BY id", args
+}
+
+// columns returns the CSV header.
+func (o *Options) columns() []string {
+	if o.Type == TypeBalances {
+		return []string{"address", "balance", "as_of"}
+	}
+	return []string{"id", "created_at", "kind", "from", "to", "amount"}
+}
+
+// scan reads one row of the cursor.
+func (o *Options) scan(rows *sql.Rows, asOf time.Time) (record, error) {
+	if o.Type == TypeBalances {
+		b := balanceRecord{AsOf: asOf.UTC()}
+		err := rows.Scan(&b.Address, &b.Balance)
+		return &b, err
+	}
+	var e entryRecord
+	var from, to sql.NullString
+	if err := rows.Scan(&e.ID, &e.CreatedAt, &e.Kind, &from, &to, &e.Amount); err != nil {
+		return nil, err
+	}
+	e.CreatedAt = e.CreatedAt.UTC()
+	if from.Valid {
+		e.From = &from.String
+	}
+	if to.Valid {
+		e.To = &to.String
+	}
+	return &e, nil
+}
@@ -0,0 +1,152 @@
+package export
+
+import (
+	"btp-transfer/testdb"
+	"bytes"
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// encode writes records the way Write does
+func encode(t *testing.T, format string, records ...record) string {
+	var buf bytes.Buffer
+	opts := Options{Format: format, Type: TypeEntries}
+	enc := newEncoder(&buf, format, opts.columns())
+	for _, rec := range records {
+		if err := enc.write(rec); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+	if err := enc.close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	return buf.String()
+}
+
+func sampleEntries() []record {
+	from, to := "0xa", "0xb"
+	at := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	return []record{
+		&entryRecord{ID: 1, CreatedAt: at, Kind: "mint", To: &from, Amount: 100},
+		&entryRecord{ID: 2, CreatedAt: at.Add(time.Second), Kind: "transfer", From: &from, To: &to, Amount: 30},
+	}
+}
+
+// 1. Formats
+// Goal: CSV has a header and empty fields for missing addresses, JSONL nulls; both end with a trailer.
+func TestEncoder_Formats(t *testing.T) {
+	csv := encode(t, FormatCSV, sampleEntries()...)
+	lines := strings.Split(strings.TrimSuffix(csv, "\n"), "\n")
+	if len(lines) != 4 || lines[0] != "id,created_at,kind,from,to,amount" || lines[1] != "1,2026-10-18T12:00:00Z,mint,,0xa,100" {
+		t.Errorf("Unexpected CSV:\n%s", csv)
+	}
+	if !strings.HasPrefix(lines[3], "# trailer rows=2 sha256=") {
+		t.Errorf("Expected a CSV trailer, got %q", lines[3])
+	}
+
+	jsonl := encode(t, FormatJSONL, sampleEntries()...)
+	lines = strings.Split(strings.TrimSuffix(jsonl, "\n"), "\n")
+	if len(lines) != 3 || lines[0] != `{"id":1,"created_at":"2026-10-18T12:00:00Z","kind":"mint","from":null,"to":"0xa","amount":100}` {
+		t.Errorf("Unexpected JSONL:\n%s", jsonl)
+	}
+	if !strings.HasPrefix(lines[2], `{"trailer":{"rows":2,"sha256":"`) {
+		t.Errorf("Expected a JSONL trailer, got %q", lines[2])
+	}
+}
+
+// 2. Trailer checks
+// Goal: Intact exports pass, truncated or edited ones are detected.
+func TestCheck(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatJSONL} {
+		full := encode(t, format, sampleEntries()...)
+		if trailer, err := Check(strings.NewReader(full)); err != nil || trailer.Rows != 2 {
+			t.Errorf("%s: expected a valid export of 2 rows, got %+v (%v)", format, trailer, err)
+		}
+		if _, err := Check(strings.NewReader(encode(t, format))); err != nil {
+			t.Errorf("%s: expected an empty export to be valid, got: %v", format, err)
+		}
+
+		lines := strings.SplitAfter(full, "\n")
+		truncated := strings.Join(lines[:len(lines)-2], "")
+		if _, err := Check(strings.NewReader(truncated)); !errors.Is(err, ErrNoTrailer) {
+			t.Errorf("%s: expected ErrNoTrailer for a truncated export, got: %v", format, err)
+		}
+		if _, err := Check(strings.NewReader(strings.TrimSuffix(full, "\n"))); !errors.Is(err, ErrNoTrailer) {
+			t.Errorf("%s: expected ErrNoTrailer for a cut trailer, got: %v", format, err)
+		}
+		edited := strings.Replace(full, "30", "31", 1)
+		if _, err := Check(strings.NewReader(edited)); !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("%s: expected ErrChecksumMismatch for an edited export, got: %v", format, err)
+		}
+		dropped := lines[0] + strings.Join(lines[2:], "")
+		if _, err := Check(strings.NewReader(dropped)); !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("%s: expected ErrChecksumMismatch for a dropped row, got: %v", format, err)
+		}
+	}
+}
+
+// 3. Query string
+// Goal: Defaults apply and invalid combinations are rejected before touching the database.
+func TestParseOptions(t *testing.T) {
+	opts, err := parseOptions(url.Values{"since": {"2026-10-01"}, "wallet": {"0xA"}})
+	if err != nil || opts.Format != FormatCSV || opts.Type != TypeEntries || opts.Since.Day() != 1 {
+		t.Errorf("Unexpected options %+v (%v)", opts, err)
+	}
+	for _, q := range []string{
+		"format=xml",
+		"type=wallets",
+		"since=yesterday",
+		"since=2026-10-02&until=2026-10-01",
+		"type=balances&since=2026-10-01",
+	} {
+		values, _ := url.ParseQuery(q)
+		if _, err := parseOptions(values); err == nil {
+			t.Errorf("Expected %q to be rejected", q)
+		}
+	}
+}
+
+// 4. Streaming from PostgreSQL
+// Goal: Batches smaller than the result still export every row once, filters and snapshots apply.
+func TestWrite(t *testing.T) {
+	db := testdb.Migrated(t, "btp_export_test", "ledger_entries", "wallet_shards", "wallets")
+	_, err := db.Exec(`
+		INSERT INTO wallets (address, balance) VALUES ('0xa', 50), ('0xb', 30), ('0xc', 20);
+		INSERT INTO ledger_entries (kind, from_address, to_address, amount) VALUES
+			('mint', NULL, '0xa', 100), ('transfer', '0xa', '0xb', 30), ('transfer', '0xa', '0xc', 20);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert entries: %v", err)
+	}
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	batches := 0
+	rows, err := Write(ctx, db, &buf, Options{Format: FormatJSONL, Type: TypeEntries, FetchSize: 2, AfterBatch: func() error {
+		batches++
+		return nil
+	}})
+	if err != nil || rows != 3 || batches != 2 {
+		t.Fatalf("Expected 3 rows in 2 batches, got %d rows in %d batches (%v)", rows, batches, err)
+	}
+	if _, err := Check(&buf); err != nil {
+		t.Errorf("Check failed: %v", err)
+	}
+
+	buf.Reset()
+	if rows, err := Write(ctx, db, &buf, Options{Format: FormatCSV, Type: TypeEntries, Wallet: "0xB"}); err != nil || rows != 1 {
+		t.Errorf("Expected 1 entry of 0xb, got %d (%v)", rows, err)
+	}
+
+	buf.Reset()
+	if rows, err := Write(ctx, db, &buf, Options{Format: FormatCSV, Type: TypeBalances}); err != nil || rows != 3 {
+		t.Fatalf("Expected 3 balances, got %d (%v)", rows, err)
+	}
+	if !strings.Contains(buf.String(), "\n0xa,50,") {
+		t.Errorf("Expected 0xa to hold 50, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,224 @@
+package export
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// record is one exported row.
+type record interface {
+	csvFields() []string
+}
+
+// entryRecord is one ledger entry; From is null for mints and opening balances, To for burns.
+type entryRecord struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Kind      string    `json:"kind"`
+	From      *string   `json:"from"`
+	To        *string   `json:"to"`
+	Amount    int64     `json:"amount"`
+}
+
+func (e *entryRecord) csvFields() []string {
+	optional := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	return []string{
+		strconv.FormatInt(e.ID, 10),
+		e.CreatedAt.Format(time.RFC3339Nano),
+		e.Kind,
+		optional(e.From),
+		optional(e.To),
+		strconv.FormatInt(e.Amount, 10),
+	}
+}
+
+// balanceRecord is the balance of a wallet at AsOf.
+type balanceRecord struct {
+	Address string    `json:"address"`
+	Balance int64     `json:"balance"`
+	AsOf    time.Time `json:"as_of"`
+}
+
+func (b *balanceRecord) csvFields() []string {
+	return []string{b.Address, strconv.FormatInt(b.Balance, 10), b.AsOf.Format(time.RFC3339Nano)}
+}
+
+// Trailer is the last line of an export.
+type Trailer struct {
+	Rows   int64  `json:"rows"`
+	SHA256 string `json:"sha256"`
+}
+
+// Trailer lines: a comment for CSV, an object with a single "trailer" key for JSONL
+const csvTrailerPrefix = "# trailer "
+
+type jsonTrailer struct {
+	Trailer *Trailer `json:"trailer"`
+}
+
+// encoder writes records in one format and hashes everything it writes, except the trailer.
+type encoder struct {
+	format string
+	out    io.Writer
+	hash   hash.Hash
+	buf    *bufio.Writer
+	csv    *csv.Writer
+	rows   int64
+	err    error
+}
+
+// newEncoder returns an encoder writing to w; a CSV export starts with the header of columns.
+func newEncoder(w io.Writer, format string, columns []string) *encoder {
+	e := &encoder{format: format, out: w, hash: sha256.New()}
+	e.buf = bufio.NewWriter(io.MultiWriter(w, e.hash))
+	if format == FormatCSV {
+		e.csv = csv.NewWriter(e.buf)
+		e.err = e.csv.Write(columns)
+	}
+	return e
+}
+
+func (e *encoder) write(rec record) error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.csv != nil {
+		e.err = e.csv.Write(rec.csvFields())
+	} else {
+		var line []byte
+		line, e.err = json.Marshal(rec)
+		if e.err == nil {
+			line = append(line, '\n')
+			_, e.err = e.buf.Write(line)
+		}
+	}
+	if e.err != nil {
+		return fmt.Errorf("failed to write row: %w", e.err)
+	}
+	e.rows++
+	return nil
+}
+
+// flush writes out what is buffered.
+func (e *encoder) flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.csv != nil {
+		e.csv.Flush()
+		e.err = e.csv.Error()
+	}
+	if e.err == nil {
+		e.err = e.buf.Flush()
+	}
+	if e.err != nil {
+		return fmt.Errorf("failed to write rows: %w", e.err)
+	}
+	return nil
+}
+
+// close flushes the rows and appends the trailer.
+func (e *encoder) close() error {
+	if err := e.flush(); err != nil {
+		return err
+	}
+	trailer := Trailer{Rows: e.rows, SHA256: hex.EncodeToString(e.hash.Sum(nil))}
+	line, err := formatTrailer(e.format, trailer)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(e.out, line); err != nil {
+		return fmt.Errorf("failed to write trailer: %w", err)
+	}
+	return nil
+}
+
+func formatTrailer(format string, t Trailer) (string, error) {
+	if format == FormatCSV {
+		return fmt.Sprintf("%srows=%d sha256=%s\n", csvTrailerPrefix, t.Rows, t.SHA256), nil
+	}
+	line, err := json.Marshal(jsonTrailer{Trailer: &t})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode trailer: %w", err)
+	}
+	return string(line) + "\n", nil
+}
+
+// parseTrailer reads a trailer line of either format.
+func parseTrailer(line string) (Trailer, bool) {
+	var t Trailer
+	if rest, ok := strings.CutPrefix(line, csvTrailerPrefix); ok {
+		_, err := fmt.Sscanf(rest, "rows=%d sha256=%s", &t.Rows, &t.SHA256)
+		return t, err == nil
+	}
+	var jt jsonTrailer
+	if err := json.Unmarshal([]byte(line), &jt); err != nil || jt.Trailer == nil {
+		return t, false
+	}
+	return *jt.Trailer, true
+}
+
+// Errors of Check
+var (
+	ErrNoTrailer        = errors.New("export has no trailer, it is truncated")
+	ErrChecksumMismatch = errors.New("export does not match its trailer checksum")
+)
+
+// Check reads an export and verifies it against its trailer, which it returns.
+// It reads line by line, so files of any size can be checked.
+func Check(r io.Reader) (Trailer, error) {
+	br := bufio.NewReader(r)
+	h := sha256.New()
+	var lines int64
+	var first, last string
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return Trailer{}, err
+		}
+		if line != "" {
+			// A line is hashed once the next one shows it wasn't the trailer
+			if last != "" {
+				h.Write([]byte(last))
+				lines++
+			}
+			if first == "" {
+				first = line
+			}
+			last = line
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	// The trailer ends with a newline, a file cut right before it is truncated too
+	trailer, ok := parseTrailer(strings.TrimSuffix(last, "\n"))
+	if !ok || !strings.HasSuffix(last, "\n") {
+		return Trailer{}, ErrNoTrailer
+	}
+	rows := lines
+	if lines > 0 && !strings.HasPrefix(first, "{") {
+		// The header of a CSV export is not a row
+		rows--
+	}
+	if hex.EncodeToString(h.Sum(nil)) != trailer.SHA256 || rows != trailer.Rows {
+		return trailer, ErrChecksumMismatch
+	}
+	return trailer, nil
+}
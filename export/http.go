@@ -0,0 +1,117 @@
+package export
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// contentTypes of the formats
+var contentTypes = map[string]string{
+	FormatCSV:   "text/csv; charset=utf-8",
+	FormatJSONL: "application/x-ndjson",
+}
+
+// Handler streams an export selected by the query string:
+// format (csv, default, or jsonl), type (entries, default, or balances), since, until and wallet.
+//
+// The response is flushed after every batch and the write deadline of the server is pushed back by
+// writeTimeout each time, so long exports are not cut by it. An export failing halfway aborts the
+// response; the client sees a broken transfer, and the file has no trailer.
+func Handler(db *sql.DB, writeTimeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		opts, err := parseOptions(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rc := http.NewResponseController(w)
+		extendDeadline := func() error {
+			if writeTimeout <= 0 {
+				return nil
+			}
+			err := rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if errors.Is(err, http.ErrNotSupported) {
+				return nil
+			}
+			return err
+		}
+		opts.AfterBatch = func() error {
+			if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+			return extendDeadline()
+		}
+		if err := extendDeadline(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		filename := fmt.Sprintf("btp-%s-%s.%s", opts.Type, time.Now().UTC().Format("20060102T150405Z"), opts.Format)
+		w.Header().Set("Content-Type", contentTypes[opts.Format])
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		start := time.Now()
+		out := &startedWriter{w: w}
+		rows, err := Write(r.Context(), db, out, opts)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "export failed", "type", opts.Type, "format", opts.Format, "rows", rows, "error", err)
+			if !out.started {
+				w.Header().Del("Content-Disposition")
+				http.Error(w, "export failed", http.StatusInternalServerError)
+				return
+			}
+			panic(http.ErrAbortHandler)
+		}
+		slog.InfoContext(r.Context(), "export", "type", opts.Type, "format", opts.Format, "rows", rows,
+			"duration_ms", float64(time.Since(start).Microseconds())/1000)
+	})
+}
+
+// parseOptions reads the export options from a query string.
+func parseOptions(q url.Values) (Options, error) {
+	opts := Options{Format: FormatCSV, Type: TypeEntries, Wallet: q.Get("wallet")}
+	if v := q.Get("format"); v != "" {
+		opts.Format = v
+	}
+	if v := q.Get("type"); v != "" {
+		opts.Type = v
+	}
+	for _, bound := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &opts.Since}, {"until", &opts.Until}} {
+		if v := q.Get(bound.name); v != "" {
+			t, err := ParseTime(v)
+			if err != nil {
+				return opts, fmt.Errorf("%s: %w", bound.name, err)
+			}
+			*bound.dst = t
+		}
+	}
+	return opts, opts.Validate()
+}
+
+// startedWriter tells whether anything was written, after which the status can't change anymore.
+type startedWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (s *startedWriter) Write(p []byte) (int, error) {
+	s.started = true
+	return s.w.Write(p)
+}
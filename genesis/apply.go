@@ -0,0 +1,133 @@
+package genesis
+
+import (
+	"btp-transfer/ledger"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// MismatchError is returned when the database was started from a different genesis.
+type MismatchError struct {
+	Recorded string
+	Given    string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("database was initialized with genesis %s, but genesis %s was given", e.Recorded, e.Given)
+}
+
+// Apply writes the genesis into a PostgreSQL database migrated to the latest schema.
+// The first call creates the wallets and records the genesis hash (and an audit event) in one
+// transaction and returns true; later calls with the same genesis do nothing, a different genesis
+// returns *MismatchError.
+//
+// Allocations are recorded as minted in the ledger entries. Wallets that already exist are left
+// untouched, so a database seeded before the genesis table existed is adopted without minting
+// its tokens a second time.
+func Apply(ctx context.Context, db *sql.DB, g *Genesis) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Several instances may start at once, only one of them applies the genesis
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE genesis IN EXCLUSIVE MODE"); err != nil {
+		return false, fmt.Errorf("failed to lock genesis: %w", err)
+	}
+
+	hash := g.Hash()
+	var recorded string
+	err = tx.QueryRowContext(ctx, "SELECT hash FROM genesis").Scan(&recorded)
+	if err == nil {
+		if recorded != hash {
+			return false, &MismatchError{Recorded: recorded, Given: hash}
+		}
+		return false, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, fmt.Errorf("failed to read genesis: %w", err)
+	}
+
+	insert := func(address string, balance int64) error {
+		_, err := ledger.MintWallet(ctx, tx, address, balance)
+		return err
+	}
+	for _, a := range g.Allocations {
+		if err := insert(a.Address, a.Balance); err != nil {
+			return false, err
+		}
+	}
+	for _, address := range g.Wallets {
+		if err := insert(address, 0); err != nil {
+			return false, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO genesis (hash, token_name, token_symbol, decimals, max_supply, supply)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, hash, g.Token.Name, g.Token.Symbol, g.Token.Decimals, g.Token.MaxSupply, g.Supply())
+	if err != nil {
+		return false, fmt.Errorf("failed to record genesis: %w", err)
+	}
+	err = ledger.RecordAudit(ctx, tx, ledger.OpApplyGenesis, map[string]any{
+		"hash":    hash,
+		"symbol":  g.Token.Symbol,
+		"wallets": len(g.Allocations) + len(g.Wallets),
+		"supply":  g.Supply(),
+	}, nil, nil)
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit genesis: %w", err)
+	}
+	return true, nil
+}
+
+// Record is what the database keeps of the genesis it was started from.
+type Record struct {
+	Hash   string
+	Supply int64
+}
+
+// Recorded returns the genesis recorded by Apply, nil if there is none
+// (e.g. a database seeded before the genesis table existed).
+func Recorded(ctx context.Context, db *sql.DB) (*Record, error) {
+	var r Record
+	err := db.QueryRowContext(ctx, "SELECT hash, supply FROM genesis").Scan(&r.Hash, &r.Supply)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read genesis: %w", err)
+	}
+	return &r, nil
+}
+
+// Seed creates the wallets of the genesis in any store, skipping the ones that exist.
+// It doesn't record the genesis; use Apply for PostgreSQL.
+func Seed(ctx context.Context, store ledger.Store, g *Genesis) error {
+	create := func(address string, balance int64) error {
+		err := store.CreateWallet(ctx, address, balance)
+		if err != nil && !errors.Is(err, ledger.ErrWalletExists) {
+			return fmt.Errorf("failed to create wallet %s: %w", address, err)
+		}
+		return nil
+	}
+	for _, a := range g.Allocations {
+		if err := create(a.Address, a.Balance); err != nil {
+			return err
+		}
+	}
+	for _, address := range g.Wallets {
+		if err := create(address, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+// Package genesis describes the initial state of the ledger: token metadata and the wallets
+// that exist before the first transfer.
+//
+// A genesis is read from a JSON or YAML file, validated as a whole before anything is written
+// and applied once. Its hash is recorded in the database, so starting the server later with a
+// different genesis file is reported instead of silently minting or ignoring tokens.
+package genesis
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Token describes the token held in the ledger.
+type Token struct {
+	Name     string `json:"name" yaml:"name"`
+	Symbol   string `json:"symbol" yaml:"symbol"`
+	Decimals int    `json:"decimals" yaml:"decimals"`
+	// MaxSupply caps the sum of all allocations, 0 means no cap
+	MaxSupply int64 `json:"max_supply" yaml:"max_supply"`
+}
+
+// Allocation is the starting balance of a wallet.
+type Allocation struct {
+	Address string `json:"address" yaml:"address"`
+	Balance int64  `json:"balance" yaml:"balance"`
+}
+
+// Genesis is the initial state of the ledger.
+type Genesis struct {
+	Token       Token        `json:"token" yaml:"token"`
+	Allocations []Allocation `json:"allocations" yaml:"allocations"`
+	// Wallets are created empty, e.g. accounts known up front that will only receive tokens
+	Wallets []string `json:"wallets,omitempty" yaml:"wallets,omitempty"`
+}
+
+// Built-in genesis used when no file is configured
+const (
+	DefaultAddress = "0x0000000000000000000000000000000000000000"
+	DefaultBalance = 1000000
+)
+
+// Default returns the genesis the project has always started with:
+// one wallet holding 1,000,000 BTP tokens.
+func Default() *Genesis {
+	return &Genesis{
+		Token:       Token{Name: "BTP Token", Symbol: "BTP", Decimals: 0},
+		Allocations: []Allocation{{Address: DefaultAddress, Balance: DefaultBalance}},
+	}
+}
+
+// Load reads a genesis file (YAML for .yaml/.yml, JSON otherwise) and validates it.
+// Addresses are lowercased the same way the API does it.
+func Load(path string) (*Genesis, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read genesis file: %w", err)
+	}
+
+	var g Genesis
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalWithOptions(content, &g, yaml.Strict())
+	default:
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&g)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse genesis file %s: %w", path, err)
+	}
+
+	for i := range g.Allocations {
+		g.Allocations[i].Address = strings.ToLower(g.Allocations[i].Address)
+	}
+	for i := range g.Wallets {
+		g.Wallets[i] = strings.ToLower(g.Wallets[i])
+	}
+
+	if err := g.Validate(); err != nil {
+		return nil, fmt.Errorf("genesis file %s: %w", path, err)
+	}
+	return &g, nil
+}
+
+var addressFormat = regexp.MustCompile(`^0x[0-9a-f]{40}$`)
+
+// Validate reports every problem of the genesis at once, so a file can be fixed in one go.
+func (g *Genesis) Validate() error {
+	var problems []error
+
+	if g.Token.Name == "" || g.Token.Symbol == "" {
+		problems = append(problems, errors.New("token name and symbol are required"))
+	}
+	if g.Token.Decimals < 0 || g.Token.Decimals > 18 {
+		problems = append(problems, fmt.Errorf("token decimals must be between 0 and 18, got %d", g.Token.Decimals))
+	}
+	if g.Token.MaxSupply < 0 {
+		problems = append(problems, fmt.Errorf("token max_supply must not be negative, got %d", g.Token.MaxSupply))
+	}
+
+	seen := map[string]bool{}
+	checkAddress := func(address string) {
+		if !addressFormat.MatchString(address) {
+			problems = append(problems, fmt.Errorf("invalid address %q (expected 0x followed by 40 hex digits)", address))
+		}
+		if seen[address] {
+			problems = append(problems, fmt.Errorf("duplicate address %s", address))
+		}
+		seen[address] = true
+	}
+
+	var total int64
+	for _, a := range g.Allocations {
+		checkAddress(a.Address)
+		if a.Balance < 0 {
+			problems = append(problems, fmt.Errorf("negative balance %d for %s", a.Balance, a.Address))
+			continue
+		}
+		if total > math.MaxInt64-a.Balance {
+			problems = append(problems, errors.New("total of allocations overflows int64"))
+			break
+		}
+		total += a.Balance
+	}
+	for _, address := range g.Wallets {
+		checkAddress(address)
+	}
+
+	if g.Token.MaxSupply > 0 && total > g.Token.MaxSupply {
+		problems = append(problems, fmt.Errorf("allocations total %d exceeds max_supply %d", total, g.Token.MaxSupply))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid genesis: %w", errors.Join(problems...))
+	}
+	return nil
+}
+
+// Supply returns the total of all allocations.
+func (g *Genesis) Supply() int64 {
+	var total int64
+	for _, a := range g.Allocations {
+		total += a.Balance
+	}
+	return total
+}
+
+// Hash identifies the content of the genesis. It doesn't depend on the file format
+// or on the order of allocations and wallets.
+func (g *Genesis) Hash() string {
+	canonical := Genesis{Token: g.Token}
+	if len(g.Allocations) > 0 {
+		canonical.Allocations = slices.Clone(g.Allocations)
+		slices.SortFunc(canonical.Allocations, func(a, b Allocation) int { return strings.Compare(a.Address, b.Address) })
+	}
+	if len(g.Wallets) > 0 {
+		canonical.Wallets = slices.Clone(g.Wallets)
+		slices.Sort(canonical.Wallets)
+	}
+
+	// Marshaling plain structs can't fail
+	content, _ := json.Marshal(canonical)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,132 @@
+package genesis
+
+import (
+	"btp-transfer/testdb"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	addrA = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	addrB = "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+// writeFile stores content in a temporary file with the given name
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// 1. File formats
+// Goal: The same genesis in JSON and YAML loads to the same content and hash.
+func TestLoad_JSONAndYAML(t *testing.T) {
+	jsonPath := writeFile(t, "genesis.json", `{
+		"token": {"name": "BTP Token", "symbol": "BTP", "decimals": 0, "max_supply": 1000},
+		"allocations": [
+			{"address": "0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", "balance": 600},
+			{"address": "`+addrB+`", "balance": 400}
+		]
+	}`)
+	yamlPath := writeFile(t, "genesis.yaml", `
+token:
+  name: BTP Token
+  symbol: BTP
+  decimals: 0
+  max_supply: 1000
+allocations:
+  - address: "`+addrB+`"
+    balance: 400
+  - address: "`+addrA+`"
+    balance: 600
+`)
+
+	fromJSON, err := Load(jsonPath)
+	if err != nil {
+		t.Fatalf("Failed to load JSON genesis: %v", err)
+	}
+	fromYAML, err := Load(yamlPath)
+	if err != nil {
+		t.Fatalf("Failed to load YAML genesis: %v", err)
+	}
+
+	if fromJSON.Allocations[0].Address != addrA {
+		t.Errorf("Expected lowercased address, got %s", fromJSON.Allocations[0].Address)
+	}
+	if fromJSON.Supply() != 1000 {
+		t.Errorf("Expected supply 1000, got %d", fromJSON.Supply())
+	}
+	if fromJSON.Hash() != fromYAML.Hash() {
+		t.Errorf("Expected equal hashes, got %s and %s", fromJSON.Hash(), fromYAML.Hash())
+	}
+}
+
+// 2. Validation
+// Goal: Every problem of a broken genesis is reported, before anything is written.
+func TestValidate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("Default genesis must be valid, got: %v", err)
+	}
+
+	g := &Genesis{
+		Token: Token{Name: "BTP Token", Symbol: "BTP", MaxSupply: 100},
+		Allocations: []Allocation{
+			{Address: addrA, Balance: 80},
+			{Address: addrA, Balance: 10},
+			{Address: "0x123", Balance: 20},
+		},
+		Wallets: []string{addrB},
+	}
+	err := g.Validate()
+	if err == nil {
+		t.Fatal("Expected validation error")
+	}
+	for _, want := range []string{"duplicate address", "invalid address", "exceeds max_supply"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in error, got: %v", want, err)
+		}
+	}
+}
+
+// 3. Applying once
+// Goal: The genesis is applied at first start only; a different genesis is refused afterwards.
+func TestApply_Idempotent(t *testing.T) {
+	db := testdb.Migrated(t, "btp_genesis_test", "genesis", "ledger_entries", "wallet_shards", "wallets")
+	ctx := context.Background()
+	g := &Genesis{
+		Token:       Token{Name: "BTP Token", Symbol: "BTP"},
+		Allocations: []Allocation{{Address: addrA, Balance: 500}},
+		Wallets:     []string{addrB},
+	}
+
+	if applied, err := Apply(ctx, db, g); err != nil || !applied {
+		t.Fatalf("Expected genesis to be applied, got applied=%v, err: %v", applied, err)
+	}
+	// Tokens move, restart with the same genesis must not mint again
+	if _, err := db.Exec("UPDATE wallets SET balance = 100 WHERE address = $1", addrA); err != nil {
+		t.Fatalf("Failed to update wallet: %v", err)
+	}
+	if applied, err := Apply(ctx, db, g); err != nil || applied {
+		t.Fatalf("Expected no-op on second apply, got applied=%v, err: %v", applied, err)
+	}
+	var balance int64
+	if err := db.QueryRow("SELECT balance FROM wallets WHERE address = $1", addrA).Scan(&balance); err != nil || balance != 100 {
+		t.Errorf("Expected balance 100 to be kept, got %d, err: %v", balance, err)
+	}
+
+	if r, err := Recorded(ctx, db); err != nil || r == nil || r.Hash != g.Hash() || r.Supply != 500 {
+		t.Errorf("Expected the genesis recorded with supply 500, got %+v, err: %v", r, err)
+	}
+
+	other := &Genesis{Token: g.Token, Allocations: []Allocation{{Address: addrA, Balance: 501}}}
+	var mismatch *MismatchError
+	if _, err := Apply(ctx, db, other); !errors.As(err, &mismatch) {
+		t.Errorf("Expected MismatchError, got: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package graph
+
+import (
+	"btp-transfer/graph/model"
+	"btp-transfer/ledger"
+	"btp-transfer/service"
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Codes of errors returned by the auditEvents and verifyLedger queries
+const (
+	codeBadUserInput        = service.CodeBadUserInput
+	codeAuditLogUnavailable = "AUDIT_LOG_UNAVAILABLE"
+	codeLedgerUnverifiable  = "LEDGER_UNVERIFIABLE"
+)
+
+// maxAuditPage caps the first argument of auditEvents
+const maxAuditPage = 100
+
+// auditCursorPrefix keeps cursors opaque to clients, they are not meant to be built by hand
+const auditCursorPrefix = "audit:"
+
+func auditCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(auditCursorPrefix + strconv.FormatInt(id, 10)))
+}
+
+// parseAuditCursor returns the event ID of a cursor made by auditCursor.
+func parseAuditCursor(cursor string) (int64, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, false
+	}
+	digits, ok := strings.CutPrefix(string(raw), auditCursorPrefix)
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(digits, 10, 64)
+	return id, err == nil && id > 0
+}
+
+// auditFilter converts the GraphQL filter, nil matches everything.
+func auditFilter(f *model.AuditEventFilter) ledger.AuditFilter {
+	var filter ledger.AuditFilter
+	if f == nil {
+		return filter
+	}
+	set := func(dst *string, src *string) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	set(&filter.Principal, f.Principal)
+	set(&filter.Operation, f.Operation)
+	set(&filter.Outcome, f.Outcome)
+	set(&filter.Address, f.Address)
+	if f.Since != nil {
+		filter.Since = *f.Since
+	}
+	if f.Until != nil {
+		filter.Until = *f.Until
+	}
+	return filter
+}
+
+// codedError returns an error with the given extensions.code.
+func codedError(code, format string, args ...any) *gqlerror.Error {
+	err := gqlerror.Errorf(format, args...)
+	errcode.Set(err, code)
+	return err
+}
@@ -0,0 +1,118 @@
+package graph
+
+import (
+	"btp-transfer/ledger"
+	"btp-transfer/service"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// fakeAuditLog serves events with IDs count..1, newest first
+type fakeAuditLog struct {
+	count int64
+}
+
+func (f *fakeAuditLog) AuditEvents(_ context.Context, _ ledger.AuditFilter, before int64, limit int) ([]ledger.AuditEvent, error) {
+	var events []ledger.AuditEvent
+	for id := f.count; id > 0 && len(events) < limit; id-- {
+		if before == 0 || id < before {
+			events = append(events, ledger.AuditEvent{ID: id, Operation: ledger.OpTransfer})
+		}
+	}
+	return events, nil
+}
+
+// asOperator is the context of a request sent by the operator ops
+var asOperator = ledger.WithActor(context.Background(), ledger.Actor{Principal: "ops"})
+
+// auditResolver serves count events to the operator ops
+func auditResolver(count int64) *Resolver {
+	return &Resolver{Audit: &fakeAuditLog{count: count}, TrustPrincipal: true, Operators: []string{"ops"}}
+}
+
+// errorCode returns extensions.code of a GraphQL error
+func errorCode(err error) any {
+	var gqlErr *gqlerror.Error
+	if !errors.As(err, &gqlErr) {
+		return nil
+	}
+	return gqlErr.Extensions["code"]
+}
+
+// 1. Paging
+// Goal: Following endCursor walks through all events exactly once.
+func TestAuditEvents_Paging(t *testing.T) {
+	query := auditResolver(5).Query()
+
+	var seen []int64
+	var after *string
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("Expected 3 pages, still paging after %v", seen)
+		}
+		conn, err := query.AuditEvents(asOperator, nil, 2, after)
+		if err != nil {
+			t.Fatalf("AuditEvents failed: %v", err)
+		}
+		for _, edge := range conn.Edges {
+			seen = append(seen, edge.Node.ID)
+		}
+		if !conn.PageInfo.HasNextPage {
+			break
+		}
+		after = conn.PageInfo.EndCursor
+	}
+	if len(seen) != 5 || seen[0] != 5 || seen[4] != 1 {
+		t.Errorf("Expected events 5..1, got %v", seen)
+	}
+}
+
+// 2. Invalid input
+// Goal: Out of range page sizes and forged cursors are rejected as BAD_USER_INPUT.
+func TestAuditEvents_BadInput(t *testing.T) {
+	query := auditResolver(5).Query()
+
+	for _, first := range []int64{0, -1, maxAuditPage + 1} {
+		if _, err := query.AuditEvents(asOperator, nil, first, nil); errorCode(err) != codeBadUserInput {
+			t.Errorf("Expected %s for first=%d, got: %v", codeBadUserInput, first, err)
+		}
+	}
+	for _, cursor := range []string{"5", "not base64!", auditCursor(0)} {
+		if _, err := query.AuditEvents(asOperator, nil, 2, &cursor); errorCode(err) != codeBadUserInput {
+			t.Errorf("Expected %s for cursor %q, got: %v", codeBadUserInput, cursor, err)
+		}
+	}
+}
+
+// 3. No audit log
+// Goal: Without PostgreSQL the query fails with a dedicated code instead of an internal error.
+func TestAuditEvents_Unavailable(t *testing.T) {
+	query := (&Resolver{Store: ledger.NewMemoryStore()}).Query()
+
+	if _, err := query.AuditEvents(context.Background(), nil, 20, nil); errorCode(err) != codeAuditLogUnavailable {
+		t.Errorf("Expected %s, got: %v", codeAuditLogUnavailable, err)
+	}
+}
+
+// 4. Authorization
+// Goal: Only operators authenticated by a trusted gateway read the audit log.
+func TestAuditEvents_Forbidden(t *testing.T) {
+	anonymous := ledger.WithActor(context.Background(), ledger.Actor{Principal: "anonymous"})
+	untrusted := &Resolver{Audit: &fakeAuditLog{count: 5}, Operators: []string{"ops"}}
+
+	for name, c := range map[string]struct {
+		resolver *Resolver
+		ctx      context.Context
+	}{
+		"anonymous caller":  {auditResolver(5), anonymous},
+		"no caller":         {auditResolver(5), context.Background()},
+		"untrusted gateway": {untrusted, asOperator},
+	} {
+		if _, err := c.resolver.Query().AuditEvents(c.ctx, nil, 2, nil); serviceCode(err) != service.CodeForbidden {
+			t.Errorf("%s: expected %s, got: %v", name, service.CodeForbidden, err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package graph
+
+import (
+	"btp-transfer/ledger"
+	"btp-transfer/service"
+	"context"
+	"log/slog"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ErrorPresenter turns resolver errors into GraphQL errors with a stable extensions.code.
+// Domain errors keep their message and details. Any other resolver error (database, network...)
+// is logged server-side and replaced with a generic message, so internals never leak to clients.
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+	if code, details, ok := service.Code(err); ok {
+		if gqlErr.Extensions == nil {
+			gqlErr.Extensions = map[string]any{}
+		}
+		gqlErr.Extensions["code"] = code
+		if details != nil {
+			gqlErr.Extensions["details"] = details
+		}
+		return gqlErr
+	}
+
+	// Errors produced by gqlgen itself (parsing, validation, limits) are safe to show
+	if gqlErr.Err == nil || gqlErr.Extensions["code"] != nil {
+		return gqlErr
+	}
+
+	slog.ErrorContext(ctx, "graphql: internal error", "path", gqlErr.Path.String(), "error", err)
+	return &gqlerror.Error{
+		Message:    "internal server error",
+		Path:       gqlErr.Path,
+		Locations:  gqlErr.Locations,
+		Extensions: map[string]any{"code": ledger.CodeInternal},
+	}
+}
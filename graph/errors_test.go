@@ -0,0 +1,51 @@
+package graph
+
+import (
+	"btp-transfer/ledger"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// presentResolverError runs err through ErrorPresenter the same way gqlgen does for resolver errors
+func presentResolverError(err error) *gqlerror.Error {
+	return ErrorPresenter(context.Background(), graphql.ErrorOnPath(context.Background(), err))
+}
+
+func TestErrors_PresenterSetsCodeAndDetails(t *testing.T) {
+	gqlErr := presentResolverError(&ledger.InsufficientBalanceError{Address: "0xpoor", Available: 10, Requested: 20})
+
+	if gqlErr.Extensions["code"] != ledger.CodeInsufficientBalance {
+		t.Errorf("Expected code %s, got: %v", ledger.CodeInsufficientBalance, gqlErr.Extensions["code"])
+	}
+	details, _ := gqlErr.Extensions["details"].(map[string]any)
+	if details["available"] != int64(10) || details["requested"] != int64(20) {
+		t.Errorf("Unexpected details: %v", details)
+	}
+}
+
+func TestErrors_PresenterMasksInternalErrors(t *testing.T) {
+	gqlErr := presentResolverError(fmt.Errorf("failed to begin transaction: %w", errors.New("dial tcp 10.0.0.5:5432: connection refused")))
+
+	if gqlErr.Extensions["code"] != ledger.CodeInternal {
+		t.Errorf("Expected code %s, got: %v", ledger.CodeInternal, gqlErr.Extensions["code"])
+	}
+	if strings.Contains(gqlErr.Message, "10.0.0.5") {
+		t.Errorf("Internal error details leaked to client: %s", gqlErr.Message)
+	}
+}
+
+func TestErrors_PresenterKeepsFrameworkErrors(t *testing.T) {
+	in := gqlerror.ErrorPathf(ast.Path{ast.PathName("transfer")}, "cannot query field")
+
+	gqlErr := ErrorPresenter(context.Background(), in)
+	if gqlErr.Message != "cannot query field" {
+		t.Errorf("Expected framework error to pass through, got: %s", gqlErr.Message)
+	}
+}
@@ -0,0 +1,162 @@
+package graph
+
+import (
+	"btp-transfer/graph/model"
+	"btp-transfer/service"
+	"context"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// transferCost is the complexity of a single transfer mutation.
+// It locks and writes two wallet rows, so it is priced well above a plain field read (1).
+const transferCost = 10
+
+// verifyLedgerCost is the complexity of verifyLedger, which reads every wallet and ledger entry.
+// Half of the default budget, so one operation can't run it twice.
+const verifyLedgerCost = 100
+
+// complexityRoot returns per-field costs used by the complexity limit.
+// Fields not listed here cost 1 plus the cost of their children.
+func complexityRoot() ComplexityRoot {
+	var c ComplexityRoot
+
+	c.Mutation.Transfer = func(childComplexity int, _ string, _ string, _ int64) int {
+		return childComplexity + transferCost
+	}
+	// Writes a wallet row and its audit event, like a transfer
+	c.Mutation.UpdateWallet = func(childComplexity int, _ string, _ *string, _ []string, _ map[string]any) int {
+		return childComplexity + transferCost
+	}
+	// Pages are capped at maxAuditPage rows, each row costs as much as the selected fields
+	c.Query.AuditEvents = func(childComplexity int, _ *model.AuditEventFilter, first int64, _ *string) int {
+		return childComplexity * int(min(max(first, 1), maxAuditPage))
+	}
+	c.Wallet.Transfers = func(childComplexity int, first int64, _ *string) int {
+		return childComplexity * int(min(max(first, 1), service.MaxPage))
+	}
+	c.Query.Wallets = func(childComplexity int, addresses []string) int {
+		return childComplexity * min(max(len(addresses), 1), service.MaxPage)
+	}
+	c.Query.SearchWallets = func(childComplexity int, _ *string, _ []string, _ *int64, _ *int64, first int64, _ *string) int {
+		return childComplexity * int(min(max(first, 1), service.MaxPage))
+	}
+	c.Query.VerifyLedger = func(childComplexity int) int {
+		return childComplexity + verifyLedgerCost
+	}
+
+	return c
+}
+
+const depthLimitExtension = "DepthLimit"
+
+// DepthLimit rejects operations whose selection sets are nested deeper than Max.
+// Introspection fields (__schema, __type) are not counted, so the playground keeps working.
+type DepthLimit struct {
+	Max int
+}
+
+var _ interface {
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = DepthLimit{}
+
+func (d DepthLimit) ExtensionName() string {
+	return depthLimitExtension
+}
+
+func (d DepthLimit) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (d DepthLimit) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	op := opCtx.Doc.Operations.ForName(opCtx.OperationName)
+	if op == nil {
+		return nil
+	}
+
+	depth := selectionDepth(op.SelectionSet, opCtx.Doc.Fragments, map[string]bool{})
+	opCtx.Stats.SetExtension(depthLimitExtension, depth)
+
+	if depth > d.Max {
+		err := gqlerror.Errorf("operation has depth %d, which exceeds the limit of %d", depth, d.Max)
+		errcode.Set(err, "DEPTH_LIMIT_EXCEEDED")
+		return err
+	}
+	return nil
+}
+
+// selectionDepth returns the deepest field nesting of a selection set, following fragments.
+// visited guards against fragment cycles, which validation normally rejects anyway.
+func selectionDepth(set ast.SelectionSet, fragments ast.FragmentDefinitionList, visited map[string]bool) int {
+	maxDepth := 0
+	for _, sel := range set {
+		depth := 0
+		switch s := sel.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") {
+				continue
+			}
+			depth = 1 + selectionDepth(s.SelectionSet, fragments, visited)
+		case *ast.InlineFragment:
+			depth = selectionDepth(s.SelectionSet, fragments, visited)
+		case *ast.FragmentSpread:
+			if visited[s.Name] {
+				continue
+			}
+			def := fragments.ForName(s.Name)
+			if def == nil {
+				continue
+			}
+			visited[s.Name] = true
+			depth = selectionDepth(def.SelectionSet, fragments, visited)
+			delete(visited, s.Name)
+		}
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+	}
+	return maxDepth
+}
+
+// CostReport adds the computed cost of an operation to the "cost" response extension,
+// so clients can see how close their queries are to the limits.
+type CostReport struct{}
+
+var _ interface {
+	graphql.ResponseInterceptor
+	graphql.HandlerExtension
+} = CostReport{}
+
+func (CostReport) ExtensionName() string {
+	return "CostReport"
+}
+
+func (CostReport) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (CostReport) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+
+	cost := map[string]any{}
+	if stats := extension.GetComplexityStats(ctx); stats != nil {
+		cost["complexity"] = stats.Complexity
+		cost["maxComplexity"] = stats.ComplexityLimit
+	}
+	if depth, ok := graphql.GetOperationContext(ctx).Stats.GetExtension(depthLimitExtension).(int); ok {
+		cost["depth"] = depth
+	}
+	if len(cost) > 0 {
+		graphql.RegisterExtension(ctx, "cost", cost)
+	}
+
+	return next(ctx)
+}
@@ -0,0 +1,149 @@
+package graph
+
+import (
+	"btp-transfer/ledger"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+// postQuery sends a GraphQL request to the handler and decodes the JSON response
+func postQuery(t *testing.T, h http.Handler, body string) map[string]any {
+	req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var resp map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response %q: %v", rec.Body.String(), err)
+	}
+	return resp
+}
+
+// firstErrorCode returns extensions.code of the first error in a GraphQL response
+func firstErrorCode(resp map[string]any) string {
+	errs, _ := resp["errors"].([]any)
+	if len(errs) == 0 {
+		return ""
+	}
+	ext, _ := errs[0].(map[string]any)["extensions"].(map[string]any)
+	code, _ := ext["code"].(string)
+	return code
+}
+
+func TestLimits_SelectionDepth(t *testing.T) {
+	doc, err := parser.ParseQuery(&ast.Source{Input: `
+		query { a { b { c } } d { ...F } }
+		fragment F on T { e { f { g { h } } } }
+	`})
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+
+	depth := selectionDepth(doc.Operations[0].SelectionSet, doc.Fragments, map[string]bool{})
+	if depth != 5 {
+		t.Errorf("Expected depth 5, got %d", depth)
+	}
+}
+
+func TestLimits_ComplexityRejected(t *testing.T) {
+	h := NewHandler(&Resolver{}, Options{Limits: Limits{MaxDepth: 10, MaxComplexity: transferCost - 1, MaxBodyBytes: 1 << 20}})
+
+	resp := postQuery(t, h, `{"query":"mutation { transfer(from_address: \"0xa\", to_address: \"0xb\", amount: 1) }"}`)
+	if code := firstErrorCode(resp); code != "COMPLEXITY_LIMIT_EXCEEDED" {
+		t.Errorf("Expected COMPLEXITY_LIMIT_EXCEEDED, got %q (%v)", code, resp)
+	}
+}
+
+func TestLimits_DepthRejected(t *testing.T) {
+	h := NewHandler(&Resolver{}, Options{Limits: Limits{MaxDepth: 0, MaxComplexity: 100, MaxBodyBytes: 1 << 20}})
+
+	resp := postQuery(t, h, `{"query":"{ dummy }"}`)
+	if code := firstErrorCode(resp); code != "DEPTH_LIMIT_EXCEEDED" {
+		t.Errorf("Expected DEPTH_LIMIT_EXCEEDED, got %q (%v)", code, resp)
+	}
+}
+
+func TestLimits_CostReported(t *testing.T) {
+	h := NewHandler(&Resolver{}, Options{Limits: Limits{MaxDepth: 10, MaxComplexity: 100, MaxBodyBytes: 1 << 20}})
+
+	// Introspection is not counted towards depth
+	resp := postQuery(t, h, `{"query":"{ __schema { queryType { name } } }"}`)
+	ext, _ := resp["extensions"].(map[string]any)
+	cost, ok := ext["cost"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected cost extension in response, got: %v", resp)
+	}
+	if cost["depth"] != float64(0) || cost["maxComplexity"] != float64(100) {
+		t.Errorf("Unexpected cost extension: %v", cost)
+	}
+}
+
+func TestLimits_BodyTooLarge(t *testing.T) {
+	h := NewHandler(&Resolver{}, Options{Limits: Limits{MaxDepth: 10, MaxComplexity: 100, MaxBodyBytes: 16}})
+
+	resp := postQuery(t, h, `{"query":"{ dummy dummy dummy dummy }"}`)
+	if _, ok := resp["errors"]; !ok {
+		t.Errorf("Expected an error for oversized body, got: %v", resp)
+	}
+}
+
+// complexityOf returns the computed complexity of query, as reported under extensions.cost
+func complexityOf(t *testing.T, h http.Handler, query string) float64 {
+	body, _ := json.Marshal(map[string]string{"query": query})
+	resp := postQuery(t, h, string(body))
+	ext, _ := resp["extensions"].(map[string]any)
+	cost, ok := ext["cost"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected cost extension in response, got: %v", resp)
+	}
+	complexity, _ := cost["complexity"].(float64)
+	return complexity
+}
+
+// Every row of a page costs as much as the fields selected on it
+func TestLimits_PagesMultiplyCost(t *testing.T) {
+	h := NewHandler(&Resolver{Store: ledger.NewMemoryStore()}, Options{Limits: Limits{MaxDepth: 10, MaxComplexity: 1 << 30, MaxBodyBytes: 1 << 20}})
+
+	cases := []struct {
+		query string
+		want  float64
+	}{
+		// edges (1) + cursor (1), 50 times
+		{`{ auditEvents(first: 50) { edges { cursor } } }`, 100},
+		// first is capped at maxAuditPage
+		{`{ auditEvents(first: 1000) { edges { cursor } } }`, 2 * maxAuditPage},
+		// address and balance of 3 wallets
+		{`{ wallets(addresses: ["0xa", "0xb", "0xc"]) { address balance } }`, 2 * 3},
+		// 10 times edges, node and address
+		{`{ searchWallets(query: "0x", first: 10) { edges { node { address } } } }`, 3 * 10},
+		// wallet (1) + 20 times edges, node and id
+		{`{ wallet(address: "0xa") { transfers(first: 20) { edges { node { id } } } } }`, 1 + 3*20},
+	}
+	for _, c := range cases {
+		if got := complexityOf(t, h, c.query); got != c.want {
+			t.Errorf("Expected complexity %v for %s, got %v", c.want, c.query, got)
+		}
+	}
+}
+
+// Nested pages multiply: a page of wallets' transfers per transfer is refused at the default limits
+func TestLimits_NestedPagesRejected(t *testing.T) {
+	// The defaults of GRAPHQL_MAX_DEPTH and GRAPHQL_MAX_COMPLEXITY
+	h := NewHandler(&Resolver{}, Options{Limits: Limits{MaxDepth: 10, MaxComplexity: 200, MaxBodyBytes: 1 << 20}})
+
+	query := `{ wallet(address: "0xa") { transfers(first: 20) { edges { node {
+		fromWallet { transfers(first: 20) { edges { node { id } } } }
+	} } } } }`
+	body, _ := json.Marshal(map[string]string{"query": query})
+	resp := postQuery(t, h, string(body))
+	if code := firstErrorCode(resp); code != "COMPLEXITY_LIMIT_EXCEEDED" {
+		t.Errorf("Expected COMPLEXITY_LIMIT_EXCEEDED, got %q (%v)", code, resp)
+	}
+}
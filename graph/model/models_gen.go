@@ -2,8 +2,61 @@
 
 package model
 
+import (
+	"btp-transfer/ledger"
+	"btp-transfer/service"
+	"time"
+)
+
+type AuditEventConnection struct {
+	Edges    []*AuditEventEdge `json:"edges"`
+	PageInfo *PageInfo         `json:"pageInfo"`
+}
+
+type AuditEventEdge struct {
+	Cursor string             `json:"cursor"`
+	Node   *ledger.AuditEvent `json:"node"`
+}
+
+type AuditEventFilter struct {
+	Principal *string `json:"principal,omitempty"`
+	Operation *string `json:"operation,omitempty"`
+	Outcome   *string `json:"outcome,omitempty"`
+	// Matches the address, from and to arguments
+	Address *string `json:"address,omitempty"`
+	// Events at or after this time
+	Since *time.Time `json:"since,omitempty"`
+	// Events before this time
+	Until *time.Time `json:"until,omitempty"`
+}
+
 type Mutation struct {
 }
 
+type PageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor,omitempty"`
+}
+
 type Query struct {
 }
+
+type TransferConnection struct {
+	Edges    []*TransferEdge `json:"edges"`
+	PageInfo *PageInfo       `json:"pageInfo"`
+}
+
+type TransferEdge struct {
+	Cursor string        `json:"cursor"`
+	Node   *ledger.Entry `json:"node"`
+}
+
+type WalletConnection struct {
+	Edges    []*WalletEdge `json:"edges"`
+	PageInfo *PageInfo     `json:"pageInfo"`
+}
+
+type WalletEdge struct {
+	Cursor string          `json:"cursor"`
+	Node   *service.Wallet `json:"node"`
+}
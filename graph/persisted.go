@@ -0,0 +1,98 @@
+package graph
+
+import (
+	"btp-transfer/persisted"
+	"context"
+	"errors"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// codePersistedQueryNotAllowed is returned in allow-list mode for operations that weren't registered.
+// Unlike PERSISTED_QUERY_NOT_FOUND, it doesn't make APQ clients retry with the whole operation.
+const codePersistedQueryNotAllowed = "PERSISTED_QUERY_NOT_ALLOWED"
+
+// PersistedQueries configures persisted queries: operations sent by hash instead of text.
+type PersistedQueries struct {
+	// Cache holds the operations registered by clients (APQ). Defaults to an in-memory LRU of 1000 operations.
+	Cache graphql.Cache[string]
+	// Registered operations by hash, known before any client sends them. Optional.
+	Registered map[string]string
+	// AllowListOnly only runs the registered operations; clients can't register others.
+	AllowListOnly bool
+}
+
+// extension returns the extension resolving operation hashes.
+func (p PersistedQueries) extension() graphql.HandlerExtension {
+	if p.AllowListOnly {
+		return AllowList{Operations: p.Registered}
+	}
+	cache := p.Cache
+	if cache == nil {
+		cache = lru.New[string](1000)
+	}
+	return extension.AutomaticPersistedQuery{Cache: registeredFirst{registered: p.Registered, Cache: cache}}
+}
+
+// registeredFirst looks up the registered operations before the cache, so they are never evicted.
+type registeredFirst struct {
+	registered map[string]string
+	graphql.Cache[string]
+}
+
+func (c registeredFirst) Get(ctx context.Context, hash string) (string, bool) {
+	if query, ok := c.registered[hash]; ok {
+		return query, true
+	}
+	return c.Cache.Get(ctx, hash)
+}
+
+func (c registeredFirst) Add(ctx context.Context, hash string, query string) {
+	if _, ok := c.registered[hash]; !ok {
+		c.Cache.Add(ctx, hash, query)
+	}
+}
+
+// AllowList only runs the operations registered ahead of time. Clients send their hash the way APQ
+// clients do; an operation sent as text is accepted only if it is registered, byte for byte.
+type AllowList struct {
+	Operations map[string]string
+}
+
+var _ interface {
+	graphql.OperationParameterMutator
+	graphql.HandlerExtension
+} = AllowList{}
+
+func (AllowList) ExtensionName() string {
+	return "PersistedQueryAllowList"
+}
+
+func (a AllowList) Validate(graphql.ExecutableSchema) error {
+	if len(a.Operations) == 0 {
+		return errors.New("the persisted query allow-list has no operations")
+	}
+	return nil
+}
+
+func (a AllowList) MutateOperationParameters(_ context.Context, rawParams *graphql.RawParams) *gqlerror.Error {
+	hash := persisted.Hash(rawParams.Query)
+	if ext, ok := rawParams.Extensions["persistedQuery"].(map[string]any); ok {
+		if sent, ok := ext["sha256Hash"].(string); ok && (rawParams.Query == "" || sent == hash) {
+			hash = sent
+		}
+	}
+
+	query, ok := a.Operations[hash]
+	if !ok || (rawParams.Query != "" && rawParams.Query != query) {
+		err := gqlerror.Errorf("operation %s is not in the persisted query allow-list", hash)
+		errcode.Set(err, codePersistedQueryNotAllowed)
+		return err
+	}
+	rawParams.Query = query
+	return nil
+}
@@ -0,0 +1,128 @@
+package graph
+
+import (
+	"btp-transfer/ledger"
+	"btp-transfer/persisted"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const walletQuery = `query($a: String!) { wallet(address: $a) { balance } }`
+
+// newPersistedHandler serves a memory store holding 0xabc with 100 tokens, and a ledger that verifies
+// for the operator ops
+func newPersistedHandler(t *testing.T, queries PersistedQueries, getMaxAge time.Duration) http.Handler {
+	store := ledger.NewMemoryStore()
+	if err := store.CreateWallet(context.Background(), "0xabc", 100); err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	verify := func(context.Context) (*ledger.Report, error) {
+		return &ledger.Report{OK: true, Discrepancies: []ledger.Discrepancy{}}, nil
+	}
+	return NewHandler(&Resolver{Store: store, Verify: verify, TrustPrincipal: true, Operators: []string{"ops"}}, Options{
+		Limits:           Limits{MaxDepth: 10, MaxComplexity: 200, MaxBodyBytes: 1 << 20},
+		PersistedQueries: queries,
+		GetMaxAge:        getMaxAge,
+		PrincipalHeader:  "X-Principal",
+	})
+}
+
+// persistedBody is a request sending the hash of walletQuery, and its text when withQuery is set
+func persistedBody(withQuery bool) string {
+	query := ""
+	if withQuery {
+		query = fmt.Sprintf(`"query": %q, `, walletQuery)
+	}
+	return fmt.Sprintf(`{%s"variables": {"a": "0xabc"}, "extensions": {"persistedQuery": {"version": 1, "sha256Hash": %q}}}`,
+		query, persisted.Hash(walletQuery))
+}
+
+// 1. Automatic persisted queries
+// Goal: An unknown hash asks for the query; once sent, the hash alone runs it.
+func TestPersistedQueries_Automatic(t *testing.T) {
+	h := newPersistedHandler(t, PersistedQueries{}, 0)
+
+	if code := firstErrorCode(postQuery(t, h, persistedBody(false))); code != "PERSISTED_QUERY_NOT_FOUND" {
+		t.Fatalf("Expected PERSISTED_QUERY_NOT_FOUND, got %q", code)
+	}
+	if resp := postQuery(t, h, persistedBody(true)); resp["errors"] != nil {
+		t.Fatalf("Expected the query to run, got %v", resp)
+	}
+	resp := postQuery(t, h, persistedBody(false))
+	wallet, _ := resp["data"].(map[string]any)["wallet"].(map[string]any)
+	if wallet["balance"] != float64(100) {
+		t.Errorf("Expected balance 100 by hash, got %v", resp)
+	}
+}
+
+// 2. Allow-list
+// Goal: Only registered operations run, by hash or by text; others are refused without registering them.
+func TestPersistedQueries_AllowList(t *testing.T) {
+	h := newPersistedHandler(t, PersistedQueries{
+		Registered:    map[string]string{persisted.Hash(walletQuery): walletQuery},
+		AllowListOnly: true,
+	}, 0)
+
+	if resp := postQuery(t, h, persistedBody(false)); resp["errors"] != nil {
+		t.Errorf("Expected the registered hash to run, got %v", resp)
+	}
+	if resp := postQuery(t, h, fmt.Sprintf(`{"query": %q, "variables": {"a": "0xabc"}}`, walletQuery)); resp["errors"] != nil {
+		t.Errorf("Expected the registered text to run, got %v", resp)
+	}
+
+	other := `{ wallet(address: "0xabc") { address } }`
+	for _, body := range []string{
+		fmt.Sprintf(`{"query": %q}`, other),
+		fmt.Sprintf(`{"query": %q, "extensions": {"persistedQuery": {"version": 1, "sha256Hash": %q}}}`, other, persisted.Hash(other)),
+		fmt.Sprintf(`{"extensions": {"persistedQuery": {"version": 1, "sha256Hash": %q}}}`, persisted.Hash(other)),
+	} {
+		if code := firstErrorCode(postQuery(t, h, body)); code != codePersistedQueryNotAllowed {
+			t.Errorf("Expected %s for %s, got %q", codePersistedQueryNotAllowed, body, code)
+		}
+	}
+}
+
+// 3. CDN caching
+// Goal: Successful GET queries of public fields are cacheable per principal; errors and other fields are not.
+func TestPersistedQueries_CacheableGET(t *testing.T) {
+	verifyQuery := `{ wallet(address: "0xabc") { balance } verifyLedger { ok } }`
+	fragmentQuery := `query($a: String!) { ...Public } fragment Public on Query { wallet(address: $a) { balance } }`
+	h := newPersistedHandler(t, PersistedQueries{Registered: map[string]string{
+		persisted.Hash(walletQuery):   walletQuery,
+		persisted.Hash(verifyQuery):   verifyQuery,
+		persisted.Hash(fragmentQuery): fragmentQuery,
+	}}, time.Minute)
+
+	get := func(hash string) *httptest.ResponseRecorder {
+		params := url.Values{
+			"variables":  {`{"a": "0xabc"}`},
+			"extensions": {fmt.Sprintf(`{"persistedQuery": {"version": 1, "sha256Hash": %q}}`, hash)},
+		}
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/query?"+params.Encode(), nil)
+		h.ServeHTTP(rec, r.WithContext(asOperator))
+		return rec
+	}
+
+	for _, query := range []string{walletQuery, fragmentQuery} {
+		rec := get(persisted.Hash(query))
+		if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "public, max-age=60" {
+			t.Errorf("Expected a cacheable response to %s, got %d %q: %s", query, rec.Code, rec.Header().Get("Cache-Control"), rec.Body.String())
+		}
+		if rec.Header().Get("Vary") != "X-Principal" {
+			t.Errorf("Expected Vary: X-Principal, got %q", rec.Header().Get("Vary"))
+		}
+	}
+	if rec := get(persisted.Hash(verifyQuery)); strings.Contains(rec.Body.String(), "errors") || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Expected verifyLedger not to be cached, got %d %q: %s", rec.Code, rec.Header().Get("Cache-Control"), rec.Body.String())
+	}
+	if rec := get(persisted.Hash("{ unknown }")); rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Expected PERSISTED_QUERY_NOT_FOUND not to be cached, got %q: %s", rec.Header().Get("Cache-Control"), rec.Body.String())
+	}
+}
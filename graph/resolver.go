@@ -1,7 +1,68 @@
 package graph
 
-import "database/sql"
+import (
+	"btp-transfer/ledger"
+	"btp-transfer/loaders"
+	"btp-transfer/service"
+	"context"
+)
 
 type Resolver struct {
-	DB *sql.DB
+	Store ledger.Store
+	// Audit serves the auditEvents query; nil when the store keeps no audit log (memory)
+	Audit ledger.AuditLog
+	// History serves the transfers of wallets; nil when the store keeps none (memory)
+	History ledger.History
+	// Verify serves the verifyLedger query, it may return a recent report; nil when the store can't be
+	// verified (memory)
+	Verify func(ctx context.Context) (*ledger.Report, error)
+	// Balances reads many wallets with one query; nil reads them one by one from Store
+	Balances ledger.BalanceReader
+	// Directory serves wallet metadata and searchWallets; nil when the store keeps none (memory)
+	Directory ledger.Directory
+	// TrustPrincipal lets owners change the metadata of their wallets, see service.Service
+	TrustPrincipal bool
+	// Operators may query the audit log and verify the ledger, see service.Service
+	Operators []string
+}
+
+// service returns the logic shared with the REST API, over the same store.
+func (r *Resolver) service() *service.Service {
+	return &service.Service{Store: r.Store, History: r.History, Directory: r.Directory, TrustPrincipal: r.TrustPrincipal, Operators: r.Operators}
+}
+
+// Source returns what the loaders of a request read from, see loaders.Middleware.
+func (r *Resolver) Source() loaders.Source {
+	src := loaders.Source{Balances: r.Balances, History: r.History, Directory: r.Directory}
+	if src.Balances == nil {
+		src.Balances = loaders.OneByOne(r.Store)
+	}
+	return src
+}
+
+// load returns the loaders of the request. Outside of loaders.Middleware (e.g. resolvers called
+// directly), each call gets new ones: lookups are not batched, but still correct.
+func (r *Resolver) load(ctx context.Context) *loaders.Loaders {
+	if l := loaders.For(ctx); l != nil {
+		return l
+	}
+	return loaders.New(r.Source())
+}
+
+// wallet returns an existing wallet through the loaders, so wallets of a request are read together.
+func (r *Resolver) wallet(ctx context.Context, address string) (*service.Wallet, error) {
+	address = service.Normalize(address)
+	balance, err := r.load(ctx).Balance(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	return &service.Wallet{Address: address, Balance: balance}, nil
+}
+
+// metadata returns the metadata of a wallet, read with it or through the loaders.
+func (r *Resolver) metadata(ctx context.Context, wallet *service.Wallet) (ledger.Metadata, error) {
+	if wallet.Metadata != nil {
+		return *wallet.Metadata, nil
+	}
+	return r.load(ctx).Metadata(ctx, wallet.Address)
 }
@@ -6,20 +6,41 @@ package graph
 // Code generated by github.com/99designs/gqlgen version v0.17.84
 
 import (
+	"btp-transfer/graph/model"
+	"btp-transfer/ledger"
+	"btp-transfer/service"
 	"context"
+	"errors"
 	"fmt"
-	"strings"
+	"sync"
 )
 
+// ErrorCode is the resolver for the errorCode field.
+func (r *auditEventResolver) ErrorCode(ctx context.Context, obj *ledger.AuditEvent) (*string, error) {
+	if obj.ErrorCode == "" {
+		return nil, nil
+	}
+	return &obj.ErrorCode, nil
+}
+
+// Address is the resolver for the address field.
+func (r *discrepancyResolver) Address(ctx context.Context, obj *ledger.Discrepancy) (*string, error) {
+	if obj.Address == "" {
+		return nil, nil
+	}
+	return &obj.Address, nil
+}
+
 // Transfer is the resolver for the transfer field.
 // In a case of any error whole transaction is recalled
 func (r *mutationResolver) Transfer(ctx context.Context, fromAddress string, toAddress string, amount int64) (int64, error) {
-	// Assure that 0xABC and 0xabc are pointing to the same address
-	fromAddress = strings.ToLower(fromAddress)
-	toAddress = strings.ToLower(toAddress)
+	// Delegate operations on data to the service, shared with the REST API
+	return r.service().Transfer(ctx, fromAddress, toAddress, amount)
+}
 
-	// Delegate operations on data to database.go
-	return r.ExecuteTransfer(ctx, fromAddress, toAddress, amount)
+// UpdateWallet is the resolver for the updateWallet field.
+func (r *mutationResolver) UpdateWallet(ctx context.Context, address string, label *string, tags []string, attributes map[string]any) (*service.Wallet, error) {
+	return r.service().UpdateWallet(ctx, address, ledger.MetadataUpdate{Label: label, Tags: tags, Attributes: attributes})
 }
 
 // Dummy is the resolver for the dummy field.
@@ -27,11 +48,219 @@ func (r *queryResolver) Dummy(ctx context.Context) (*string, error) {
 	panic(fmt.Errorf("not implemented: Dummy - dummy"))
 }
 
+// Wallet is the resolver for the wallet field.
+func (r *queryResolver) Wallet(ctx context.Context, address string) (*service.Wallet, error) {
+	return r.wallet(ctx, address)
+}
+
+// Wallets is the resolver for the wallets field.
+func (r *queryResolver) Wallets(ctx context.Context, addresses []string) ([]*service.Wallet, error) {
+	if len(addresses) > service.MaxPage {
+		return nil, codedError(codeBadUserInput, "at most %d addresses are allowed, got %d", service.MaxPage, len(addresses))
+	}
+	// Concurrent lookups end up in the same batch, like the fields of a list
+	wallets := make([]*service.Wallet, len(addresses))
+	errs := make([]error, len(addresses))
+	var wg sync.WaitGroup
+	for i, address := range addresses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wallet, err := r.wallet(ctx, address)
+			if errors.Is(err, ledger.ErrWalletNotFound) {
+				return
+			}
+			wallets[i], errs[i] = wallet, err
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return wallets, nil
+}
+
+// SearchWallets is the resolver for the searchWallets field.
+func (r *queryResolver) SearchWallets(ctx context.Context, query *string, tags []string, minBalance *int64, maxBalance *int64, first int64, after *string) (*model.WalletConnection, error) {
+	filter := ledger.WalletFilter{Tags: tags, MinBalance: minBalance, MaxBalance: maxBalance}
+	if query != nil {
+		filter.Query = *query
+	}
+	var cursor string
+	if after != nil {
+		cursor = *after
+	}
+	page, err := r.service().SearchWallets(ctx, filter, int(first), cursor)
+	if err != nil {
+		return nil, err
+	}
+	conn := &model.WalletConnection{PageInfo: &model.PageInfo{HasNextPage: page.HasNextPage}}
+	conn.Edges = make([]*model.WalletEdge, len(page.Wallets))
+	for i := range page.Wallets {
+		conn.Edges[i] = &model.WalletEdge{Cursor: service.WalletCursor(page.Wallets[i].Address), Node: &page.Wallets[i]}
+	}
+	if page.EndCursor != "" {
+		conn.PageInfo.EndCursor = &page.EndCursor
+	}
+	return conn, nil
+}
+
+// Transfer is the resolver for the transfer field.
+func (r *queryResolver) Transfer(ctx context.Context, id int64) (*ledger.Entry, error) {
+	if r.History == nil {
+		return nil, codedError(service.CodeTransferHistoryUnavailable, "transfer history is only kept by the postgres store")
+	}
+	return r.load(ctx).Transfer(ctx, id)
+}
+
+// AuditEvents is the resolver for the auditEvents field.
+func (r *queryResolver) AuditEvents(ctx context.Context, filter *model.AuditEventFilter, first int64, after *string) (*model.AuditEventConnection, error) {
+	if r.Audit == nil {
+		return nil, codedError(codeAuditLogUnavailable, "audit log is only kept by the postgres store")
+	}
+	if err := r.service().RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+	if first <= 0 || first > maxAuditPage {
+		return nil, codedError(codeBadUserInput, "first must be between 1 and %d, got %d", maxAuditPage, first)
+	}
+	var before int64
+	if after != nil {
+		id, ok := parseAuditCursor(*after)
+		if !ok {
+			return nil, codedError(codeBadUserInput, "invalid cursor %q", *after)
+		}
+		before = id
+	}
+
+	// One more than asked for tells whether there is a next page
+	events, err := r.Audit.AuditEvents(ctx, auditFilter(filter), before, int(first)+1)
+	if err != nil {
+		return nil, err
+	}
+	conn := &model.AuditEventConnection{PageInfo: &model.PageInfo{HasNextPage: len(events) > int(first)}}
+	if conn.PageInfo.HasNextPage {
+		events = events[:first]
+	}
+	conn.Edges = make([]*model.AuditEventEdge, len(events))
+	for i := range events {
+		conn.Edges[i] = &model.AuditEventEdge{Cursor: auditCursor(events[i].ID), Node: &events[i]}
+	}
+	if len(events) > 0 {
+		end := conn.Edges[len(events)-1].Cursor
+		conn.PageInfo.EndCursor = &end
+	}
+	return conn, nil
+}
+
+// VerifyLedger is the resolver for the verifyLedger field.
+func (r *queryResolver) VerifyLedger(ctx context.Context) (*ledger.Report, error) {
+	if r.Verify == nil {
+		return nil, codedError(codeLedgerUnverifiable, "ledger verification needs the postgres store")
+	}
+	if err := r.service().RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+	return r.Verify(ctx)
+}
+
+// From is the resolver for the from field.
+func (r *transferResolver) From(ctx context.Context, obj *ledger.Entry) (*string, error) {
+	if obj.From == "" {
+		return nil, nil
+	}
+	return &obj.From, nil
+}
+
+// To is the resolver for the to field.
+func (r *transferResolver) To(ctx context.Context, obj *ledger.Entry) (*string, error) {
+	if obj.To == "" {
+		return nil, nil
+	}
+	return &obj.To, nil
+}
+
+// FromWallet is the resolver for the fromWallet field.
+func (r *transferResolver) FromWallet(ctx context.Context, obj *ledger.Entry) (*service.Wallet, error) {
+	if obj.From == "" {
+		return nil, nil
+	}
+	return r.wallet(ctx, obj.From)
+}
+
+// ToWallet is the resolver for the toWallet field.
+func (r *transferResolver) ToWallet(ctx context.Context, obj *ledger.Entry) (*service.Wallet, error) {
+	if obj.To == "" {
+		return nil, nil
+	}
+	return r.wallet(ctx, obj.To)
+}
+
+// Label is the resolver for the label field.
+func (r *walletResolver) Label(ctx context.Context, obj *service.Wallet) (string, error) {
+	metadata, err := r.metadata(ctx, obj)
+	return metadata.Label, err
+}
+
+// Tags is the resolver for the tags field.
+func (r *walletResolver) Tags(ctx context.Context, obj *service.Wallet) ([]string, error) {
+	metadata, err := r.metadata(ctx, obj)
+	return metadata.Tags, err
+}
+
+// Attributes is the resolver for the attributes field.
+func (r *walletResolver) Attributes(ctx context.Context, obj *service.Wallet) (map[string]any, error) {
+	metadata, err := r.metadata(ctx, obj)
+	return metadata.Attributes, err
+}
+
+// Transfers is the resolver for the transfers field.
+func (r *walletResolver) Transfers(ctx context.Context, obj *service.Wallet, first int64, after *string) (*model.TransferConnection, error) {
+	var cursor string
+	if after != nil {
+		cursor = *after
+	}
+	// The transfers of every wallet of a list are read together
+	s := r.service()
+	if s.History != nil {
+		s.History = r.load(ctx).History()
+	}
+	page, err := s.Transfers(ctx, obj.Address, int(first), cursor)
+	if err != nil {
+		return nil, err
+	}
+	conn := &model.TransferConnection{PageInfo: &model.PageInfo{HasNextPage: page.HasNextPage}}
+	conn.Edges = make([]*model.TransferEdge, len(page.Transfers))
+	for i := range page.Transfers {
+		conn.Edges[i] = &model.TransferEdge{Cursor: service.Cursor(page.Transfers[i].ID), Node: &page.Transfers[i]}
+	}
+	if page.EndCursor != "" {
+		conn.PageInfo.EndCursor = &page.EndCursor
+	}
+	return conn, nil
+}
+
+// AuditEvent returns AuditEventResolver implementation.
+func (r *Resolver) AuditEvent() AuditEventResolver { return &auditEventResolver{r} }
+
+// Discrepancy returns DiscrepancyResolver implementation.
+func (r *Resolver) Discrepancy() DiscrepancyResolver { return &discrepancyResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Transfer returns TransferResolver implementation.
+func (r *Resolver) Transfer() TransferResolver { return &transferResolver{r} }
+
+// Wallet returns WalletResolver implementation.
+func (r *Resolver) Wallet() WalletResolver { return &walletResolver{r} }
+
+type auditEventResolver struct{ *Resolver }
+type discrepancyResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type transferResolver struct{ *Resolver }
+type walletResolver struct{ *Resolver }
@@ -0,0 +1,222 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Limits bounds the amount of work a single GraphQL request may cause.
+type Limits struct {
+	MaxDepth      int
+	MaxComplexity int
+	MaxBodyBytes  int64
+}
+
+// Options configure the handler built by NewHandler.
+type Options struct {
+	Limits Limits
+	// Shutdown closes open websocket subscriptions when it is done. They are hijacked
+	// connections, so http.Server.Shutdown doesn't wait for them nor close them. Optional.
+	Shutdown context.Context
+	// Extensions are added after the built-in ones, e.g. for metrics. Optional.
+	Extensions []graphql.HandlerExtension
+	// PersistedQueries configures operations sent by hash; the zero value is APQ with an in-memory cache
+	PersistedQueries PersistedQueries
+	// GetMaxAge lets CDNs and browsers cache successful GET queries of public fields for this long.
+	// 0 leaves responses uncached.
+	GetMaxAge time.Duration
+	// PrincipalHeader names the request header identifying the caller. Cacheable responses vary on it,
+	// so a cache never serves the response of one caller to another. Optional.
+	PrincipalHeader string
+}
+
+// NewHandler builds the GraphQL HTTP handler served under /query.
+// It uses the same transports as handler.NewDefaultServer, plus depth, complexity and body size limits.
+func NewHandler(r *Resolver, opts Options) http.Handler {
+	limits := opts.Limits
+
+	srv := handler.New(NewExecutableSchema(Config{
+		Resolvers:  r,
+		Complexity: complexityRoot(),
+	}))
+	srv.SetErrorPresenter(ErrorPresenter)
+
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc:              closeOnShutdown(opts.Shutdown),
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{
+		MaxUploadSize: limits.MaxBodyBytes,
+	})
+
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+
+	srv.Use(extension.Introspection{})
+	srv.Use(opts.PersistedQueries.extension())
+
+	// Cheap depth check goes first, so deeply nested queries never reach the complexity calculation
+	srv.Use(DepthLimit{Max: limits.MaxDepth})
+	srv.Use(extension.FixedComplexityLimit(limits.MaxComplexity))
+	srv.Use(CostReport{})
+	for _, ext := range opts.Extensions {
+		srv.Use(ext)
+	}
+
+	h := http.MaxBytesHandler(srv, limits.MaxBodyBytes)
+	if opts.GetMaxAge > 0 {
+		srv.Use(publicOperations{})
+		h = cacheableGET(h, opts.GetMaxAge, opts.PrincipalHeader)
+	}
+	return h
+}
+
+// publicFields are the Query fields whose results are the same for every caller. Operations selecting
+// any other field, e.g. auditEvents or verifyLedger, are never cached.
+var publicFields = map[string]bool{
+	"__typename":    true,
+	"dummy":         true,
+	"wallet":        true,
+	"wallets":       true,
+	"searchWallets": true,
+	"transfer":      true,
+}
+
+// cacheability is set by publicOperations for the request served by cacheableGET.
+type cacheability struct {
+	public bool
+}
+
+type cacheabilityKey struct{}
+
+// cacheableGET marks successful responses to GET queries of public fields as cacheable for maxAge, so a
+// CDN can serve repeated queries (with APQ, the URL holds only the hash and the variables). Responses
+// with errors, e.g. PERSISTED_QUERY_NOT_FOUND, and the other operations are never cached. GET only
+// carries queries, mutations are refused.
+func cacheableGET(next http.Handler, maxAge time.Duration, principalHeader string) http.Handler {
+	cacheControl := fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Websocket upgrades are GET requests too, their connection is hijacked
+		if r.Method != http.MethodGet || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		c := &cacheability{}
+		rec := &bufferedResponse{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), cacheabilityKey{}, c)))
+
+		var body struct {
+			Errors json.RawMessage `json:"errors"`
+		}
+		if c.public && rec.status == http.StatusOK && json.Unmarshal(rec.body.Bytes(), &body) == nil && body.Errors == nil {
+			w.Header().Set("Cache-Control", cacheControl)
+		} else {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		if principalHeader != "" {
+			w.Header().Add("Vary", principalHeader)
+		}
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes())
+	})
+}
+
+// publicOperations tells cacheableGET whether the operation of its request selects public fields only.
+type publicOperations struct{}
+
+var _ interface {
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = publicOperations{}
+
+func (publicOperations) ExtensionName() string {
+	return "PublicOperations"
+}
+
+func (publicOperations) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (publicOperations) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	c, ok := ctx.Value(cacheabilityKey{}).(*cacheability)
+	if !ok {
+		return nil
+	}
+	op := opCtx.Doc.Operations.ForName(opCtx.OperationName)
+	c.public = op != nil && op.Operation == ast.Query && publicSelection(op.SelectionSet, opCtx.Doc.Fragments, map[string]bool{})
+	return nil
+}
+
+// publicSelection reports whether the root fields of set, through fragments, are all public fields.
+func publicSelection(set ast.SelectionSet, fragments ast.FragmentDefinitionList, visited map[string]bool) bool {
+	for _, sel := range set {
+		switch s := sel.(type) {
+		case *ast.Field:
+			if !publicFields[s.Name] {
+				return false
+			}
+		case *ast.InlineFragment:
+			if !publicSelection(s.SelectionSet, fragments, visited) {
+				return false
+			}
+		case *ast.FragmentSpread:
+			if visited[s.Name] {
+				continue
+			}
+			def := fragments.ForName(s.Name)
+			if def == nil {
+				return false
+			}
+			visited[s.Name] = true
+			if !publicSelection(def.SelectionSet, fragments, visited) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// bufferedResponse holds a response until its cacheability is known.
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+// closeOnShutdown returns a websocket InitFunc that ends the connection context when shutdown is done.
+// gqlgen then sends a normal closure ("terminated") to the client, which can reconnect to another instance.
+func closeOnShutdown(shutdown context.Context) transport.WebsocketInitFunc {
+	if shutdown == nil {
+		return nil
+	}
+	return func(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		stop := context.AfterFunc(shutdown, cancel)
+		// Don't keep the callback registered after the connection is gone
+		context.AfterFunc(ctx, func() { stop() })
+		return ctx, nil, nil
+	}
+}
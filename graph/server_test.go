@@ -0,0 +1,53 @@
+package graph
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 1. Websockets on shutdown
+// Goal: Open subscriptions are closed normally when the shutdown context is canceled.
+func TestNewHandler_ClosesWebsocketsOnShutdown(t *testing.T) {
+	shutdown, closeSubscriptions := context.WithCancel(context.Background())
+	defer closeSubscriptions()
+
+	srv := httptest.NewServer(NewHandler(&Resolver{}, Options{
+		Limits:   Limits{MaxDepth: 10, MaxComplexity: 100, MaxBodyBytes: 1 << 20},
+		Shutdown: shutdown,
+	}))
+	defer srv.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to open websocket: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON(map[string]any{"type": "connection_init"}); err != nil {
+		t.Fatalf("Failed to send connection_init: %v", err)
+	}
+	var ack map[string]any
+	if err := conn.ReadJSON(&ack); err != nil || ack["type"] != "connection_ack" {
+		t.Fatalf("Expected connection_ack, got %v, err: %v", ack, err)
+	}
+
+	closeSubscriptions()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			t.Errorf("Expected normal closure, got: %v", err)
+		}
+		return
+	}
+}
@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// getReport calls /readyz and decodes its body
+func getReport(t *testing.T, c *Checker) (int, Report) {
+	rec := httptest.NewRecorder()
+	c.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var report Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode report %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, report
+}
+
+// 1. All checks pass
+// Goal: Ready with 200 and every check listed.
+func TestReady_OK(t *testing.T) {
+	c := NewChecker(time.Second)
+	c.Add("database", func(ctx context.Context) error { return nil })
+	c.Add("workers", func(ctx context.Context) error { return nil })
+
+	code, report := getReport(t, c)
+	if code != http.StatusOK || report.Status != StatusOK {
+		t.Errorf("Expected 200 ok, got %d %s", code, report.Status)
+	}
+	if len(report.Checks) != 2 || report.Checks["database"].Status != StatusOK {
+		t.Errorf("Expected both checks reported ok, got %+v", report.Checks)
+	}
+}
+
+// 2. A failing and a hanging check
+// Goal: 503 with the error of each failed check; a hanging check is cut off by the timeout.
+func TestReady_Failures(t *testing.T) {
+	c := NewChecker(20 * time.Millisecond)
+	c.Add("database", func(ctx context.Context) error { return nil })
+	c.Add("migrations", func(ctx context.Context) error { return errors.New("schema is at version 1, expected 2") })
+	c.Add("stuck", func(ctx context.Context) error { select {} })
+
+	code, report := getReport(t, c)
+	if code != http.StatusServiceUnavailable || report.Status != StatusFail {
+		t.Errorf("Expected 503 fail, got %d %s", code, report.Status)
+	}
+	if r := report.Checks["migrations"]; r.Status != StatusFail || r.Error == "" {
+		t.Errorf("Expected migrations failure with error, got %+v", r)
+	}
+	if r := report.Checks["stuck"]; r.Status != StatusFail {
+		t.Errorf("Expected hanging check to fail on timeout, got %+v", r)
+	}
+	if r := report.Checks["database"]; r.Status != StatusOK {
+		t.Errorf("Expected database ok, got %+v", r)
+	}
+}
+
+// 3. Shutdown
+// Goal: Once draining, the instance is unready although its checks pass; liveness is unaffected.
+func TestReady_Draining(t *testing.T) {
+	c := NewChecker(time.Second)
+	c.Add("database", func(ctx context.Context) error { return nil })
+	c.SetDraining()
+
+	code, report := getReport(t, c)
+	if code != http.StatusServiceUnavailable || report.Status != StatusDraining {
+		t.Errorf("Expected 503 draining, got %d %s", code, report.Status)
+	}
+
+	rec := httptest.NewRecorder()
+	c.LiveHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected liveness 200 while draining, got %d", rec.Code)
+	}
+}
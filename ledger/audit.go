@@ -0,0 +1,224 @@
+package ledger
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Actor identifies who performs an operation. It is recorded with the operation in the audit log.
+type Actor struct {
+	Principal string
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+// PrincipalSystem is recorded for operations nobody asked for directly, e.g. the ones done at startup.
+const PrincipalSystem = "system"
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying the actor of the operations run with it.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor stored in ctx, PrincipalSystem if there is none.
+func ActorFrom(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	if actor.Principal == "" {
+		actor.Principal = PrincipalSystem
+	}
+	return actor
+}
+
+// Audited operations
+const (
+	OpTransfer     = "transfer"
+	OpCreateWallet = "create_wallet"
+	OpReshard      = "reshard"
+	OpApplyGenesis = "apply_genesis"
+	OpConfigure    = "configure"
+	OpUpdateWallet = "update_wallet"
+)
+
+// Outcomes of audited operations
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+// AuditEvent is one entry of the audit log.
+type AuditEvent struct {
+	ID         int64
+	OccurredAt time.Time
+	Principal  string
+	IP         string
+	UserAgent  string
+	RequestID  string
+	Operation  string
+	Arguments  map[string]any
+	Outcome    string
+	// ErrorCode is the code of a failed operation (see Code), CodeInternal for unexpected errors
+	ErrorCode string
+	Result    map[string]any
+}
+
+// AuditFilter selects audit events; zero fields match everything.
+type AuditFilter struct {
+	Principal string
+	Operation string
+	Outcome   string
+	// Address matches the "address", "from" and "to" arguments
+	Address string
+	Since   time.Time
+	Until   time.Time
+}
+
+// AuditLog reads the audit log.
+type AuditLog interface {
+	// AuditEvents returns up to limit events matching filter, newest first, starting below the
+	// event with ID before (0: from the newest one).
+	AuditEvents(ctx context.Context, filter AuditFilter, before int64, limit int) ([]AuditEvent, error)
+}
+
+// execer is *sql.DB or *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// RecordAudit appends operation, done by the actor of ctx, to the audit log. Pass the transaction
+// of the operation as q, so the event is committed (or rolled back) together with it.
+// opErr is the outcome of the operation.
+func RecordAudit(ctx context.Context, q execer, operation string, arguments, result map[string]any, opErr error) error {
+	actor := ActorFrom(ctx)
+	outcome, errorCode := OutcomeSuccess, ""
+	if opErr != nil {
+		outcome, errorCode = OutcomeError, CodeInternal
+		if code, _, ok := Code(opErr); ok {
+			errorCode = code
+		}
+	}
+
+	args, err := json.Marshal(arguments)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit arguments: %w", err)
+	}
+	// Strings, lib/pq would send []byte as bytea
+	var res sql.NullString
+	if result != nil {
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit result: %w", err)
+		}
+		res = sql.NullString{String: string(encoded), Valid: true}
+	}
+
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO audit_events (principal, ip, user_agent, request_id, operation, arguments, outcome, error_code, result)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, actor.Principal, actor.IP, actor.UserAgent, actor.RequestID, operation, string(args), outcome, errorCode, res)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	return nil
+}
+
+// auditFailure records a failed operation once its transaction has been rolled back.
+// The caller gets the error of the operation either way, a failure to record it is only logged.
+func (s *PostgresStore) auditFailure(ctx context.Context, operation string, arguments map[string]any, opErr error) {
+	// The caller may be gone (that can be the very reason of the failure), its actor still counts
+	ctx = context.WithoutCancel(ctx)
+	if err := RecordAudit(ctx, s.DB, operation, arguments, nil, opErr); err != nil {
+		slog.ErrorContext(ctx, "ledger: failed to audit a failed operation", "operation", operation, "error", err)
+	}
+}
+
+func transferArguments(fromAddress, toAddress string, amount int64) map[string]any {
+	return map[string]any{"from": fromAddress, "to": toAddress, "amount": amount}
+}
+
+// AuditEvents implements AuditLog.
+func (s *PostgresStore) AuditEvents(ctx context.Context, filter AuditFilter, before int64, limit int) ([]AuditEvent, error) {
+	var where []string
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(condition, len(args)))
+	}
+	if before > 0 {
+		add("id < $%d", before)
+	}
+	if filter.Principal != "" {
+		add("principal = $%d", filter.Principal)
+	}
+	if filter.Operation != "" {
+		add("operation = $%d", filter.Operation)
+	}
+	if filter.Outcome != "" {
+		add("outcome = $%d", filter.Outcome)
+	}
+	if filter.Address != "" {
+		add(`(arguments @> jsonb_build_object('address', $%[1]d::text)
+			OR arguments @> jsonb_build_object('from', $%[1]d::text)
+			OR arguments @> jsonb_build_object('to', $%[1]d::text))`, strings.ToLower(filter.Address))
+	}
+	if !filter.Since.IsZero() {
+		add("occurred_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		add("occurred_at < $%d", filter.Until)
+	}
+
+	query := `
+		SELECT id, occurred_at, principal, ip, user_agent, request_id, operation, arguments, outcome, error_code, result
+		FROM audit_events`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []AuditEvent
+	for rows.Next() {
+		var e AuditEvent
+		var arguments, result []byte
+		err := rows.Scan(&e.ID, &e.OccurredAt, &e.Principal, &e.IP, &e.UserAgent, &e.RequestID,
+			&e.Operation, &arguments, &e.Outcome, &e.ErrorCode, &result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit events: %w", err)
+		}
+		if err := decodeJSON(arguments, &e.Arguments); err != nil {
+			return nil, fmt.Errorf("failed to decode arguments of audit event %d: %w", e.ID, err)
+		}
+		if result != nil {
+			if err := decodeJSON(result, &e.Result); err != nil {
+				return nil, fmt.Errorf("failed to decode result of audit event %d: %w", e.ID, err)
+			}
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit events: %w", err)
+	}
+	return events, nil
+}
+
+// decodeJSON keeps numbers as json.Number, amounts and balances don't fit in a float64
+func decodeJSON(data []byte, v *map[string]any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
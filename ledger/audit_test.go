@@ -0,0 +1,148 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// postgresOf returns the PostgreSQL store behind store
+func postgresOf(t *testing.T, store Store) *PostgresStore {
+	switch s := store.(type) {
+	case *PostgresStore:
+		return s
+	case *Batcher:
+		return postgresOf(t, s.store)
+	default:
+		t.Fatalf("postgresOf: unsupported store %T", store)
+		return nil
+	}
+}
+
+// testActor returns a context with an actor whose principal is unique to this run,
+// the audit log cannot be cleaned between tests
+func testActor(t *testing.T) (context.Context, string) {
+	principal := fmt.Sprintf("test:%s:%d", t.Name(), time.Now().UnixNano())
+	ctx := WithActor(context.Background(), Actor{Principal: principal, IP: "203.0.113.7", UserAgent: "go-test", RequestID: "req-1"})
+	return ctx, principal
+}
+
+// 1. Transfers are audited
+// Goal: Successful and failed transfers are recorded with the actor, arguments and outcome, in every mode.
+func TestAudit_Transfer(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		if _, ok := store.(*MemoryStore); ok {
+			t.Skip("The in-memory store has no audit log")
+		}
+		ctx, principal := testActor(t)
+		resetWallet(t, store, "0xaudit", 100)
+
+		if _, err := store.Transfer(ctx, "0xaudit", "0xaudited", 30); err != nil {
+			t.Fatalf("Transfer failed: %v", err)
+		}
+		if _, err := store.Transfer(ctx, "0xaudit", "0xaudited", 500); err == nil {
+			t.Fatalf("Expected insufficient balance")
+		}
+
+		events, err := postgresOf(t, store).AuditEvents(context.Background(), AuditFilter{Principal: principal}, 0, 10)
+		if err != nil {
+			t.Fatalf("AuditEvents failed: %v", err)
+		}
+		if len(events) != 2 {
+			t.Fatalf("Expected 2 events, got %d: %+v", len(events), events)
+		}
+
+		failed, succeeded := events[0], events[1]
+		if failed.Outcome != OutcomeError || failed.ErrorCode != CodeInsufficientBalance || failed.Result != nil {
+			t.Errorf("Expected a failed transfer without result, got: %+v", failed)
+		}
+		if succeeded.Outcome != OutcomeSuccess || succeeded.ErrorCode != "" {
+			t.Errorf("Expected a successful transfer, got: %+v", succeeded)
+		}
+		if fmt.Sprint(succeeded.Result["balance"]) != "70" {
+			t.Errorf("Expected balance 70 in the result, got: %v", succeeded.Result)
+		}
+		args := succeeded.Arguments
+		if args["from"] != "0xaudit" || args["to"] != "0xaudited" || fmt.Sprint(args["amount"]) != "30" {
+			t.Errorf("Unexpected arguments: %v", args)
+		}
+		if succeeded.Operation != OpTransfer || succeeded.IP != "203.0.113.7" || succeeded.UserAgent != "go-test" || succeeded.RequestID != "req-1" {
+			t.Errorf("Unexpected actor or operation: %+v", succeeded)
+		}
+	})
+}
+
+// 2. Filters and paging
+// Goal: Events can be selected by operation, outcome and address, and paged with before.
+func TestAudit_Filters(t *testing.T) {
+	store := NewPostgresStore(getDB(t))
+	ctx, principal := testActor(t)
+
+	if err := store.CreateWallet(ctx, "0xfiltered", 10); err != nil {
+		t.Fatalf("CreateWallet failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := store.Transfer(ctx, "0xfiltered", "0xother", 1); err != nil {
+			t.Fatalf("Transfer failed: %v", err)
+		}
+	}
+	if err := store.CreateWallet(ctx, "0xfiltered", 10); err == nil {
+		t.Fatalf("Expected wallet exists")
+	}
+
+	count := func(filter AuditFilter) int {
+		filter.Principal = principal
+		events, err := store.AuditEvents(context.Background(), filter, 0, 100)
+		if err != nil {
+			t.Fatalf("AuditEvents failed: %v", err)
+		}
+		return len(events)
+	}
+	if n := count(AuditFilter{Operation: OpCreateWallet}); n != 2 {
+		t.Errorf("Expected 2 create_wallet events, got %d", n)
+	}
+	if n := count(AuditFilter{Outcome: OutcomeError}); n != 1 {
+		t.Errorf("Expected 1 failed event, got %d", n)
+	}
+	if n := count(AuditFilter{Address: "0xOTHER"}); n != 3 {
+		t.Errorf("Expected 3 events for 0xother, got %d", n)
+	}
+	if n := count(AuditFilter{Until: time.Now().Add(-time.Hour)}); n != 0 {
+		t.Errorf("Expected no events an hour ago, got %d", n)
+	}
+
+	first, err := store.AuditEvents(context.Background(), AuditFilter{Principal: principal}, 0, 2)
+	if err != nil || len(first) != 2 {
+		t.Fatalf("Expected a first page of 2, got %d (%v)", len(first), err)
+	}
+	rest, err := store.AuditEvents(context.Background(), AuditFilter{Principal: principal}, first[1].ID, 100)
+	if err != nil || len(rest) != 3 {
+		t.Fatalf("Expected 3 more events, got %d (%v)", len(rest), err)
+	}
+	if rest[0].ID >= first[1].ID {
+		t.Errorf("Expected the next page below %d, got %d", first[1].ID, rest[0].ID)
+	}
+}
+
+// 3. Append-only
+// Goal: Recorded events cannot be changed or removed.
+func TestAudit_AppendOnly(t *testing.T) {
+	db := getDB(t)
+	ctx, _ := testActor(t)
+	if err := RecordAudit(ctx, db, OpConfigure, map[string]any{"test": true}, nil, nil); err != nil {
+		t.Fatalf("RecordAudit failed: %v", err)
+	}
+
+	for _, statement := range []string{
+		"UPDATE audit_events SET principal = 'someone else'",
+		"DELETE FROM audit_events",
+		"TRUNCATE audit_events",
+	} {
+		_, err := db.Exec(statement)
+		if err == nil || !strings.Contains(err.Error(), "append-only") {
+			t.Errorf("Expected %q to be rejected, got: %v", statement, err)
+		}
+	}
+}
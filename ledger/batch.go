@@ -0,0 +1,288 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrBatcherClosed is returned by Batcher.Transfer after Close was called.
+var ErrBatcherClosed = errors.New("transfer queue is closed")
+
+// BatchOptions configure the group-commit pipeline.
+type BatchOptions struct {
+	// MaxSize is the maximum number of transfers applied in one database transaction
+	MaxSize int
+	// MaxWait is how long the first transfer of a batch waits for others to join it
+	MaxWait time.Duration
+}
+
+// Batcher is a Store that trades a few milliseconds of latency for throughput ("group commit").
+// Concurrent transfers are queued, collected into micro-batches and each batch is applied
+// in a single transaction. Every transfer runs under its own savepoint, so a failing transfer
+// (e.g. insufficient balance) is rolled back alone and every caller gets its own result.
+//
+// A batch locks all its wallets (the slots of hot ones) in alphabetical order before applying any
+// transfer, the order every transfer locks its wallets in, so it can't deadlock with them.
+type Batcher struct {
+	store *PostgresStore
+	opts  BatchOptions
+
+	requests chan *batchRequest
+	closing  chan struct{}
+	stopped  chan struct{}
+
+	closeOnce sync.Once
+	// mu guards sends to requests against Close
+	mu sync.RWMutex
+}
+
+type batchRequest struct {
+	ctx         context.Context
+	fromAddress string
+	toAddress   string
+	amount      int64
+	result      chan batchResult
+}
+
+type batchResult struct {
+	balance int64
+	err     error
+}
+
+// NewBatcher starts the batching worker in front of store. Call Close to stop it.
+func NewBatcher(store *PostgresStore, opts BatchOptions) *Batcher {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = 1
+	}
+	b := &Batcher{
+		store:    store,
+		opts:     opts,
+		requests: make(chan *batchRequest, opts.MaxSize),
+		closing:  make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Transfer queues a transfer and waits until the batch containing it is committed.
+// If ctx is canceled while the transfer is waiting in the queue it is skipped; once its batch is
+// being applied the outcome is decided by the batch, even if the caller has stopped waiting.
+func (b *Batcher) Transfer(ctx context.Context, fromAddress, toAddress string, amount int64) (int64, error) {
+	balance, queued, err := b.transfer(ctx, fromAddress, toAddress, amount)
+	// Queued transfers are audited by their batch
+	if err != nil && !queued {
+		b.store.auditFailure(ctx, OpTransfer, transferArguments(fromAddress, toAddress, amount), err)
+	}
+	return balance, err
+}
+
+// transfer is Transfer; queued reports whether the request reached the queue.
+func (b *Batcher) transfer(ctx context.Context, fromAddress, toAddress string, amount int64) (balance int64, queued bool, err error) {
+	if err := validateTransfer(fromAddress, toAddress, amount); err != nil {
+		return 0, false, err
+	}
+
+	// Self-transfer doesn't write anything, no need to queue it
+	if fromAddress == toAddress {
+		balance, err := b.store.Balance(ctx, fromAddress)
+		return balance, false, err
+	}
+
+	req := &batchRequest{
+		ctx:         ctx,
+		fromAddress: fromAddress,
+		toAddress:   toAddress,
+		amount:      amount,
+		result:      make(chan batchResult, 1),
+	}
+
+	b.mu.RLock()
+	select {
+	case <-b.closing:
+		b.mu.RUnlock()
+		return 0, false, ErrBatcherClosed
+	default:
+	}
+	select {
+	case b.requests <- req:
+		b.mu.RUnlock()
+	case <-ctx.Done():
+		b.mu.RUnlock()
+		return 0, false, ctx.Err()
+	}
+
+	select {
+	case res := <-req.result:
+		return res.balance, true, res.err
+	case <-ctx.Done():
+		return 0, true, ctx.Err()
+	}
+}
+
+// Balance reads directly from the underlying store.
+func (b *Batcher) Balance(ctx context.Context, address string) (int64, error) {
+	return b.store.Balance(ctx, address)
+}
+
+// TransferSigned writes directly to the underlying store, signed transfers are not batched.
+func (b *Batcher) TransferSigned(ctx context.Context, t SignedTransfer) (int64, error) {
+	return b.store.TransferSigned(ctx, t)
+}
+
+// CreateWallet writes directly to the underlying store.
+func (b *Batcher) CreateWallet(ctx context.Context, address string, balance int64) error {
+	return b.store.CreateWallet(ctx, address, balance)
+}
+
+// Close stops accepting transfers, applies the ones already queued and waits for the worker to exit.
+func (b *Batcher) Close() error {
+	b.closeOnce.Do(func() {
+		// Wait for senders in flight, then nobody can send anymore
+		b.mu.Lock()
+		close(b.closing)
+		close(b.requests)
+		b.mu.Unlock()
+	})
+	<-b.stopped
+	return nil
+}
+
+// run collects requests into batches until the queue is closed and drained.
+func (b *Batcher) run() {
+	defer close(b.stopped)
+
+	for first := range b.requests {
+		batch := []*batchRequest{first}
+		timer := time.NewTimer(b.opts.MaxWait)
+
+	collect:
+		for len(batch) < b.opts.MaxSize {
+			select {
+			case req, ok := <-b.requests:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, req)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		b.apply(batch)
+	}
+}
+
+// apply runs a batch in one transaction and delivers a result to every request.
+// Each transfer is audited in the batch transaction with the actor of its caller;
+// if the batch fails, its transfers are audited as failed afterwards.
+func (b *Batcher) apply(batch []*batchRequest) {
+	// The batch outlives any single caller, so it doesn't use their contexts for the transaction
+	ctx := context.Background()
+	results := make([]batchResult, len(batch))
+
+	err := b.store.inTx(ctx, func(tx *sql.Tx) error {
+		// The whole batch may be retried, start from scratch every time
+		clear(results)
+
+		if err := b.lockWallets(ctx, tx, batch); err != nil {
+			return err
+		}
+
+		for i, req := range batch {
+			// Carries the actor of the caller, but not its cancellation
+			actorCtx := context.WithoutCancel(req.ctx)
+			arguments := transferArguments(req.fromAddress, req.toAddress, req.amount)
+
+			// Caller gave up while waiting in the queue
+			if err := req.ctx.Err(); err != nil {
+				results[i].err = err
+				if err := RecordAudit(actorCtx, tx, OpTransfer, arguments, nil, err); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_item"); err != nil {
+				return fmt.Errorf("failed to create savepoint: %w", err)
+			}
+
+			balance, err := b.store.transferInTx(ctx, tx, req.fromAddress, req.toAddress, req.amount)
+			if err != nil {
+				// Serialization failures and deadlocks abort the whole transaction, retry the batch
+				if _, retryable := retryableState(err); retryable {
+					return err
+				}
+				if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_item"); rbErr != nil {
+					return fmt.Errorf("failed to roll back savepoint: %w", rbErr)
+				}
+				results[i].err = err
+			} else {
+				results[i].balance = balance
+			}
+			var result map[string]any
+			if err == nil {
+				result = map[string]any{"balance": balance}
+			}
+			if err := RecordAudit(actorCtx, tx, OpTransfer, arguments, result, err); err != nil {
+				return err
+			}
+
+			if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_item"); err != nil {
+				return fmt.Errorf("failed to release savepoint: %w", err)
+			}
+		}
+		return nil
+	})
+
+	for i, req := range batch {
+		res := results[i]
+		// Nothing was committed: transfers that had succeeded inside the batch failed after all
+		if err != nil && res.err == nil {
+			res = batchResult{err: err}
+		}
+		if err != nil {
+			b.store.auditFailure(req.ctx, OpTransfer, transferArguments(req.fromAddress, req.toAddress, req.amount), res.err)
+		}
+		req.result <- res
+	}
+}
+
+// lockWallets locks the existing wallets of a batch in alphabetical order, and all slots of the hot ones
+// in place of their row. The transfers of the batch then only take locks the batch already holds, except
+// on receivers they create.
+func (b *Batcher) lockWallets(ctx context.Context, tx *sql.Tx, batch []*batchRequest) error {
+	var addresses []string
+	for _, req := range batch {
+		if req.ctx.Err() == nil {
+			addresses = append(addresses, req.fromAddress, req.toAddress)
+		}
+	}
+	slices.Sort(addresses)
+	addresses = slices.Compact(addresses)
+
+	lockStart := time.Now()
+	for _, address := range addresses {
+		if b.store.isHot(address) {
+			slots, _, err := lockAllSlots(ctx, tx, address)
+			if err != nil {
+				return err
+			}
+			if slots > 0 {
+				continue
+			}
+			// Not sharded yet, its transfers use the row
+		}
+		if _, err := tx.ExecContext(ctx, "SELECT 1 FROM wallets WHERE address = $1 FOR UPDATE", address); err != nil {
+			return fmt.Errorf("failed to lock wallet %s: %w", address, err)
+		}
+	}
+	b.store.observeLockWait(lockStart)
+	return nil
+}
@@ -0,0 +1,142 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 1. Failure isolation
+// Goal: Failing transfers in a batch are rolled back alone, the others are committed.
+func TestBatcher_IsolatesFailures(t *testing.T) {
+	store := NewPostgresStore(getDB(t))
+	// Long wait, so all transfers below end up in the same batch
+	batcher := NewBatcher(store, BatchOptions{MaxSize: 4, MaxWait: time.Second})
+	defer batcher.Close()
+
+	resetWallet(t, store, "0xrich", 100)
+	resetWallet(t, store, "0xpoor", 1)
+
+	type outcome struct {
+		balance int64
+		err     error
+	}
+	transfers := []struct{ from, to string }{
+		{"0xrich", "0xa"},  // ok
+		{"0xpoor", "0xb"},  // insufficient balance
+		{"0xghost", "0xc"}, // sender does not exist
+		{"0xrich", "0xd"},  // ok
+	}
+	outcomes := make([]outcome, len(transfers))
+
+	var wg sync.WaitGroup
+	for i, tr := range transfers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			balance, err := batcher.Transfer(context.Background(), tr.from, tr.to, 10)
+			outcomes[i] = outcome{balance, err}
+		}()
+	}
+	wg.Wait()
+
+	if outcomes[0].err != nil || outcomes[3].err != nil {
+		t.Fatalf("Expected successful transfers, got: %v, %v", outcomes[0].err, outcomes[3].err)
+	}
+	if !errors.Is(outcomes[1].err, ErrInsufficientBalance) {
+		t.Errorf("Expected ErrInsufficientBalance, got: %v", outcomes[1].err)
+	}
+	if !errors.Is(outcomes[2].err, ErrWalletNotFound) {
+		t.Errorf("Expected ErrWalletNotFound, got: %v", outcomes[2].err)
+	}
+
+	if balance := mustBalance(t, store, "0xrich"); balance != 80 {
+		t.Errorf("Expected sender balance 80, got %d", balance)
+	}
+	if _, err := store.Balance(context.Background(), "0xb"); !errors.Is(err, ErrWalletNotFound) {
+		t.Errorf("Receiver of a failed transfer must not be created, got: %v", err)
+	}
+}
+
+// 2. Shutdown
+// Goal: Close applies queued transfers, later ones are refused.
+func TestBatcher_Close(t *testing.T) {
+	store := NewPostgresStore(getDB(t))
+	batcher := NewBatcher(store, BatchOptions{MaxSize: 100, MaxWait: 50 * time.Millisecond})
+
+	resetWallet(t, store, "0xsender", 10)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := batcher.Transfer(context.Background(), "0xsender", "0xreceiver", 10)
+		done <- err
+	}()
+
+	// Let the transfer enter the queue, then close while the batch is still collecting
+	time.Sleep(10 * time.Millisecond)
+	if err := batcher.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Queued transfer should be applied on Close, got: %v", err)
+	}
+
+	if _, err := batcher.Transfer(context.Background(), "0xreceiver", "0xsender", 1); !errors.Is(err, ErrBatcherClosed) {
+		t.Errorf("Expected ErrBatcherClosed after Close, got: %v", err)
+	}
+}
+
+// retryCounter counts the transactions retried by a store
+type retryCounter struct {
+	mu      sync.Mutex
+	retries []string
+}
+
+func (r *retryCounter) LockWait(time.Duration) {}
+
+func (r *retryCounter) TxRetried(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retries = append(r.retries, reason)
+}
+
+// 3. Lock order
+// Goal: Batches lock their wallets in the order of other transfers, so crossing transfers never deadlock.
+func TestBatcher_LockOrder(t *testing.T) {
+	store := NewPostgresStore(getDB(t))
+	retries := &retryCounter{}
+	store.Observer = retries
+	batcher := NewBatcher(store, BatchOptions{MaxSize: 4, MaxWait: 5 * time.Millisecond})
+	defer batcher.Close()
+
+	wallets := []string{"0xlock1", "0xlock2", "0xlock3", "0xlock4"}
+	for _, address := range wallets {
+		resetWallet(t, store, address, 1000)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		from, to := wallets[i%4], wallets[3-i%4]
+		wg.Add(2)
+		// Batched in descending order, unbatched the other way around
+		go func() {
+			defer wg.Done()
+			if _, err := batcher.Transfer(context.Background(), to, from, 1); err != nil {
+				t.Errorf("Batched transfer failed: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := store.Transfer(context.Background(), from, to, 1); err != nil {
+				t.Errorf("Transfer failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(retries.retries) > 0 {
+		t.Errorf("Expected no retried transaction, got %v", retries.retries)
+	}
+}
@@ -0,0 +1,82 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+)
+
+// Benchmarks compare the transaction modes of PostgresStore.
+// Run with: go test ./ledger -run '^$' -bench Transfer -benchtime 5s
+//
+// ModeSingleStatement should win whenever round-trip latency dominates,
+// i.e. the further the database is from the application.
+
+const benchWallets = 100
+
+// benchModes are the PostgresStore modes under comparison
+var benchModes = []string{ModeLocking, ModeSerializable, ModeSingleStatement}
+
+// newBenchStore returns a store in the given mode with benchWallets funded wallets
+func newBenchStore(b *testing.B, mode string) *PostgresStore {
+	if testDB == nil {
+		b.Skip("PostgreSQL not available (set TEST_DATABASE_URL or start docker-compose)")
+	}
+	cleanTestDB(b, testDB)
+
+	store := NewPostgresStore(testDB)
+	store.Mode = mode
+	store.Retry.MaxAttempts = 1000
+	for i := 0; i < benchWallets; i++ {
+		if err := store.CreateWallet(context.Background(), benchAddress(i), 1<<40); err != nil {
+			b.Fatalf("Failed to create wallet: %v", err)
+		}
+	}
+	return store
+}
+
+func benchAddress(i int) string {
+	return fmt.Sprintf("0xbench%03d", i)
+}
+
+// BenchmarkTransfer_Spread sends 1 token between random wallets, so contention is low
+// and the cost of round-trips is what's being measured.
+func BenchmarkTransfer_Spread(b *testing.B) {
+	for _, mode := range benchModes {
+		b.Run(mode, func(b *testing.B) {
+			store := newBenchStore(b, mode)
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					from := rand.IntN(benchWallets)
+					to := (from + 1 + rand.IntN(benchWallets-1)) % benchWallets
+					if _, err := store.Transfer(context.Background(), benchAddress(from), benchAddress(to), 1); err != nil {
+						b.Errorf("Transfer failed: %v", err)
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkTransfer_HotWallet pays out from a single wallet, so every transfer waits for the same row lock.
+// Fewer round-trips mean the lock is held for a shorter time.
+func BenchmarkTransfer_HotWallet(b *testing.B) {
+	for _, mode := range benchModes {
+		b.Run(mode, func(b *testing.B) {
+			store := newBenchStore(b, mode)
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					to := 1 + rand.IntN(benchWallets-1)
+					if _, err := store.Transfer(context.Background(), benchAddress(0), benchAddress(to), 1); err != nil {
+						b.Errorf("Transfer failed: %v", err)
+					}
+				}
+			})
+		})
+	}
+}
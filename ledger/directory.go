@@ -0,0 +1,243 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Metadata is what the owner of a wallet tells about it. It plays no part in transfers.
+type Metadata struct {
+	Label string   `json:"label"`
+	Tags  []string `json:"tags"`
+	// Attributes is a free-form JSON object
+	Attributes map[string]any `json:"attributes"`
+}
+
+// MetadataUpdate changes the metadata of a wallet. Nil fields are left as they are;
+// an empty Tags or Attributes clears them.
+type MetadataUpdate struct {
+	Label      *string
+	Tags       []string
+	Attributes map[string]any
+}
+
+// WalletFilter selects wallets. Zero fields match every wallet.
+type WalletFilter struct {
+	// Query matches the start of the address, or the label: a part of it or similar words (trigrams)
+	Query string
+	// Tags must all be set on the wallet
+	Tags       []string
+	MinBalance *int64
+	MaxBalance *int64
+}
+
+// WalletMatch is a wallet found by SearchWallets.
+type WalletMatch struct {
+	Address  string
+	Balance  int64
+	Metadata Metadata
+}
+
+// Directory keeps the metadata of wallets and searches them.
+type Directory interface {
+	// UpdateMetadata changes the metadata of an existing wallet and returns the result.
+	UpdateMetadata(ctx context.Context, address string, update MetadataUpdate) (Metadata, error)
+	// Metadata returns the metadata of the existing wallets among addresses; unknown ones are left out.
+	Metadata(ctx context.Context, addresses []string) (map[string]Metadata, error)
+	// SearchWallets returns up to limit wallets matching filter, by address, starting after the
+	// address after (empty: from the first one).
+	SearchWallets(ctx context.Context, filter WalletFilter, after string, limit int) ([]WalletMatch, error)
+}
+
+// UpdateMetadata implements Directory.
+func (s *PostgresStore) UpdateMetadata(ctx context.Context, address string, update MetadataUpdate) (Metadata, error) {
+	arguments := metadataArguments(address, update)
+	metadata, err := s.updateMetadata(ctx, address, update, arguments)
+	if err != nil {
+		s.auditFailure(ctx, OpUpdateWallet, arguments, err)
+	}
+	return metadata, err
+}
+
+func (s *PostgresStore) updateMetadata(ctx context.Context, address string, update MetadataUpdate, arguments map[string]any) (Metadata, error) {
+	var label sql.NullString
+	if update.Label != nil {
+		label = sql.NullString{String: *update.Label, Valid: true}
+	}
+	var tags any
+	if update.Tags != nil {
+		tags = pq.Array(update.Tags)
+	}
+	// Strings, lib/pq would send []byte as bytea
+	var attributes sql.NullString
+	if update.Attributes != nil {
+		encoded, err := json.Marshal(update.Attributes)
+		if err != nil {
+			return Metadata{}, fmt.Errorf("failed to encode attributes: %w", err)
+		}
+		attributes = sql.NullString{String: string(encoded), Valid: true}
+	}
+
+	var metadata Metadata
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx, `
+			UPDATE wallets SET
+				label = COALESCE($2, label),
+				tags = COALESCE($3::text[], tags),
+				attributes = COALESCE($4::jsonb, attributes)
+			WHERE address = $1
+			RETURNING label, tags, attributes
+		`, address, label, tags, attributes)
+		var columns metadataColumns
+		err := row.Scan(columns.dest()...)
+		if err == sql.ErrNoRows {
+			return &WalletNotFoundError{Address: address}
+		}
+		if err == nil {
+			metadata, err = columns.metadata()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update wallet %s: %w", address, err)
+		}
+		return RecordAudit(ctx, tx, OpUpdateWallet, arguments, nil, nil)
+	})
+	return metadata, err
+}
+
+// metadataArguments are the audited arguments of an update: the address and the fields it changes.
+func metadataArguments(address string, update MetadataUpdate) map[string]any {
+	arguments := map[string]any{"address": address}
+	if update.Label != nil {
+		arguments["label"] = *update.Label
+	}
+	if update.Tags != nil {
+		arguments["tags"] = update.Tags
+	}
+	if update.Attributes != nil {
+		arguments["attributes"] = update.Attributes
+	}
+	return arguments
+}
+
+// Metadata implements Directory with a single query.
+func (s *PostgresStore) Metadata(ctx context.Context, addresses []string) (map[string]Metadata, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT address, label, tags, attributes FROM wallets WHERE address = ANY($1)", pq.Array(addresses))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wallet metadata: %w", err)
+	}
+	defer rows.Close()
+
+	metadata := make(map[string]Metadata, len(addresses))
+	for rows.Next() {
+		var address string
+		var columns metadataColumns
+		err := rows.Scan(append([]any{&address}, columns.dest()...)...)
+		if err == nil {
+			metadata[address], err = columns.metadata()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read wallet metadata: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read wallet metadata: %w", err)
+	}
+	return metadata, nil
+}
+
+// SearchWallets implements Directory. Each condition can use an index of its own (see migration 0010);
+// the balance bounds are checked on the matching wallets only, hot wallets keep theirs in slots.
+func (s *PostgresStore) SearchWallets(ctx context.Context, filter WalletFilter, after string, limit int) ([]WalletMatch, error) {
+	var where []string
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(condition, len(args)))
+	}
+	if after != "" {
+		add("address > $%d", after)
+	}
+	if filter.Query != "" {
+		args = append(args, escapeLike(strings.ToLower(filter.Query))+"%", "%"+escapeLike(filter.Query)+"%", filter.Query)
+		n := len(args)
+		where = append(where, fmt.Sprintf("(address LIKE $%d OR label ILIKE $%d OR $%d <%% label)", n-2, n-1, n))
+	}
+	if len(filter.Tags) > 0 {
+		add("tags @> $%d", pq.Array(filter.Tags))
+	}
+	if filter.MinBalance != nil {
+		add("balance >= $%d", *filter.MinBalance)
+	}
+	if filter.MaxBalance != nil {
+		add("balance <= $%d", *filter.MaxBalance)
+	}
+
+	// Flattened by the planner: conditions on the columns of wallets still reach its indexes
+	query := `
+		SELECT address, balance, label, tags, attributes FROM (
+			SELECT w.address, w.label, w.tags, w.attributes,
+				w.balance + COALESCE((SELECT SUM(s.balance) FROM wallet_shards s WHERE s.address = w.address), 0)::bigint AS balance
+			FROM wallets w
+		) w`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY address LIMIT $%d", len(args))
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search wallets: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []WalletMatch
+	for rows.Next() {
+		var m WalletMatch
+		var columns metadataColumns
+		err := rows.Scan(append([]any{&m.Address, &m.Balance}, columns.dest()...)...)
+		if err == nil {
+			m.Metadata, err = columns.metadata()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to search wallets: %w", err)
+		}
+		matches = append(matches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search wallets: %w", err)
+	}
+	return matches, nil
+}
+
+// metadataColumns receives the label, tags and attributes columns of a row.
+type metadataColumns struct {
+	label      string
+	tags       []string
+	attributes []byte
+}
+
+func (c *metadataColumns) dest() []any {
+	return []any{&c.label, pq.Array(&c.tags), &c.attributes}
+}
+
+func (c *metadataColumns) metadata() (Metadata, error) {
+	m := Metadata{Label: c.label, Tags: c.tags}
+	if m.Tags == nil {
+		m.Tags = []string{}
+	}
+	if err := decodeJSON(c.attributes, &m.Attributes); err != nil {
+		return m, fmt.Errorf("failed to decode attributes: %w", err)
+	}
+	return m, nil
+}
+
+// escapeLike makes the LIKE wildcards of s match themselves.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,100 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// 1. Metadata updates
+// Goal: Only the given fields change, empty ones clear them; unknown wallets are WalletNotFound.
+func TestUpdateMetadata(t *testing.T) {
+	store := NewPostgresStore(getDB(t))
+	ctx := context.Background()
+	if err := store.CreateWallet(ctx, "0xlabeled", 100); err != nil {
+		t.Fatalf("CreateWallet failed: %v", err)
+	}
+
+	label := "Treasury"
+	_, err := store.UpdateMetadata(ctx, "0xlabeled", MetadataUpdate{
+		Label: &label, Tags: []string{"ops", "cold"}, Attributes: map[string]any{"team": "finance"},
+	})
+	if err != nil {
+		t.Fatalf("UpdateMetadata failed: %v", err)
+	}
+	metadata, err := store.UpdateMetadata(ctx, "0xlabeled", MetadataUpdate{Tags: []string{}})
+	if err != nil {
+		t.Fatalf("UpdateMetadata failed: %v", err)
+	}
+	if metadata.Label != "Treasury" || len(metadata.Tags) != 0 || metadata.Attributes["team"] != "finance" {
+		t.Errorf("Expected the label and attributes kept and the tags cleared, got %+v", metadata)
+	}
+
+	read, err := store.Metadata(ctx, []string{"0xlabeled", "0xghost"})
+	if err != nil || len(read) != 1 || read["0xlabeled"].Label != "Treasury" {
+		t.Errorf("Expected the metadata of 0xlabeled only, got %+v (%v)", read, err)
+	}
+
+	if _, err := store.UpdateMetadata(ctx, "0xghost", MetadataUpdate{Label: &label}); !errors.Is(err, ErrWalletNotFound) {
+		t.Errorf("Expected ErrWalletNotFound, got: %v", err)
+	}
+}
+
+// 2. Search
+// Goal: Wallets match by address prefix, label (part or similar word), tags and balance, and are paged by address.
+func TestSearchWallets(t *testing.T) {
+	store := NewPostgresStore(getDB(t))
+	ctx := context.Background()
+	for _, w := range []struct {
+		address, label string
+		tags           []string
+		balance        int64
+	}{
+		{"0xaa01", "Treasury reserve", []string{"ops"}, 500},
+		{"0xaa02", "Market maker", []string{"ops", "hot"}, 50},
+		{"0xbb01", "Payroll", nil, 10},
+		{"0xbb02", "Treasury_old", []string{"hot"}, 0},
+	} {
+		if err := store.CreateWallet(ctx, w.address, w.balance); err != nil {
+			t.Fatalf("CreateWallet failed: %v", err)
+		}
+		if _, err := store.UpdateMetadata(ctx, w.address, MetadataUpdate{Label: &w.label, Tags: w.tags}); err != nil {
+			t.Fatalf("UpdateMetadata failed: %v", err)
+		}
+	}
+	addresses := func(filter WalletFilter, after string, limit int) []string {
+		matches, err := store.SearchWallets(ctx, filter, after, limit)
+		if err != nil {
+			t.Fatalf("SearchWallets failed: %v", err)
+		}
+		var found []string
+		for _, m := range matches {
+			found = append(found, m.Address)
+		}
+		return found
+	}
+	balance := func(b int64) *int64 { return &b }
+
+	cases := []struct {
+		name   string
+		filter WalletFilter
+		want   []string
+	}{
+		{"address prefix", WalletFilter{Query: "0xAA"}, []string{"0xaa01", "0xaa02"}},
+		{"part of the label", WalletFilter{Query: "treasury"}, []string{"0xaa01", "0xbb02"}},
+		{"similar word", WalletFilter{Query: "tresury"}, []string{"0xaa01", "0xbb02"}},
+		{"wildcards match themselves", WalletFilter{Query: "y_o"}, []string{"0xbb02"}},
+		{"all tags", WalletFilter{Tags: []string{"ops", "hot"}}, []string{"0xaa02"}},
+		{"balance range", WalletFilter{MinBalance: balance(10), MaxBalance: balance(100)}, []string{"0xaa02", "0xbb01"}},
+	}
+	for _, c := range cases {
+		if got := addresses(c.filter, "", 10); !slices.Equal(got, c.want) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+
+	if page := addresses(WalletFilter{}, "0xaa02", 1); len(page) != 1 || page[0] != "0xbb01" {
+		t.Errorf("Expected 0xbb01 after 0xaa02, got %v", page)
+	}
+}
@@ -0,0 +1,153 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Kinds of rows in the ledger_entries table, the history every balance can be rebuilt from
+const (
+	// EntryOpening is the balance a wallet held when entries started being recorded
+	EntryOpening = "opening"
+	// EntryMint creates tokens (genesis allocations, wallets created with a balance)
+	EntryMint = "mint"
+	// EntryBurn destroys tokens
+	EntryBurn = "burn"
+	// EntryTransfer moves tokens between two wallets
+	EntryTransfer = "transfer"
+)
+
+// mintWalletSQL creates a wallet unless it exists and records its balance as minted, in one statement.
+// It returns 1 when the wallet was created, 0 when it already existed.
+const mintWalletSQL = `
+	WITH created AS (
+		INSERT INTO wallets (address, balance) VALUES ($1, $2)
+		ON CONFLICT (address) DO NOTHING
+		RETURNING address, balance
+	), entry AS (
+		INSERT INTO ledger_entries (kind, to_address, amount)
+		SELECT 'mint', address, balance FROM created WHERE balance > 0
+	)
+	SELECT COUNT(*) FROM created
+`
+
+// MintWallet creates a wallet holding balance, newly minted tokens, inside tx.
+// It reports false, and changes nothing, when the wallet already exists.
+func MintWallet(ctx context.Context, tx *sql.Tx, address string, balance int64) (bool, error) {
+	var created int
+	if err := tx.QueryRowContext(ctx, mintWalletSQL, address, balance).Scan(&created); err != nil {
+		return false, fmt.Errorf("failed to create wallet %s: %w", address, err)
+	}
+	return created == 1, nil
+}
+
+// recordTransferEntry appends the entry of a transfer applied in tx. txHash is the hash of the
+// signed transaction the transfer came from, empty for the others.
+func recordTransferEntry(ctx context.Context, tx *sql.Tx, fromAddress, toAddress string, amount int64, txHash string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO ledger_entries (kind, from_address, to_address, amount, tx_hash) VALUES ('transfer', $1, $2, $3, NULLIF($4, ''))
+	`, fromAddress, toAddress, amount, txHash)
+	if err != nil {
+		return fmt.Errorf("failed to record ledger entry: %w", err)
+	}
+	return nil
+}
+
+// Entry is one row of the ledger_entries table. From is empty for openings and mints, To for burns.
+type Entry struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Kind      string    `json:"kind"`
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to,omitempty"`
+	Amount    int64     `json:"amount"`
+}
+
+// History reads the ledger entries of wallets.
+type History interface {
+	// WalletEntries returns up to limit entries sent or received by address, newest first,
+	// starting below the entry with ID before (0: from the newest one).
+	WalletEntries(ctx context.Context, address string, before int64, limit int) ([]Entry, error)
+	// WalletsEntries is WalletEntries for many addresses at once; addresses without entries are left out.
+	WalletsEntries(ctx context.Context, addresses []string, before int64, limit int) (map[string][]Entry, error)
+	// Entries returns the entries with the given IDs; unknown IDs are left out.
+	Entries(ctx context.Context, ids []int64) (map[int64]Entry, error)
+}
+
+// walletEntriesSQL reads each side through its own index and merges them, for every address of $1.
+// A wallet never sends to itself, so no entry shows up on both sides.
+const walletEntriesSQL = `
+	SELECT a.address, e.id, e.created_at, e.kind, COALESCE(e.from_address, ''), COALESCE(e.to_address, ''), e.amount
+	FROM unnest($1::text[]) AS a(address)
+	CROSS JOIN LATERAL (
+		SELECT * FROM (
+			(SELECT * FROM ledger_entries WHERE from_address = a.address AND id < $2 ORDER BY id DESC LIMIT $3)
+			UNION ALL
+			(SELECT * FROM ledger_entries WHERE to_address = a.address AND id < $2 ORDER BY id DESC LIMIT $3)
+		) u
+		ORDER BY id DESC LIMIT $3
+	) e
+	ORDER BY a.address, e.id DESC
+`
+
+// WalletEntries implements History.
+func (s *PostgresStore) WalletEntries(ctx context.Context, address string, before int64, limit int) ([]Entry, error) {
+	entries, err := s.WalletsEntries(ctx, []string{address}, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	return entries[address], nil
+}
+
+// WalletsEntries implements History with a single query.
+func (s *PostgresStore) WalletsEntries(ctx context.Context, addresses []string, before int64, limit int) (map[string][]Entry, error) {
+	if before <= 0 {
+		before = math.MaxInt64
+	}
+	rows, err := s.DB.QueryContext(ctx, walletEntriesSQL, pq.Array(addresses), before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ledger entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make(map[string][]Entry, len(addresses))
+	for rows.Next() {
+		var address string
+		var e Entry
+		if err := rows.Scan(&address, &e.ID, &e.CreatedAt, &e.Kind, &e.From, &e.To, &e.Amount); err != nil {
+			return nil, fmt.Errorf("failed to read ledger entries: %w", err)
+		}
+		entries[address] = append(entries[address], e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ledger entries: %w", err)
+	}
+	return entries, nil
+}
+
+// Entries implements History with a single query.
+func (s *PostgresStore) Entries(ctx context.Context, ids []int64) (map[int64]Entry, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT "+entryColumns+" FROM ledger_entries WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ledger entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make(map[int64]Entry, len(ids))
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.CreatedAt, &e.Kind, &e.From, &e.To, &e.Amount); err != nil {
+			return nil, fmt.Errorf("failed to read ledger entries: %w", err)
+		}
+		entries[e.ID] = e
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ledger entries: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,105 @@
+package ledger
+
+import (
+	"context"
+	"testing"
+)
+
+// 1. Wallet history
+// Goal: WalletEntries returns the entries sent and received by a wallet, newest first, paged with before.
+func TestWalletEntries(t *testing.T) {
+	store := NewPostgresStore(getDB(t))
+	ctx := context.Background()
+	if err := store.CreateWallet(ctx, "0xhistory", 100); err != nil {
+		t.Fatalf("CreateWallet failed: %v", err)
+	}
+	if err := store.CreateWallet(ctx, "0xpeer", 100); err != nil {
+		t.Fatalf("CreateWallet failed: %v", err)
+	}
+	for _, tr := range []struct {
+		from, to string
+		amount   int64
+	}{{"0xhistory", "0xpeer", 10}, {"0xpeer", "0xhistory", 20}, {"0xpeer", "0xother", 5}, {"0xhistory", "0xother", 30}} {
+		if _, err := store.Transfer(ctx, tr.from, tr.to, tr.amount); err != nil {
+			t.Fatalf("Transfer failed: %v", err)
+		}
+	}
+
+	entries, err := store.WalletEntries(ctx, "0xhistory", 0, 10)
+	if err != nil {
+		t.Fatalf("WalletEntries failed: %v", err)
+	}
+	var amounts []int64
+	for _, e := range entries {
+		amounts = append(amounts, e.Amount)
+	}
+	// Transfers 30, 20 and 10, then the mint of the wallet
+	if len(entries) != 4 || amounts[0] != 30 || amounts[1] != 20 || amounts[2] != 10 || amounts[3] != 100 {
+		t.Fatalf("Expected amounts [30 20 10 100], got %v", amounts)
+	}
+	if mint := entries[3]; mint.Kind != EntryMint || mint.From != "" || mint.To != "0xhistory" {
+		t.Errorf("Expected the mint of 0xhistory, got %+v", mint)
+	}
+
+	page, err := store.WalletEntries(ctx, "0xhistory", entries[1].ID, 1)
+	if err != nil {
+		t.Fatalf("WalletEntries failed: %v", err)
+	}
+	if len(page) != 1 || page[0].ID != entries[2].ID {
+		t.Errorf("Expected the entry after %d, got %+v", entries[1].ID, page)
+	}
+}
+
+// 2. Many wallets
+// Goal: WalletsEntries reads the first page of many wallets with one query and leaves out wallets without entries.
+func TestWalletsEntries(t *testing.T) {
+	store := NewPostgresStore(getDB(t))
+	ctx := context.Background()
+	for _, address := range []string{"0xmany1", "0xmany2"} {
+		if err := store.CreateWallet(ctx, address, 100); err != nil {
+			t.Fatalf("CreateWallet failed: %v", err)
+		}
+	}
+	for _, amount := range []int64{10, 20} {
+		if _, err := store.Transfer(ctx, "0xmany1", "0xmany2", amount); err != nil {
+			t.Fatalf("Transfer failed: %v", err)
+		}
+	}
+
+	entries, err := store.WalletsEntries(ctx, []string{"0xmany1", "0xmany2", "0xnone"}, 0, 2)
+	if err != nil {
+		t.Fatalf("WalletsEntries failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected the entries of 2 wallets, got %+v", entries)
+	}
+	for _, address := range []string{"0xmany1", "0xmany2"} {
+		page := entries[address]
+		if len(page) != 2 || page[0].Amount != 20 || page[1].Amount != 10 {
+			t.Errorf("Expected transfers 20 and 10 for %s, got %+v", address, page)
+		}
+	}
+}
+
+// 3. Entries by ID
+// Goal: Entries reads many entries at once and leaves out the IDs that don't exist.
+func TestEntries(t *testing.T) {
+	store := NewPostgresStore(getDB(t))
+	ctx := context.Background()
+	if err := store.CreateWallet(ctx, "0xbyid", 100); err != nil {
+		t.Fatalf("CreateWallet failed: %v", err)
+	}
+	history, err := store.WalletEntries(ctx, "0xbyid", 0, 1)
+	if err != nil || len(history) != 1 {
+		t.Fatalf("Expected the mint of 0xbyid, got %+v (%v)", history, err)
+	}
+	mint := history[0]
+
+	entries, err := store.Entries(ctx, []int64{mint.ID, -1})
+	if err != nil {
+		t.Fatalf("Entries failed: %v", err)
+	}
+	if len(entries) != 1 || entries[mint.ID].Amount != 100 || entries[mint.ID].To != "0xbyid" {
+		t.Errorf("Expected the mint of 0xbyid by ID, got %+v", entries)
+	}
+}
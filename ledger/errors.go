@@ -0,0 +1,170 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors returned by the transfer logic.
+// Use errors.Is to match them; the typed errors below carry the details.
+var (
+	ErrInvalidAmount       = errors.New("transfer amount must be positive")
+	ErrInvalidAddress      = errors.New("invalid wallet address")
+	ErrWalletNotFound      = errors.New("wallet does not exist")
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrWalletExists        = errors.New("wallet already exists")
+	ErrTransactionConflict = errors.New("transaction conflict")
+	ErrInvalidNonce        = errors.New("invalid nonce")
+)
+
+// Stable codes returned to API clients (extensions.code in GraphQL errors).
+// Clients should match on these, never on error messages.
+const (
+	CodeInvalidAmount       = "INVALID_AMOUNT"
+	CodeInvalidAddress      = "INVALID_ADDRESS"
+	CodeWalletNotFound      = "WALLET_NOT_FOUND"
+	CodeInsufficientBalance = "INSUFFICIENT_BALANCE"
+	CodeWalletExists        = "WALLET_EXISTS"
+	CodeTransactionConflict = "TRANSACTION_CONFLICT"
+	CodeInvalidNonce        = "INVALID_NONCE"
+	CodeInternal            = "INTERNAL_SERVER_ERROR"
+)
+
+// InvalidAmountError is returned when a transfer amount is zero or negative.
+type InvalidAmountError struct {
+	Amount int64
+}
+
+func (e *InvalidAmountError) Error() string {
+	return fmt.Sprintf("%v, got: %d", ErrInvalidAmount, e.Amount)
+}
+
+func (e *InvalidAmountError) Is(target error) bool { return target == ErrInvalidAmount }
+
+// InvalidAddressError is returned when a wallet address is malformed.
+type InvalidAddressError struct {
+	Address string
+}
+
+func (e *InvalidAddressError) Error() string {
+	return fmt.Sprintf("%v: %q", ErrInvalidAddress, e.Address)
+}
+
+func (e *InvalidAddressError) Is(target error) bool { return target == ErrInvalidAddress }
+
+// WalletNotFoundError is returned when an operation requires a wallet that does not exist.
+type WalletNotFoundError struct {
+	Address string
+}
+
+func (e *WalletNotFoundError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrWalletNotFound, e.Address)
+}
+
+func (e *WalletNotFoundError) Is(target error) bool { return target == ErrWalletNotFound }
+
+// InsufficientBalanceError is returned when the sender cannot cover the transfer amount.
+type InsufficientBalanceError struct {
+	Address   string
+	Available int64
+	Requested int64
+}
+
+func (e *InsufficientBalanceError) Error() string {
+	return fmt.Sprintf("%v: wallet %s has %d, requested %d", ErrInsufficientBalance, e.Address, e.Available, e.Requested)
+}
+
+func (e *InsufficientBalanceError) Is(target error) bool { return target == ErrInsufficientBalance }
+
+// WalletExistsError is returned when creating a wallet under an address that is already taken.
+type WalletExistsError struct {
+	Address string
+}
+
+func (e *WalletExistsError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrWalletExists, e.Address)
+}
+
+func (e *WalletExistsError) Is(target error) bool { return target == ErrWalletExists }
+
+// TransactionConflictError is returned when a transaction kept conflicting with concurrent ones
+// and the retry budget ran out. The request can be safely repeated later.
+// The database error is kept for logging but not included in the message.
+type TransactionConflictError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *TransactionConflictError) Error() string {
+	return fmt.Sprintf("%v: gave up after %d attempts, try again later", ErrTransactionConflict, e.Attempts)
+}
+
+func (e *TransactionConflictError) Is(target error) bool { return target == ErrTransactionConflict }
+
+func (e *TransactionConflictError) Unwrap() error { return e.Err }
+
+// InvalidNonceError is returned when a signed transfer doesn't carry the sender's current nonce:
+// lower means it was already used, higher that earlier transactions are missing.
+type InvalidNonceError struct {
+	Address  string
+	Expected uint64
+	Got      uint64
+}
+
+func (e *InvalidNonceError) Error() string {
+	if e.Got < e.Expected {
+		return fmt.Sprintf("%v: nonce too low, wallet %s is at %d, got %d", ErrInvalidNonce, e.Address, e.Expected, e.Got)
+	}
+	return fmt.Sprintf("%v: nonce too high, wallet %s is at %d, got %d", ErrInvalidNonce, e.Address, e.Expected, e.Got)
+}
+
+func (e *InvalidNonceError) Is(target error) bool { return target == ErrInvalidNonce }
+
+// Code maps a domain error to its stable code and structured details.
+// ok is false for errors that are not part of the API contract (e.g. database failures).
+func Code(err error) (code string, details map[string]any, ok bool) {
+	var (
+		amountErr  *InvalidAmountError
+		addressErr *InvalidAddressError
+		notFound   *WalletNotFoundError
+		balanceErr *InsufficientBalanceError
+		existsErr  *WalletExistsError
+		conflict   *TransactionConflictError
+		nonceErr   *InvalidNonceError
+	)
+	switch {
+	case errors.As(err, &amountErr):
+		return CodeInvalidAmount, map[string]any{"amount": amountErr.Amount}, true
+	case errors.As(err, &addressErr):
+		return CodeInvalidAddress, map[string]any{"address": addressErr.Address}, true
+	case errors.As(err, &notFound):
+		return CodeWalletNotFound, map[string]any{"address": notFound.Address}, true
+	case errors.As(err, &balanceErr):
+		return CodeInsufficientBalance, map[string]any{
+			"address":   balanceErr.Address,
+			"available": balanceErr.Available,
+			"requested": balanceErr.Requested,
+		}, true
+	case errors.As(err, &existsErr):
+		return CodeWalletExists, map[string]any{"address": existsErr.Address}, true
+	case errors.As(err, &conflict):
+		return CodeTransactionConflict, map[string]any{"attempts": conflict.Attempts}, true
+	case errors.As(err, &nonceErr):
+		return CodeInvalidNonce, map[string]any{"address": nonceErr.Address, "expected": nonceErr.Expected, "got": nonceErr.Got}, true
+	case errors.Is(err, ErrInvalidAmount):
+		return CodeInvalidAmount, nil, true
+	case errors.Is(err, ErrInvalidAddress):
+		return CodeInvalidAddress, nil, true
+	case errors.Is(err, ErrWalletNotFound):
+		return CodeWalletNotFound, nil, true
+	case errors.Is(err, ErrInsufficientBalance):
+		return CodeInsufficientBalance, nil, true
+	case errors.Is(err, ErrWalletExists):
+		return CodeWalletExists, nil, true
+	case errors.Is(err, ErrTransactionConflict):
+		return CodeTransactionConflict, nil, true
+	case errors.Is(err, ErrInvalidNonce):
+		return CodeInvalidNonce, nil, true
+	}
+	return "", nil, false
+}
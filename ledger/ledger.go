@@ -0,0 +1,42 @@
+// Package ledger holds the wallet balances and the transfer logic.
+// It has no API logic; GraphQL resolvers and other front-ends talk to it through Store.
+package ledger
+
+import "context"
+
+// Store is the storage of wallets and their balances.
+// Implementations must be safe for concurrent use and must never let a balance go negative.
+type Store interface {
+	// Transfer moves amount from one wallet to another and returns the sender's new balance.
+	// The receiver wallet is created if it does not exist yet.
+	Transfer(ctx context.Context, fromAddress, toAddress string, amount int64) (int64, error)
+
+	// Balance returns the balance of an existing wallet.
+	Balance(ctx context.Context, address string) (int64, error)
+
+	// CreateWallet creates a wallet with an initial balance.
+	// It fails with ErrWalletExists if the address is already taken.
+	CreateWallet(ctx context.Context, address string, balance int64) error
+}
+
+// BalanceReader reads the balances of many wallets at once, e.g. for the batched lookups of the API.
+type BalanceReader interface {
+	// Balances returns the balances of the existing wallets among addresses; unknown ones are left out.
+	Balances(ctx context.Context, addresses []string) (map[string]int64, error)
+}
+
+// validateTransfer checks the arguments of a transfer before any storage is touched.
+func validateTransfer(fromAddress, toAddress string, amount int64) error {
+	// Positive amounts only
+	if amount <= 0 {
+		return &InvalidAmountError{Amount: amount}
+	}
+	// Both addresses are required
+	if fromAddress == "" {
+		return &InvalidAddressError{Address: fromAddress}
+	}
+	if toAddress == "" {
+		return &InvalidAddressError{Address: toAddress}
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// storeFactories build an empty store of every implementation.
+// Every test below runs against all of them, so both behave the same way.
+var storeFactories = []struct {
+	name string
+	new  func(t *testing.T) Store
+}{
+	{"memory", func(t *testing.T) Store { return NewMemoryStore() }},
+	{"postgres", func(t *testing.T) Store { return NewPostgresStore(getDB(t)) }},
+	{"postgres-serializable", func(t *testing.T) Store {
+		store := NewPostgresStore(getDB(t))
+		store.Mode = ModeSerializable
+		// The hammer test makes 100 transactions fight for one row, give them enough budget
+		store.Retry.MaxAttempts = 100
+		return store
+	}},
+	{"postgres-single", func(t *testing.T) Store {
+		store := NewPostgresStore(getDB(t))
+		store.Mode = ModeSingleStatement
+		return store
+	}},
+	{"postgres-batched", func(t *testing.T) Store {
+		batcher := NewBatcher(NewPostgresStore(getDB(t)), BatchOptions{MaxSize: 16, MaxWait: time.Millisecond})
+		t.Cleanup(func() { batcher.Close() })
+		return batcher
+	}},
+}
+
+// forEachStore runs fn as a subtest for every store implementation
+func forEachStore(t *testing.T, fn func(t *testing.T, store Store)) {
+	for _, f := range storeFactories {
+		t.Run(f.name, func(t *testing.T) {
+			fn(t, f.new(t))
+		})
+	}
+}
+
+// resetWallet inserts or updates a wallet to a specific balance for testing
+func resetWallet(t *testing.T, store Store, address string, balance int64) {
+	switch s := store.(type) {
+	case *MemoryStore:
+		s.mu.Lock()
+		s.balances[address] = balance
+		s.mu.Unlock()
+	case *Batcher:
+		resetWallet(t, s.store, address, balance)
+	case *PostgresStore:
+		_, err := s.DB.Exec(`
+			INSERT INTO wallets (address, balance) VALUES ($1, $2)
+			ON CONFLICT (address) DO UPDATE SET balance = $2
+		`, address, balance)
+		if err != nil {
+			t.Fatalf("Failed to reset wallet %s: %v", address, err)
+		}
+	default:
+		t.Fatalf("resetWallet: unsupported store %T", store)
+	}
+}
+
+// mustBalance reads a wallet balance and fails the test on error
+func mustBalance(t *testing.T, store Store, address string) int64 {
+	balance, err := store.Balance(context.Background(), address)
+	if err != nil {
+		t.Fatalf("Failed to verify balance of %s: %v", address, err)
+	}
+	return balance
+}
+
+// --- ACTUAL TESTS ---
+
+// 1. The "Hammer" Test: 100 concurrent threads withdraw 1 token each.
+// Goal: Verify locking mechanism works under high load.
+func TestConcurrent_Hammer(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		address := "0xhammer"
+		startBalance := int64(100)
+		resetWallet(t, store, address, startBalance)
+
+		var wg sync.WaitGroup
+		wg.Add(int(startBalance)) // 100 threads
+
+		for i := 0; i < int(startBalance); i++ {
+			go func() {
+				defer wg.Done()
+				_, err := store.Transfer(context.Background(), address, "0xreceiver", 1)
+				if err != nil {
+					t.Errorf("Unexpected error in hammer test: %v", err)
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		// Verify final balance
+		if finalBalance := mustBalance(t, store, address); finalBalance != 0 {
+			t.Errorf(" - Race Condition Detected! Expected 0, got %d", finalBalance)
+		}
+		if received := mustBalance(t, store, "0xreceiver"); received != startBalance {
+			t.Errorf(" - Lost update on receiver! Expected %d, got %d", startBalance, received)
+		}
+	})
+}
+
+// 2. Logic Test: Insufficient Funds
+// Goal: Verify that the system prevents spending more than you have.
+func TestLogic_InsufficientFunds(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		sender := "0xpoor"
+		resetWallet(t, store, sender, 10) // Wallet has 10
+
+		// Try to send 20
+		_, err := store.Transfer(context.Background(), sender, "0xrich", 20)
+
+		var balanceErr *InsufficientBalanceError
+		if err == nil {
+			t.Fatalf(" - Error expected but transfer succeeded! Balance should not go negative.")
+		}
+		if !errors.As(err, &balanceErr) {
+			t.Fatalf("Expected InsufficientBalanceError, got: %v", err)
+		}
+		if balanceErr.Available != 10 || balanceErr.Requested != 20 {
+			t.Errorf("Unexpected error details: %+v", balanceErr)
+		}
+
+		// Failed transfer must not create the receiver
+		if _, err := store.Balance(context.Background(), "0xrich"); !errors.Is(err, ErrWalletNotFound) {
+			t.Errorf("Expected receiver not to be created, got: %v", err)
+		}
+	})
+}
+
+// 3. The "Threads Scenario" Test: Mixed operations (+1, -4, -7) on small balance.
+// Goal: Verify specific race condition outcome logic required by the task.
+// We run this scenario multiple times to increase the chance of catching a race condition.
+func TestConcurrent_MixedThreadsScenario(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		subject := "0xsubject"
+		external := "0xexternal"
+
+		iterations := 100
+
+		for i := 0; i < iterations; i++ {
+			resetWallet(t, store, subject, 10)   // Has 10
+			resetWallet(t, store, external, 100) // Has 100 (to send funds)
+
+			var wg sync.WaitGroup
+			wg.Add(3)
+
+			// Op 1: +1 (Receive)
+			go func() {
+				defer wg.Done()
+				_, err := store.Transfer(context.Background(), external, subject, 1)
+				if err != nil {
+					t.Errorf("Unexpected error in +1 operation: %v", err)
+				}
+			}()
+
+			// Op 2: -4 (Send)
+			go func() {
+				defer wg.Done()
+				_, _ = store.Transfer(context.Background(), subject, external, 4)
+			}()
+
+			// Op 3: -7 (Send)
+			go func() {
+				defer wg.Done()
+				_, _ = store.Transfer(context.Background(), subject, external, 7)
+			}()
+
+			wg.Wait()
+
+			// Valid outcomes: 0 (all succeed in order), 7 (-7 failed), 4 (-4 failed)
+			switch finalBalance := mustBalance(t, store, subject); finalBalance {
+			case 0, 7, 4:
+				// everything fine! :) (but we're not gonna write that out for 100 times)
+			default:
+				t.Errorf(" - Mixed Threads Scenario Failed. Invalid balance: %d", finalBalance)
+			}
+		}
+	})
+}
+
+// 4. Security Test: Negative Amount
+// Goal: Ensure users cannot steal money by sending negative amounts.
+func TestSecurity_NegativeAmount(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		hacker := "0xhacker"
+		resetWallet(t, store, hacker, 100)
+
+		// Hacker tries to send -50 to increase their own balance or steal from receiver
+		_, err := store.Transfer(context.Background(), hacker, "0xvictim", -50)
+
+		if !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Security Breach: expected ErrInvalidAmount, got: %v", err)
+		}
+		if balance := mustBalance(t, store, hacker); balance != 100 {
+			t.Errorf("Balance changed after rejected transfer: %d", balance)
+		}
+	})
+}
+
+// 5. Edge Case: Non-Existent Sender
+// Goal: Verify graceful error handling when wallet is missing.
+func TestLogic_NonExistentSender(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		// 0xghost does not exist
+		_, err := store.Transfer(context.Background(), "0xghost", "0xreal", 10)
+
+		if !errors.Is(err, ErrWalletNotFound) {
+			t.Errorf("Fail: Expected ErrWalletNotFound, got: %v", err)
+		}
+	})
+}
+
+// 6. Edge Case: Self-Transfer
+// Goal: Verify that sending money to yourself doesn't result in deadlock or lost funds.
+func TestLogic_SelfTransfer(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		me := "0xnarcissist" // Wallet who loves only himself ;)
+		startBalance := int64(100)
+		resetWallet(t, store, me, startBalance)
+
+		// Transfer 50. The outcome should remain 100
+		if _, err := store.Transfer(context.Background(), me, me, 50); err != nil {
+			t.Errorf(" - Self-transfer failed with error: %v", err)
+		}
+
+		if finalBalance := mustBalance(t, store, me); finalBalance != startBalance {
+			t.Errorf(" - Failed: Expected %d, got %d. Check your UPDATE logic.", startBalance, finalBalance)
+		}
+	})
+}
+
+// 7. Wallet creation
+// Goal: Verify that an address can only be registered once.
+func TestLogic_CreateWallet(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+
+		if err := store.CreateWallet(ctx, "0xnew", 42); err != nil {
+			t.Fatalf("Failed to create wallet: %v", err)
+		}
+		if balance := mustBalance(t, store, "0xnew"); balance != 42 {
+			t.Errorf("Expected 42, got %d", balance)
+		}
+		if err := store.CreateWallet(ctx, "0xnew", 1); !errors.Is(err, ErrWalletExists) {
+			t.Errorf("Expected ErrWalletExists, got: %v", err)
+		}
+	})
+}
+
+// 8. Error codes
+// Goal: Every domain error maps to a stable code, wrapped or not.
+func TestErrors_Code(t *testing.T) {
+	cases := []struct {
+		err  error
+		code string
+	}{
+		{&InvalidAmountError{Amount: -1}, CodeInvalidAmount},
+		{&InvalidAddressError{Address: ""}, CodeInvalidAddress},
+		{&WalletNotFoundError{Address: "0xa"}, CodeWalletNotFound},
+		{&InsufficientBalanceError{Address: "0xa", Available: 1, Requested: 2}, CodeInsufficientBalance},
+		{&WalletExistsError{Address: "0xa"}, CodeWalletExists},
+		{&InvalidNonceError{Address: "0xa", Expected: 2, Got: 1}, CodeInvalidNonce},
+		{ErrWalletNotFound, CodeWalletNotFound},
+	}
+	for _, c := range cases {
+		code, _, ok := Code(fmt.Errorf("transfer failed: %w", c.err))
+		if !ok || code != c.code {
+			t.Errorf("Expected %s for %v, got %q (ok=%v)", c.code, c.err, code, ok)
+		}
+	}
+
+	if _, _, ok := Code(errors.New("connection refused")); ok {
+		t.Errorf("Expected unknown errors to have no code")
+	}
+}
+
+// 9. Batch reads
+// Goal: Balances reads many wallets at once and leaves out the ones that don't exist.
+func TestBalances(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		reader, ok := store.(BalanceReader)
+		if !ok {
+			t.Skipf("%T has no batch reads", store)
+		}
+		resetWallet(t, store, "0xbatch1", 10)
+		resetWallet(t, store, "0xbatch2", 20)
+
+		balances, err := reader.Balances(context.Background(), []string{"0xbatch1", "0xbatch2", "0xbatchghost"})
+		if err != nil {
+			t.Fatalf("Balances failed: %v", err)
+		}
+		if len(balances) != 2 || balances["0xbatch1"] != 10 || balances["0xbatch2"] != 20 {
+			t.Errorf("Expected 0xbatch1: 10 and 0xbatch2: 20, got %v", balances)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package ledger
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore keeps wallets in a map guarded by a mutex.
+// It is meant for unit tests and the database-less demo mode; nothing survives a restart.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	balances map[string]int64
+}
+
+// NewMemoryStore returns an empty in-memory Store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{balances: map[string]int64{}}
+}
+
+// Transfer follows the same rules as PostgresStore.Transfer.
+// A single lock covers both wallets, so there is no lock ordering to worry about.
+func (s *MemoryStore) Transfer(ctx context.Context, fromAddress, toAddress string, amount int64) (int64, error) {
+	if err := validateTransfer(fromAddress, toAddress, amount); err != nil {
+		return 0, err
+	}
+
+	// Self-transfer doesn't change anything, the wallet only has to exist
+	if fromAddress == toAddress {
+		return s.Balance(ctx, fromAddress)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.balances[fromAddress]
+	if !ok {
+		return 0, &WalletNotFoundError{Address: fromAddress}
+	}
+	if current < amount {
+		return 0, &InsufficientBalanceError{Address: fromAddress, Available: current, Requested: amount}
+	}
+
+	s.balances[fromAddress] = current - amount
+	s.balances[toAddress] += amount
+
+	return current - amount, nil
+}
+
+// Balance returns the balance of an existing wallet.
+func (s *MemoryStore) Balance(ctx context.Context, address string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	balance, ok := s.balances[address]
+	if !ok {
+		return 0, &WalletNotFoundError{Address: address}
+	}
+	return balance, nil
+}
+
+// Balances implements BalanceReader.
+func (s *MemoryStore) Balances(ctx context.Context, addresses []string) (map[string]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	balances := make(map[string]int64, len(addresses))
+	for _, address := range addresses {
+		if balance, ok := s.balances[address]; ok {
+			balances[address] = balance
+		}
+	}
+	return balances, nil
+}
+
+// CreateWallet adds a new wallet with the given balance.
+func (s *MemoryStore) CreateWallet(ctx context.Context, address string, balance int64) error {
+	if address == "" {
+		return &InvalidAddressError{Address: address}
+	}
+	if balance < 0 {
+		return &InvalidAmountError{Amount: balance}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.balances[address]; ok {
+		return &WalletExistsError{Address: address}
+	}
+	s.balances[address] = balance
+	return nil
+}
@@ -0,0 +1,33 @@
+package ledger
+
+import (
+	"context"
+	"time"
+)
+
+// Observer receives measurements from PostgresStore, e.g. to export them as metrics,
+// so the store itself doesn't depend on any metrics library.
+// Methods are called on the transfer path: they must be cheap and safe for concurrent use.
+type Observer interface {
+	// LockWait reports how long a transfer waited to lock its wallets (or slots)
+	LockWait(d time.Duration)
+	// TxRetried reports a transaction aborted by PostgreSQL and run again, by SQLSTATE (40001, 40P01),
+	// or RetryExhausted when a request ran out of attempts
+	TxRetried(reason string)
+}
+
+// observeLockWait reports the time since start to s.Observer, if there is one.
+func (s *PostgresStore) observeLockWait(start time.Time) {
+	if s.Observer != nil {
+		s.Observer.LockWait(time.Since(start))
+	}
+}
+
+// retry runs fn with s.Retry, reporting the retries to s.Observer, if there is one.
+func (s *PostgresStore) retry(ctx context.Context, fn func() error) error {
+	var onRetry func(string)
+	if s.Observer != nil {
+		onRetry = s.Observer.TxRetried
+	}
+	return s.Retry.do(ctx, onRetry, fn)
+}
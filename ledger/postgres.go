@@ -0,0 +1,316 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Transaction modes of PostgresStore
+const (
+	// ModeLocking runs transfers in READ COMMITTED and locks both wallets with SELECT ... FOR UPDATE
+	// in a fixed (alphabetical) order, so deadlocks cannot happen.
+	ModeLocking = "locking"
+	// ModeSerializable runs transfers in SERIALIZABLE transactions without explicit locks.
+	// PostgreSQL aborts conflicting transactions (SQLSTATE 40001), which are then retried.
+	ModeSerializable = "serializable"
+	// ModeSingleStatement runs the whole transfer as one SQL statement (one round-trip),
+	// locking wallets in the same order as ModeLocking. See singleStatementTransferSQL.
+	ModeSingleStatement = "single"
+)
+
+// PostgresStore keeps wallets in the PostgreSQL "wallets" table.
+type PostgresStore struct {
+	DB *sql.DB
+
+	// Mode is ModeLocking (default), ModeSerializable or ModeSingleStatement
+	Mode string
+	// Retry controls how transactions aborted by serialization failures or deadlocks are retried
+	Retry RetryPolicy
+	// HotWallets maps addresses of heavily used wallets to the number of balance slots
+	// they are split into. See ApplySharding.
+	HotWallets map[string]int
+	// Observer is notified about lock waits, optional
+	Observer Observer
+}
+
+// NewPostgresStore returns a Store backed by db, using ModeLocking and the default retry policy.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{
+		DB:    db,
+		Mode:  ModeLocking,
+		Retry: DefaultRetryPolicy,
+	}
+}
+
+// Transfer does not contain API logic.
+// Api logic connected to Transfer operation can be found in graph/schema.resolvers.go file
+//
+// A successful transfer is recorded in the audit log in its own transaction, a failed one afterwards.
+func (s *PostgresStore) Transfer(ctx context.Context, fromAddress, toAddress string, amount int64) (int64, error) {
+	balance, err := s.transfer(ctx, fromAddress, toAddress, amount)
+	if err != nil {
+		s.auditFailure(ctx, OpTransfer, transferArguments(fromAddress, toAddress, amount), err)
+	}
+	return balance, err
+}
+
+func (s *PostgresStore) transfer(ctx context.Context, fromAddress, toAddress string, amount int64) (int64, error) {
+	if err := validateTransfer(fromAddress, toAddress, amount); err != nil {
+		return 0, err
+	}
+
+	// Handle Self-Transfer immediately
+	if fromAddress == toAddress {
+		// If sending to self, balance doesn't change, but we must ensure wallet exists.
+		return s.Balance(ctx, fromAddress)
+	}
+
+	if s.Mode == ModeSingleStatement && !s.isHot(fromAddress) && !s.isHot(toAddress) {
+		return s.transferSingleStatement(ctx, fromAddress, toAddress, amount)
+	}
+
+	var newBalance int64
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		newBalance, err = s.transferInTx(ctx, tx, fromAddress, toAddress, amount)
+		if err != nil {
+			return err
+		}
+		return RecordAudit(ctx, tx, OpTransfer, transferArguments(fromAddress, toAddress, amount),
+			map[string]any{"balance": newBalance}, nil)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return newBalance, nil
+}
+
+// inTx runs fn in a transaction using the isolation level of s.Mode and commits it.
+// Transactions aborted by a serialization failure or a deadlock are retried according to s.Retry.
+func (s *PostgresStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	opts := &sql.TxOptions{Isolation: sql.LevelReadCommitted}
+	if s.Mode == ModeSerializable {
+		opts.Isolation = sql.LevelSerializable
+	}
+
+	return s.retry(ctx, func() error {
+		tx, err := s.DB.BeginTx(ctx, opts)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+
+		// Defer function to handle rollback in case of panic or error
+		defer tx.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		// If there were no errors (detected by defender before) commit changes
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("transaction commit failed: %w", err)
+		}
+		return nil
+	})
+}
+
+// transferInTx runs a transfer inside an open transaction and records its ledger entry.
+func (s *PostgresStore) transferInTx(ctx context.Context, tx *sql.Tx, fromAddress, toAddress string, amount int64) (int64, error) {
+	newBalance, err := s.moveInTx(ctx, tx, fromAddress, toAddress, amount)
+	if err != nil {
+		return 0, err
+	}
+	return newBalance, recordTransferEntry(ctx, tx, fromAddress, toAddress, amount, "")
+}
+
+// moveInTx moves the funds of a transfer inside an open transaction, without recording it.
+// Sharded (hot) wallets have their own path, whatever the mode.
+func (s *PostgresStore) moveInTx(ctx context.Context, tx *sql.Tx, fromAddress, toAddress string, amount int64) (int64, error) {
+	if s.isHot(fromAddress) || s.isHot(toAddress) {
+		return s.transferTxSharded(ctx, tx, fromAddress, toAddress, amount)
+	}
+	return s.transferTx(ctx, tx, fromAddress, toAddress, amount)
+}
+
+// prepareTransferTx checks that the sender exists and makes sure the receiver does,
+// so the rows can be locked afterwards.
+func prepareTransferTx(ctx context.Context, tx *sql.Tx, fromAddress, toAddress string) error {
+	// Before creating new receiver check (without blocking) whether sender even exists
+	var exists bool
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM wallets WHERE address = $1)", fromAddress).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check sender existence: %w", err)
+	}
+	if !exists {
+		return &WalletNotFoundError{Address: fromAddress}
+	}
+
+	// Ensure Receiver Exists
+	// If he does not: create him
+	_, err = tx.ExecContext(ctx, `
+       INSERT INTO wallets (address, balance) VALUES ($1, 0)
+       ON CONFLICT (address) DO NOTHING
+    `, toAddress)
+	if err != nil {
+		return fmt.Errorf("failed to initialize receiver wallet: %w", err)
+	}
+	return nil
+}
+
+// transferTx moves funds inside an open transaction and returns the sender's new balance.
+// In ModeLocking both wallets are locked in alphabetical order before the balance is read.
+func (s *PostgresStore) transferTx(ctx context.Context, tx *sql.Tx, fromAddress, toAddress string, amount int64) (int64, error) {
+	if err := prepareTransferTx(ctx, tx, fromAddress, toAddress); err != nil {
+		return 0, err
+	}
+
+	var err error
+
+	// -- Prevention of deadlocks: --
+	// Serializable transactions don't lock, conflicts are detected by PostgreSQL and retried instead
+	if s.Mode != ModeSerializable {
+		//Sort addresses: always put them in alphabetical order
+		firstLock := fromAddress
+		secondLock := toAddress
+		if fromAddress > toAddress {
+			firstLock = toAddress
+			secondLock = fromAddress
+		}
+
+		lockStart := time.Now()
+
+		// Block first address
+		// Ignore "haven't found" error-receiver may have been not created yet
+		_, err = tx.ExecContext(ctx, "SELECT 1 FROM wallets WHERE address = $1 FOR UPDATE", firstLock)
+		if err != nil {
+			return 0, fmt.Errorf("failed to lock first wallet: %w", err)
+		}
+
+		// Block second address
+		_, err = tx.ExecContext(ctx, "SELECT 1 FROM wallets WHERE address = $1 FOR UPDATE", secondLock)
+		if err != nil {
+			return 0, fmt.Errorf("failed to lock second wallet: %w", err)
+		}
+		s.observeLockWait(lockStart)
+	}
+
+	// Downland sender's balance
+	var currentBalance int64
+	// If row doesn't exist Scan will return sql.ErrNoRows
+	err = tx.QueryRowContext(ctx, "SELECT balance FROM wallets WHERE address = $1", fromAddress).Scan(&currentBalance)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get sender balance: %w", err)
+	}
+
+	// -- Deadlocks prevented --
+
+	// Check if balance is sufficient
+	if currentBalance < amount {
+		return 0, &InsufficientBalanceError{Address: fromAddress, Available: currentBalance, Requested: amount}
+	}
+
+	// Subtract means from sender
+	_, err = tx.ExecContext(ctx, "UPDATE wallets SET balance = balance - $1 WHERE address = $2", amount, fromAddress)
+	if err != nil {
+		return 0, fmt.Errorf("failed to deduct funds: %w", err)
+	}
+
+	// Add means to reciver
+	// UPSERT: if wallet exists update, else: make a new one
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO wallets (address, balance) VALUES ($1, $2)
+		ON CONFLICT (address) DO UPDATE SET balance = wallets.balance + $2
+	`, toAddress, amount)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add funds to receiver: %w", err)
+	}
+
+	// Return new balance, the caller commits
+	return currentBalance - amount, nil
+}
+
+// walletBalanceSQL reads the balance of a wallet including its slots, if it is sharded.
+// Being a single statement, it sees one consistent snapshot of all slots.
+const walletBalanceSQL = `
+	SELECT w.balance + COALESCE((SELECT SUM(s.balance) FROM wallet_shards s WHERE s.address = w.address), 0)::bigint
+	FROM wallets w
+	WHERE w.address = $1
+`
+
+// Balance is a helper function for read-only operations.
+// It checks if the wallet exists and returns its balance.
+func (s *PostgresStore) Balance(ctx context.Context, address string) (int64, error) {
+	var balance int64
+	err := s.DB.QueryRowContext(ctx, walletBalanceSQL, address).Scan(&balance)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, &WalletNotFoundError{Address: address}
+		}
+		return 0, fmt.Errorf("failed to fetch balance: %w", err)
+	}
+	return balance, nil
+}
+
+// walletBalancesSQL is walletBalanceSQL for many wallets, in one snapshot as well.
+const walletBalancesSQL = `
+	SELECT w.address, w.balance + COALESCE((SELECT SUM(s.balance) FROM wallet_shards s WHERE s.address = w.address), 0)::bigint
+	FROM wallets w
+	WHERE w.address = ANY($1)
+`
+
+// Balances implements BalanceReader with a single query.
+func (s *PostgresStore) Balances(ctx context.Context, addresses []string) (map[string]int64, error) {
+	rows, err := s.DB.QueryContext(ctx, walletBalancesSQL, pq.Array(addresses))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch balances: %w", err)
+	}
+	defer rows.Close()
+
+	balances := make(map[string]int64, len(addresses))
+	for rows.Next() {
+		var address string
+		var balance int64
+		if err := rows.Scan(&address, &balance); err != nil {
+			return nil, fmt.Errorf("failed to fetch balances: %w", err)
+		}
+		balances[address] = balance
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch balances: %w", err)
+	}
+	return balances, nil
+}
+
+// CreateWallet inserts a new wallet with the given balance, minted, and records it in the audit log.
+func (s *PostgresStore) CreateWallet(ctx context.Context, address string, balance int64) error {
+	arguments := map[string]any{"address": address, "balance": balance}
+	err := s.createWallet(ctx, address, balance, arguments)
+	if err != nil {
+		s.auditFailure(ctx, OpCreateWallet, arguments, err)
+	}
+	return err
+}
+
+func (s *PostgresStore) createWallet(ctx context.Context, address string, balance int64, arguments map[string]any) error {
+	if address == "" {
+		return &InvalidAddressError{Address: address}
+	}
+	if balance < 0 {
+		return &InvalidAmountError{Amount: balance}
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		created, err := MintWallet(ctx, tx, address, balance)
+		if err != nil {
+			return err
+		}
+		if !created {
+			return &WalletExistsError{Address: address}
+		}
+		return RecordAudit(ctx, tx, OpCreateWallet, arguments, nil, nil)
+	})
+}
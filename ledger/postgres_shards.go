@@ -0,0 +1,299 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// Hot wallet sharding
+//
+// Every transfer locks the sender's row, so a wallet that pays out to everyone (like the genesis wallet)
+// serializes all transfers on a single lock. A hot wallet instead keeps its balance in N rows of
+// wallet_shards ("slots") and its wallets.balance stays 0:
+//
+//   - a debit locks one random slot that has enough funds (SKIP LOCKED, so concurrent debits pick different slots);
+//     if there is none, it locks all slots, takes the amount and spreads the rest evenly again (rebalancing);
+//   - a credit adds to one random unlocked slot;
+//   - the reported balance is wallets.balance plus the sum of the slots, read in one statement, so it stays exact.
+//
+// Locks are still taken in alphabetical order of addresses; a hot wallet locks its slots in place of its row.
+
+// isHot reports whether address is configured as a sharded wallet.
+func (s *PostgresStore) isHot(address string) bool {
+	return s.HotWallets[address] > 0
+}
+
+// ApplySharding brings wallet_shards in line with s.HotWallets: configured wallets are split into slots,
+// wallets that are no longer configured get their slots merged back into wallets.balance.
+// Call it once at startup, before serving transfers.
+func (s *PostgresStore) ApplySharding(ctx context.Context) error {
+	rows, err := s.DB.QueryContext(ctx, "SELECT DISTINCT address FROM wallet_shards")
+	if err != nil {
+		return fmt.Errorf("failed to list sharded wallets: %w", err)
+	}
+	var sharded []string
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to list sharded wallets: %w", err)
+		}
+		sharded = append(sharded, address)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list sharded wallets: %w", err)
+	}
+
+	for _, address := range sharded {
+		if !s.isHot(address) {
+			if err := s.reshard(ctx, address, 0); err != nil {
+				return err
+			}
+		}
+	}
+	for address, slots := range s.HotWallets {
+		err := s.reshard(ctx, address, slots)
+		if errors.Is(err, ErrWalletNotFound) {
+			slog.WarnContext(ctx, "ledger: hot wallet does not exist yet, not sharded", "address", address)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reshard moves the whole balance of a wallet into slots equal parts (slots == 0: back into wallets.balance).
+// It does nothing if the wallet already has exactly that many slots.
+func (s *PostgresStore) reshard(ctx context.Context, address string, slots int) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		var walletBalance int64
+		err := tx.QueryRowContext(ctx, "SELECT balance FROM wallets WHERE address = $1 FOR UPDATE", address).Scan(&walletBalance)
+		if err == sql.ErrNoRows {
+			return &WalletNotFoundError{Address: address}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock wallet %s: %w", address, err)
+		}
+
+		current, shardTotal, err := lockAllSlots(ctx, tx, address)
+		if err != nil {
+			return err
+		}
+		if current == slots && (slots == 0 || walletBalance == 0) {
+			return nil
+		}
+
+		total := walletBalance + shardTotal
+		if _, err := tx.ExecContext(ctx, "DELETE FROM wallet_shards WHERE address = $1", address); err != nil {
+			return fmt.Errorf("failed to remove slots of %s: %w", address, err)
+		}
+
+		if slots == 0 {
+			_, err = tx.ExecContext(ctx, "UPDATE wallets SET balance = $1 WHERE address = $2", total, address)
+		} else {
+			_, err = tx.ExecContext(ctx, "UPDATE wallets SET balance = 0 WHERE address = $1", address)
+			if err == nil {
+				_, err = tx.ExecContext(ctx, `
+					INSERT INTO wallet_shards (address, slot, balance)
+					SELECT $1, g, $2::bigint / $3::int + CASE WHEN g < $2::bigint % $3::int THEN 1 ELSE 0 END
+					FROM generate_series(0, $3::int - 1) AS g
+				`, address, total, slots)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to reshard %s: %w", address, err)
+		}
+
+		slog.InfoContext(ctx, "ledger: wallet resharded", "address", address, "slots", slots, "balance", total)
+		return RecordAudit(ctx, tx, OpReshard, map[string]any{"address": address, "slots": slots},
+			map[string]any{"balance": total}, nil)
+	})
+}
+
+// transferTxSharded is transferTx for transfers where at least one side is a hot wallet.
+func (s *PostgresStore) transferTxSharded(ctx context.Context, tx *sql.Tx, fromAddress, toAddress string, amount int64) (int64, error) {
+	if err := prepareTransferTx(ctx, tx, fromAddress, toAddress); err != nil {
+		return 0, err
+	}
+
+	// -- Prevention of deadlocks: --
+	// Same alphabetical order as transferTx. Hot wallets are debited/credited right when their slot is locked,
+	// plain wallets are only locked here and updated below.
+	firstLock, secondLock := fromAddress, toAddress
+	if fromAddress > toAddress {
+		firstLock, secondLock = toAddress, fromAddress
+	}
+	// Hot wallet debits and credits are part of the lock wait, they happen while taking the slot lock
+	lockStart := time.Now()
+	for _, address := range []string{firstLock, secondLock} {
+		var err error
+		switch {
+		case address == fromAddress && s.isHot(address):
+			err = debitSlot(ctx, tx, address, amount)
+		case address == toAddress && s.isHot(address):
+			err = creditSlot(ctx, tx, address, amount, s.HotWallets[address])
+		default:
+			_, err = tx.ExecContext(ctx, "SELECT 1 FROM wallets WHERE address = $1 FOR UPDATE", address)
+			if err != nil {
+				err = fmt.Errorf("failed to lock wallet: %w", err)
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	s.observeLockWait(lockStart)
+
+	if !s.isHot(fromAddress) {
+		if err := debitWallet(ctx, tx, fromAddress, amount); err != nil {
+			return 0, err
+		}
+	}
+
+	if !s.isHot(toAddress) {
+		if _, err := tx.ExecContext(ctx, "UPDATE wallets SET balance = balance + $1 WHERE address = $2", amount, toAddress); err != nil {
+			return 0, fmt.Errorf("failed to add funds to receiver: %w", err)
+		}
+	}
+
+	// Return new balance (sum of slots for a hot sender)
+	var newBalance int64
+	if err := tx.QueryRowContext(ctx, walletBalanceSQL, fromAddress).Scan(&newBalance); err != nil {
+		return 0, fmt.Errorf("failed to get sender balance: %w", err)
+	}
+	return newBalance, nil
+}
+
+// debitSlot takes amount from one slot of a hot wallet.
+// It prefers a random unlocked slot with enough funds and falls back to rebalancing all slots.
+func debitSlot(ctx context.Context, tx *sql.Tx, address string, amount int64) error {
+	var slot int
+	err := tx.QueryRowContext(ctx, `
+		SELECT slot FROM wallet_shards
+		WHERE address = $1 AND balance >= $2
+		ORDER BY random()
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	`, address, amount).Scan(&slot)
+	if err == sql.ErrNoRows {
+		return debitRebalancing(ctx, tx, address, amount)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock slot of %s: %w", address, err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE wallet_shards SET balance = balance - $1 WHERE address = $2 AND slot = $3", amount, address, slot)
+	if err != nil {
+		return fmt.Errorf("failed to deduct funds: %w", err)
+	}
+	return nil
+}
+
+// debitRebalancing locks all slots of a hot wallet, takes amount and spreads the rest evenly,
+// so that following debits find funded slots again.
+func debitRebalancing(ctx context.Context, tx *sql.Tx, address string, amount int64) error {
+	slots, total, err := lockAllSlots(ctx, tx, address)
+	if err != nil {
+		return err
+	}
+	if slots == 0 {
+		// Not sharded yet (ApplySharding wasn't run): debit the wallet row instead
+		return debitWallet(ctx, tx, address, amount)
+	}
+	if total < amount {
+		return &InsufficientBalanceError{Address: address, Available: total, Requested: amount}
+	}
+
+	// Slots are numbered 0..slots-1, the remainder goes to the first ones
+	_, err = tx.ExecContext(ctx, `
+		UPDATE wallet_shards
+		SET balance = $2::bigint / $3::int + CASE WHEN slot < $2::bigint % $3::int THEN 1 ELSE 0 END
+		WHERE address = $1
+	`, address, total-amount, slots)
+	if err != nil {
+		return fmt.Errorf("failed to rebalance slots of %s: %w", address, err)
+	}
+	return nil
+}
+
+// creditSlot adds amount to one random slot of a hot wallet, preferring slots nobody holds right now.
+func creditSlot(ctx context.Context, tx *sql.Tx, address string, amount int64, slots int) error {
+	var slot int
+	err := tx.QueryRowContext(ctx, `
+		SELECT slot FROM wallet_shards
+		WHERE address = $1
+		ORDER BY random()
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	`, address).Scan(&slot)
+	if err == sql.ErrNoRows {
+		// All slots are busy: wait for a random one
+		slot = rand.IntN(slots)
+		err = tx.QueryRowContext(ctx, "SELECT slot FROM wallet_shards WHERE address = $1 AND slot = $2 FOR UPDATE", address, slot).Scan(&slot)
+	}
+	if err == sql.ErrNoRows {
+		// Not sharded yet (ApplySharding wasn't run): credit the wallet row instead
+		_, err = tx.ExecContext(ctx, "UPDATE wallets SET balance = balance + $1 WHERE address = $2", amount, address)
+		if err != nil {
+			return fmt.Errorf("failed to add funds to receiver: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock slot of %s: %w", address, err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE wallet_shards SET balance = balance + $1 WHERE address = $2 AND slot = $3", amount, address, slot)
+	if err != nil {
+		return fmt.Errorf("failed to add funds to receiver: %w", err)
+	}
+	return nil
+}
+
+// debitWallet takes amount from the row of a plain (not sharded) wallet.
+func debitWallet(ctx context.Context, tx *sql.Tx, address string, amount int64) error {
+	var currentBalance int64
+	err := tx.QueryRowContext(ctx, "SELECT balance FROM wallets WHERE address = $1 FOR UPDATE", address).Scan(&currentBalance)
+	if err != nil {
+		return fmt.Errorf("failed to get sender balance: %w", err)
+	}
+	if currentBalance < amount {
+		return &InsufficientBalanceError{Address: address, Available: currentBalance, Requested: amount}
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE wallets SET balance = balance - $1 WHERE address = $2", amount, address); err != nil {
+		return fmt.Errorf("failed to deduct funds: %w", err)
+	}
+	return nil
+}
+
+// lockAllSlots locks the slots of a wallet in slot order and returns their count and total balance.
+func lockAllSlots(ctx context.Context, tx *sql.Tx, address string) (int, int64, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT balance FROM wallet_shards WHERE address = $1 ORDER BY slot FOR UPDATE", address)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to lock slots of %s: %w", address, err)
+	}
+	defer rows.Close()
+
+	var count int
+	var total int64
+	for rows.Next() {
+		var balance int64
+		if err := rows.Scan(&balance); err != nil {
+			return 0, 0, fmt.Errorf("failed to lock slots of %s: %w", address, err)
+		}
+		count++
+		total += balance
+	}
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("failed to lock slots of %s: %w", address, err)
+	}
+	return count, total, nil
+}
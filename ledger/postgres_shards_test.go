@@ -0,0 +1,129 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+const hotAddress = "0xhot"
+
+// newShardedStore returns a store where hotAddress holds balance split into slots
+func newShardedStore(t *testing.T, balance int64, slots int) *PostgresStore {
+	store := NewPostgresStore(getDB(t))
+	store.HotWallets = map[string]int{hotAddress: slots}
+
+	if err := store.CreateWallet(context.Background(), hotAddress, balance); err != nil {
+		t.Fatalf("Failed to create hot wallet: %v", err)
+	}
+	if err := store.ApplySharding(context.Background()); err != nil {
+		t.Fatalf("Failed to apply sharding: %v", err)
+	}
+	return store
+}
+
+// slotCount returns the number of slots of a wallet and their total
+func slotCount(t *testing.T, store *PostgresStore, address string) (int, int64) {
+	var count int
+	var total int64
+	err := store.DB.QueryRow("SELECT COUNT(*), COALESCE(SUM(balance), 0) FROM wallet_shards WHERE address = $1", address).Scan(&count, &total)
+	if err != nil {
+		t.Fatalf("Failed to read slots: %v", err)
+	}
+	return count, total
+}
+
+// 1. Sharding keeps the balance
+// Goal: Splitting into slots and merging back never changes the reported balance.
+func TestShards_ApplyAndMergeBack(t *testing.T) {
+	store := newShardedStore(t, 1003, 4)
+
+	if count, total := slotCount(t, store, hotAddress); count != 4 || total != 1003 {
+		t.Fatalf("Expected 4 slots holding 1003, got %d slots holding %d", count, total)
+	}
+	if balance := mustBalance(t, store, hotAddress); balance != 1003 {
+		t.Errorf("Expected balance 1003, got %d", balance)
+	}
+
+	// Wallet no longer configured as hot: slots are merged back
+	store.HotWallets = nil
+	if err := store.ApplySharding(context.Background()); err != nil {
+		t.Fatalf("Failed to merge slots back: %v", err)
+	}
+	if count, _ := slotCount(t, store, hotAddress); count != 0 {
+		t.Errorf("Expected no slots after merge, got %d", count)
+	}
+	if balance := mustBalance(t, store, hotAddress); balance != 1003 {
+		t.Errorf("Expected balance 1003 after merge, got %d", balance)
+	}
+}
+
+// 2. The "Hammer" Test on a hot wallet
+// Goal: Concurrent payouts from a sharded wallet never lose or create tokens.
+func TestShards_ConcurrentPayouts(t *testing.T) {
+	store := newShardedStore(t, 1000, 8)
+
+	var wg sync.WaitGroup
+	receivers := []string{"0xr1", "0xr2", "0xr3", "0xr4", "0xr5"}
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := store.Transfer(context.Background(), hotAddress, receivers[i%len(receivers)], 5); err != nil {
+				t.Errorf("Unexpected error in hot wallet payout: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if balance := mustBalance(t, store, hotAddress); balance != 0 {
+		t.Errorf(" - Race Condition Detected! Expected 0, got %d", balance)
+	}
+	for _, r := range receivers {
+		if balance := mustBalance(t, store, r); balance != 200 {
+			t.Errorf("Expected %s to hold 200, got %d", r, balance)
+		}
+	}
+}
+
+// 3. Rebalancing
+// Goal: A transfer larger than any single slot still succeeds if the slots together can cover it.
+func TestShards_RebalanceWhenNoSlotIsBigEnough(t *testing.T) {
+	store := newShardedStore(t, 100, 4) // 25 per slot
+
+	newBalance, err := store.Transfer(context.Background(), hotAddress, "0xbig", 60)
+	if err != nil {
+		t.Fatalf("Expected rebalancing transfer to succeed, got: %v", err)
+	}
+	if newBalance != 40 {
+		t.Errorf("Expected new balance 40, got %d", newBalance)
+	}
+	if count, total := slotCount(t, store, hotAddress); count != 4 || total != 40 {
+		t.Errorf("Expected 4 slots holding 40, got %d slots holding %d", count, total)
+	}
+
+	// More than the whole wallet is still refused
+	_, err = store.Transfer(context.Background(), hotAddress, "0xbig", 41)
+	var balanceErr *InsufficientBalanceError
+	if !errors.As(err, &balanceErr) || balanceErr.Available != 40 {
+		t.Errorf("Expected InsufficientBalanceError with 40 available, got: %v", err)
+	}
+}
+
+// 4. Credits to a hot wallet
+// Goal: Funds sent to a hot wallet land in its slots and are counted in its balance.
+func TestShards_CreditHotWallet(t *testing.T) {
+	store := newShardedStore(t, 0, 4)
+	resetWallet(t, store, "0xpayer", 50)
+
+	if _, err := store.Transfer(context.Background(), "0xpayer", hotAddress, 50); err != nil {
+		t.Fatalf("Transfer to hot wallet failed: %v", err)
+	}
+	if balance := mustBalance(t, store, hotAddress); balance != 50 {
+		t.Errorf("Expected hot wallet balance 50, got %d", balance)
+	}
+	if _, total := slotCount(t, store, hotAddress); total != 50 {
+		t.Errorf("Expected slots to hold 50, got %d", total)
+	}
+}
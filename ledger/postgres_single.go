@@ -0,0 +1,83 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// singleStatementTransferSQL does the whole transfer in one statement, so it costs a single round-trip
+// (PostgreSQL wraps it in an implicit transaction, no BEGIN/COMMIT needed):
+//
+//   - locked: locks the existing wallets in alphabetical order (rows are locked after the sort),
+//     the same deadlock-free order as ModeLocking uses;
+//   - debit: subtracts the amount only if the locked sender has enough funds;
+//   - credit: adds the amount to the receiver, creating it if needed, only if debit happened;
+//   - entry: records the transfer in ledger_entries, only if debit happened;
+//   - audit: records the transfer in the audit log, only if debit happened (failures are recorded by Transfer).
+//
+// The final SELECT reports the sender's balance before the transfer (NULL if the sender doesn't exist)
+// and after it (NULL if the debit didn't happen), so Go code can return the same errors as ModeLocking.
+// A failed transfer changes nothing, in particular the receiver is not created.
+const singleStatementTransferSQL = `
+	WITH locked AS (
+		SELECT address, balance
+		FROM wallets
+		WHERE address IN ($1::varchar, $2::varchar)
+		ORDER BY address
+		FOR UPDATE
+	), debit AS (
+		UPDATE wallets
+		SET balance = wallets.balance - $3::bigint
+		FROM locked
+		WHERE wallets.address = $1::varchar
+		  AND locked.address = $1::varchar
+		  AND locked.balance >= $3::bigint
+		RETURNING wallets.balance
+	), credit AS (
+		INSERT INTO wallets (address, balance)
+		SELECT $2::varchar, $3::bigint FROM debit
+		ON CONFLICT (address) DO UPDATE SET balance = wallets.balance + EXCLUDED.balance
+	), entry AS (
+		INSERT INTO ledger_entries (kind, from_address, to_address, amount)
+		SELECT 'transfer', $1::varchar, $2::varchar, $3::bigint FROM debit
+	), audit AS (
+		INSERT INTO audit_events (principal, ip, user_agent, request_id, operation, arguments, outcome, result)
+		SELECT $4, $5, $6, $7, $8, $9::jsonb, 'success', jsonb_build_object('balance', debit.balance)
+		FROM debit
+	)
+	SELECT
+		(SELECT balance FROM locked WHERE address = $1::varchar),
+		(SELECT balance FROM debit)
+`
+
+// transferSingleStatement is Transfer in ModeSingleStatement.
+// Arguments are already validated and fromAddress != toAddress.
+func (s *PostgresStore) transferSingleStatement(ctx context.Context, fromAddress, toAddress string, amount int64) (int64, error) {
+	var before, after sql.NullInt64
+
+	actor := ActorFrom(ctx)
+	arguments, err := json.Marshal(transferArguments(fromAddress, toAddress, amount))
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode audit arguments: %w", err)
+	}
+
+	// A single statement can't deadlock with the fixed lock order, but retrying is cheap insurance
+	err = s.retry(ctx, func() error {
+		return s.DB.QueryRowContext(ctx, singleStatementTransferSQL, fromAddress, toAddress, amount,
+			actor.Principal, actor.IP, actor.UserAgent, actor.RequestID, OpTransfer, string(arguments),
+		).Scan(&before, &after)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute transfer: %w", err)
+	}
+
+	if !before.Valid {
+		return 0, &WalletNotFoundError{Address: fromAddress}
+	}
+	if !after.Valid {
+		return 0, &InsufficientBalanceError{Address: fromAddress, Available: before.Int64, Requested: amount}
+	}
+	return after.Int64, nil
+}
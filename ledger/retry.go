@@ -0,0 +1,95 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SQLSTATE codes of transactions aborted by PostgreSQL that are safe to run again
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+// RetryExhausted is the reason reported to Observer.TxRetried when a request ran out of retry budget
+const RetryExhausted = "exhausted"
+
+// RetryPolicy controls how transactions aborted by serialization failures (40001)
+// and deadlocks (40P01) are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int
+	// BaseDelay is the upper bound of the first backoff; it doubles with every attempt
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is used by NewPostgresStore.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   5 * time.Millisecond,
+	MaxDelay:    200 * time.Millisecond,
+}
+
+// Do calls fn until it succeeds, fails with an error that is not retryable, or the budget is used up.
+// Between attempts it sleeps a random ("full jitter") delay, so competing transactions spread out.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	return p.do(ctx, nil, fn)
+}
+
+// do is Do, reporting every retry and exhausted budget to onRetry when it is not nil.
+func (p RetryPolicy) do(ctx context.Context, onRetry func(reason string), fn func() error) error {
+	if onRetry == nil {
+		onRetry = func(string) {}
+	}
+	attempts := max(p.MaxAttempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		state, retryable := retryableState(err)
+		if !retryable {
+			return err
+		}
+		if attempt >= attempts {
+			onRetry(RetryExhausted)
+			return &TransactionConflictError{Attempts: attempt, Err: err}
+		}
+		onRetry(state)
+
+		select {
+		case <-time.After(p.backoff(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// backoff returns a random delay in [0, min(MaxDelay, BaseDelay * 2^(attempt-1))].
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	ceiling := p.BaseDelay << min(attempt-1, 20)
+	if p.MaxDelay > 0 && ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+	return rand.N(ceiling + 1)
+}
+
+// retryableState reports whether err was caused by a serialization failure or a deadlock.
+func retryableState(err error) (string, bool) {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return "", false
+	}
+	switch pqErr.Code {
+	case sqlStateSerializationFailure, sqlStateDeadlockDetected:
+		return string(pqErr.Code), true
+	}
+	return "", false
+}
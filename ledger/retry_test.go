@@ -0,0 +1,90 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// 1. Retry of serialization failures and deadlocks
+// Goal: Aborted transactions are run again until they succeed.
+func TestRetry_RetriesSerializationFailures(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Microsecond, MaxDelay: time.Millisecond}
+
+	calls := 0
+	err := policy.Do(context.Background(), func() error {
+		calls++
+		switch calls {
+		case 1:
+			return &pq.Error{Code: sqlStateSerializationFailure}
+		case 2:
+			return &pq.Error{Code: sqlStateDeadlockDetected}
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Expected success after retries, got: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls)
+	}
+}
+
+// 2. Retry budget
+// Goal: After MaxAttempts the caller gets TransactionConflictError, not an endless loop; every retry is reported.
+func TestRetry_BudgetExhausted(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Microsecond, MaxDelay: time.Millisecond}
+
+	calls := 0
+	var reasons []string
+	err := policy.do(context.Background(), func(reason string) { reasons = append(reasons, reason) }, func() error {
+		calls++
+		return &pq.Error{Code: sqlStateSerializationFailure}
+	})
+
+	var conflict *TransactionConflictError
+	if !errors.As(err, &conflict) || conflict.Attempts != 3 {
+		t.Fatalf("Expected TransactionConflictError after 3 attempts, got: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls)
+	}
+	if code, _, _ := Code(err); code != CodeTransactionConflict {
+		t.Errorf("Expected code %s, got %s", CodeTransactionConflict, code)
+	}
+	// Two retries, then the budget is exhausted
+	if want := []string{sqlStateSerializationFailure, sqlStateSerializationFailure, RetryExhausted}; !slices.Equal(reasons, want) {
+		t.Errorf("Expected retries reported as %v, got %v", want, reasons)
+	}
+}
+
+// 3. Domain errors are final
+// Goal: Business errors (e.g. insufficient balance) are never retried.
+func TestRetry_DoesNotRetryOtherErrors(t *testing.T) {
+	calls := 0
+	err := DefaultRetryPolicy.Do(context.Background(), func() error {
+		calls++
+		return &InsufficientBalanceError{Address: "0xa", Available: 1, Requested: 2}
+	})
+
+	if !errors.Is(err, ErrInsufficientBalance) || calls != 1 {
+		t.Errorf("Expected a single attempt returning ErrInsufficientBalance, got %d attempts: %v", calls, err)
+	}
+}
+
+// 4. Backoff
+// Goal: Delays stay within the cap, however many attempts were made.
+func TestRetry_BackoffIsCapped(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 100, BaseDelay: 5 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+
+	for attempt := 1; attempt < 100; attempt++ {
+		if d := policy.backoff(attempt); d < 0 || d > policy.MaxDelay {
+			t.Fatalf("Backoff for attempt %d out of range: %v", attempt, d)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// SignedTransfer is a transfer authorized by a signed transaction instead of the caller of the API.
+// Nonce orders the transactions of a sender: each one must carry the wallet's current nonce, so a
+// transaction can't be applied twice nor replayed once a later one went through.
+type SignedTransfer struct {
+	// Hash identifies the transaction, 0x followed by 64 hex digits
+	Hash   string
+	From   string
+	To     string
+	Amount int64
+	Nonce  uint64
+}
+
+// SignedTransferer applies signed transfers, see PostgresStore.TransferSigned.
+type SignedTransferer interface {
+	TransferSigned(ctx context.Context, t SignedTransfer) (int64, error)
+}
+
+// TransferSigned applies t with s, for wrappers of a Store that may not support signed transfers.
+func TransferSigned(ctx context.Context, s Store, t SignedTransfer) (int64, error) {
+	signer, ok := s.(SignedTransferer)
+	if !ok {
+		return 0, fmt.Errorf("signed transfers: %w", errors.ErrUnsupported)
+	}
+	return signer.TransferSigned(ctx, t)
+}
+
+// Nonce returns the nonce the next signed transfer of a wallet must carry, 0 for unknown wallets.
+func (s *PostgresStore) Nonce(ctx context.Context, address string) (uint64, error) {
+	var nonce uint64
+	err := s.DB.QueryRowContext(ctx, "SELECT nonce FROM wallets WHERE address = $1", address).Scan(&nonce)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read nonce: %w", err)
+	}
+	return nonce, nil
+}
+
+// TransferSigned applies a signed transfer and returns the sender's new balance. It follows the rules
+// of Transfer, the nonce must match and the sender's nonce is incremented in the same transaction.
+// A transaction already applied is not applied again: its entry is found by hash and the current
+// balance returned.
+func (s *PostgresStore) TransferSigned(ctx context.Context, t SignedTransfer) (int64, error) {
+	arguments := transferArguments(t.From, t.To, t.Amount)
+	arguments["tx_hash"] = t.Hash
+	arguments["nonce"] = t.Nonce
+
+	balance, err := s.transferSigned(ctx, t, arguments)
+	if err != nil {
+		s.auditFailure(ctx, OpTransfer, arguments, err)
+	}
+	return balance, err
+}
+
+func (s *PostgresStore) transferSigned(ctx context.Context, t SignedTransfer, arguments map[string]any) (int64, error) {
+	if err := validateTransfer(t.From, t.To, t.Amount); err != nil {
+		return 0, err
+	}
+	// A self-transfer changes nothing, so it would have no entry to find the transaction by
+	if t.From == t.To {
+		return 0, &InvalidAddressError{Address: t.To}
+	}
+	applied, err := s.SignedEntry(ctx, t.Hash)
+	if err != nil {
+		return 0, err
+	}
+	if applied != nil {
+		return s.Balance(ctx, t.From)
+	}
+
+	var newBalance int64
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		newBalance, err = s.moveInTx(ctx, tx, t.From, t.To, t.Amount)
+		if err != nil {
+			return err
+		}
+		// The sender's row is locked by now (except in serializable mode, where a concurrent
+		// transaction of the same sender makes one of them fail and retry)
+		res, err := tx.ExecContext(ctx, "UPDATE wallets SET nonce = nonce + 1 WHERE address = $1 AND nonce = $2", t.From, t.Nonce)
+		if err != nil {
+			return fmt.Errorf("failed to increment nonce: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to increment nonce: %w", err)
+		}
+		if n == 0 {
+			var current uint64
+			if err := tx.QueryRowContext(ctx, "SELECT nonce FROM wallets WHERE address = $1", t.From).Scan(&current); err != nil {
+				return fmt.Errorf("failed to read nonce: %w", err)
+			}
+			return &InvalidNonceError{Address: t.From, Expected: current, Got: t.Nonce}
+		}
+		if err := recordTransferEntry(ctx, tx, t.From, t.To, t.Amount, t.Hash); err != nil {
+			return err
+		}
+		return RecordAudit(ctx, tx, OpTransfer, arguments, map[string]any{"balance": newBalance}, nil)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return newBalance, nil
+}
+
+const entryColumns = "id, created_at, kind, COALESCE(from_address, ''), COALESCE(to_address, ''), amount"
+
+// SignedEntry returns the entry of the signed transfer with the given hash, nil if there is none.
+func (s *PostgresStore) SignedEntry(ctx context.Context, hash string) (*Entry, error) {
+	return s.entryWhere(ctx, "tx_hash = $1", hash)
+}
+
+// Entry returns the ledger entry with the given ID, nil if there is none.
+func (s *PostgresStore) Entry(ctx context.Context, id int64) (*Entry, error) {
+	return s.entryWhere(ctx, "id = $1", id)
+}
+
+func (s *PostgresStore) entryWhere(ctx context.Context, condition string, arg any) (*Entry, error) {
+	var e Entry
+	err := s.DB.QueryRowContext(ctx, "SELECT "+entryColumns+" FROM ledger_entries WHERE "+condition, arg).
+		Scan(&e.ID, &e.CreatedAt, &e.Kind, &e.From, &e.To, &e.Amount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ledger entry: %w", err)
+	}
+	return &e, nil
+}
+
+// LastEntryID returns the ID of the newest ledger entry, 0 when there is none.
+func (s *PostgresStore) LastEntryID(ctx context.Context) (int64, error) {
+	var id int64
+	if err := s.DB.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM ledger_entries").Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to read the last ledger entry: %w", err)
+	}
+	return id, nil
+}
+
+// TotalSupply returns the sum of all balances, slots of hot wallets included.
+func (s *PostgresStore) TotalSupply(ctx context.Context) (int64, error) {
+	var supply int64
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT (SELECT COALESCE(SUM(balance), 0) FROM wallets) + (SELECT COALESCE(SUM(balance), 0) FROM wallet_shards)
+	`).Scan(&supply)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read total supply: %w", err)
+	}
+	return supply, nil
+}
@@ -0,0 +1,56 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// 1. Signed transfers
+// Goal: A signed transfer needs the sender's current nonce, increments it, can be found by hash,
+// and is applied only once when sent again.
+func TestTransferSigned(t *testing.T) {
+	store := NewPostgresStore(getDB(t))
+	ctx := context.Background()
+	if err := store.CreateWallet(ctx, "0xsigner", 100); err != nil {
+		t.Fatalf("CreateWallet failed: %v", err)
+	}
+	hash := "0x" + "ab"
+	for len(hash) < 66 {
+		hash += "ab"
+	}
+	transfer := SignedTransfer{Hash: hash, From: "0xsigner", To: "0xreceiver", Amount: 40, Nonce: 0}
+
+	balance, err := store.TransferSigned(ctx, transfer)
+	if err != nil || balance != 60 {
+		t.Fatalf("Expected balance 60, got %d: %v", balance, err)
+	}
+	if nonce, err := store.Nonce(ctx, "0xsigner"); err != nil || nonce != 1 {
+		t.Errorf("Expected nonce 1, got %d: %v", nonce, err)
+	}
+	entry, err := store.SignedEntry(ctx, hash)
+	if err != nil || entry == nil || entry.From != "0xsigner" || entry.To != "0xreceiver" || entry.Amount != 40 {
+		t.Fatalf("Expected the entry of the transfer, got %+v: %v", entry, err)
+	}
+	if last, err := store.LastEntryID(ctx); err != nil || last != entry.ID {
+		t.Errorf("Expected last entry %d, got %d: %v", entry.ID, last, err)
+	}
+
+	// Sent again: nothing changes
+	if balance, err := store.TransferSigned(ctx, transfer); err != nil || balance != 60 {
+		t.Errorf("Expected the same transfer to be accepted once, got %d: %v", balance, err)
+	}
+
+	// Another transaction with the nonce already used
+	transfer.Hash = "0x" + hash[4:] + "cd"
+	var nonceErr *InvalidNonceError
+	if _, err := store.TransferSigned(ctx, transfer); !errors.As(err, &nonceErr) || nonceErr.Expected != 1 || nonceErr.Got != 0 {
+		t.Errorf("Expected InvalidNonceError (expected 1, got 0), got %v", err)
+	}
+	if balance, _ := store.Balance(ctx, "0xsigner"); balance != 60 {
+		t.Errorf("Expected a refused transfer to change nothing, balance is %d", balance)
+	}
+	if supply, err := store.TotalSupply(ctx); err != nil || supply != 100 {
+		t.Errorf("Expected total supply 100, got %d: %v", supply, err)
+	}
+}
@@ -0,0 +1,174 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Discrepancy is one violated ledger invariant.
+type Discrepancy struct {
+	Check   string `json:"check"`
+	Address string `json:"address,omitempty"`
+	Detail  string `json:"detail"`
+}
+
+// Names of the checks run by Verify
+const (
+	CheckTotalSupply       = "total_supply"
+	CheckMintedMinusBurned = "minted_minus_burned"
+	CheckNegativeBalance   = "negative_balance"
+	CheckShardedRowBalance = "sharded_row_balance"
+	CheckDerivedBalance    = "derived_balance"
+	CheckOrphanEntry       = "orphan_entry"
+)
+
+// Report is the machine-readable result of Verify.
+type Report struct {
+	CheckedAt time.Time `json:"checked_at"`
+	// Supply is the expected supply Verify was given, negative when unknown
+	Supply int64 `json:"supply"`
+	// Balances is the sum of all balances, slots of hot wallets included
+	Balances int64 `json:"balances"`
+	// Minted includes opening balances (see EntryOpening)
+	Minted        int64         `json:"minted"`
+	Burned        int64         `json:"burned"`
+	Wallets       int64         `json:"wallets"`
+	Entries       int64         `json:"entries"`
+	OK            bool          `json:"ok"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
+
+// Verify checks the ledger invariants on one consistent snapshot and reports every violation found:
+//   - the balances of all wallets (including slots of hot wallets) add up to supply; a negative supply skips this check;
+//   - they also add up to the tokens minted minus the tokens burned, according to the ledger entries;
+//   - no wallet or slot holds a negative balance;
+//   - a sharded wallet keeps its whole balance in slots, its wallets.balance is 0;
+//   - the balance of every wallet equals what its ledger entries add up to;
+//   - no ledger entry refers to a wallet that doesn't exist.
+func (s *PostgresStore) Verify(ctx context.Context, supply int64) (*Report, error) {
+	tx, err := s.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	report := &Report{Supply: supply, Discrepancies: []Discrepancy{}}
+	err = tx.QueryRowContext(ctx, `
+		SELECT now(),
+		       (SELECT COALESCE(SUM(balance), 0) FROM wallets) + (SELECT COALESCE(SUM(balance), 0) FROM wallet_shards),
+		       (SELECT COUNT(*) FROM wallets),
+		       COALESCE(SUM(amount) FILTER (WHERE kind IN ('opening', 'mint')), 0),
+		       COALESCE(SUM(amount) FILTER (WHERE kind = 'burn'), 0),
+		       COUNT(*)
+		FROM ledger_entries
+	`).Scan(&report.CheckedAt, &report.Balances, &report.Wallets, &report.Minted, &report.Burned, &report.Entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum balances: %w", err)
+	}
+
+	if supply >= 0 && report.Balances != supply {
+		report.Discrepancies = append(report.Discrepancies, Discrepancy{
+			Check:  CheckTotalSupply,
+			Detail: fmt.Sprintf("balances add up to %d, expected %d", report.Balances, supply),
+		})
+	}
+	if issued := report.Minted - report.Burned; report.Balances != issued {
+		report.Discrepancies = append(report.Discrepancies, Discrepancy{
+			Check:  CheckMintedMinusBurned,
+			Detail: fmt.Sprintf("balances add up to %d, minted %d minus burned %d is %d", report.Balances, report.Minted, report.Burned, issued),
+		})
+	}
+
+	// Every check returns the address and the values its detail is formatted with
+	checks := []struct {
+		name   string
+		query  string
+		detail string
+	}{
+		{CheckNegativeBalance, `
+			SELECT address, balance FROM wallets WHERE balance < 0
+			UNION ALL
+			SELECT address, balance FROM wallet_shards WHERE balance < 0
+		`, "negative balance %d"},
+		{CheckShardedRowBalance, `
+			SELECT w.address, w.balance FROM wallets w
+			WHERE w.balance <> 0 AND EXISTS (SELECT 1 FROM wallet_shards s WHERE s.address = w.address)
+		`, "sharded wallet holds %d outside of its slots"},
+		{CheckDerivedBalance, `
+			WITH movements AS (
+				SELECT to_address AS address, amount FROM ledger_entries WHERE to_address IS NOT NULL
+				UNION ALL
+				SELECT from_address, -amount FROM ledger_entries WHERE from_address IS NOT NULL
+			), derived AS (
+				SELECT address, SUM(amount) AS balance FROM movements GROUP BY address
+			), stored AS (
+				SELECT w.address,
+				       w.balance + COALESCE((SELECT SUM(s.balance) FROM wallet_shards s WHERE s.address = w.address), 0) AS balance
+				FROM wallets w
+			)
+			SELECT stored.address, stored.balance, COALESCE(derived.balance, 0)
+			FROM stored LEFT JOIN derived ON derived.address = stored.address
+			WHERE stored.balance <> COALESCE(derived.balance, 0)
+			ORDER BY stored.address
+		`, "balance is %d, ledger entries add up to %d"},
+		{CheckOrphanEntry, `
+			WITH referenced AS (
+				SELECT id, from_address AS address FROM ledger_entries WHERE from_address IS NOT NULL
+				UNION ALL
+				SELECT id, to_address FROM ledger_entries WHERE to_address IS NOT NULL
+			)
+			SELECT r.address, COUNT(*), MIN(r.id)
+			FROM referenced r
+			WHERE NOT EXISTS (SELECT 1 FROM wallets w WHERE w.address = r.address)
+			GROUP BY r.address
+			ORDER BY r.address
+		`, "%d ledger entries refer to this missing wallet, the first one is %d"},
+	}
+	for _, check := range checks {
+		found, err := runCheck(ctx, tx, check.name, check.query, check.detail)
+		if err != nil {
+			return nil, err
+		}
+		report.Discrepancies = append(report.Discrepancies, found...)
+	}
+
+	report.OK = len(report.Discrepancies) == 0
+	return report, nil
+}
+
+// runCheck reports a discrepancy for every row of query: an address followed by the values of detail.
+func runCheck(ctx context.Context, tx *sql.Tx, name, query, detail string) ([]Discrepancy, error) {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run check %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run check %s: %w", name, err)
+	}
+	var found []Discrepancy
+	for rows.Next() {
+		var address string
+		numbers := make([]int64, len(columns)-1)
+		dest := []any{&address}
+		for i := range numbers {
+			dest = append(dest, &numbers[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to run check %s: %w", name, err)
+		}
+		values := make([]any, len(numbers))
+		for i, n := range numbers {
+			values[i] = n
+		}
+		found = append(found, Discrepancy{Check: name, Address: address, Detail: fmt.Sprintf(detail, values...)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to run check %s: %w", name, err)
+	}
+	return found, nil
+}
@@ -0,0 +1,118 @@
+package ledger
+
+import (
+	"context"
+	"testing"
+)
+
+// checksFound returns the names of the checks that reported a discrepancy
+func checksFound(report *Report) map[string]bool {
+	checks := map[string]bool{}
+	for _, d := range report.Discrepancies {
+		checks[d.Check] = true
+	}
+	return checks
+}
+
+// 1. Consistent ledger
+// Goal: Transfers keep the invariants, Verify reports nothing.
+func TestVerify_Consistent(t *testing.T) {
+	store := newShardedStore(t, 1000, 4)
+	if err := store.CreateWallet(context.Background(), "0xplain", 500); err != nil {
+		t.Fatalf("CreateWallet failed: %v", err)
+	}
+
+	if _, err := store.Transfer(context.Background(), hotAddress, "0xplain", 300); err != nil {
+		t.Fatalf("Transfer failed: %v", err)
+	}
+	if _, err := store.Transfer(context.Background(), "0xplain", "0xfresh", 100); err != nil {
+		t.Fatalf("Transfer failed: %v", err)
+	}
+
+	report, err := store.Verify(context.Background(), 1500)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !report.OK || len(report.Discrepancies) != 0 {
+		t.Errorf("Expected no discrepancies, got: %+v", report.Discrepancies)
+	}
+	if report.Minted != 1500 || report.Burned != 0 || report.Balances != 1500 || report.Entries != 4 {
+		t.Errorf("Expected 1500 minted in 4 entries, got: %+v", report)
+	}
+}
+
+// 2. Broken ledger
+// Goal: Tokens created outside of a transfer are reported.
+func TestVerify_Discrepancies(t *testing.T) {
+	store := newShardedStore(t, 1000, 4)
+
+	// Bypass the store: the hot wallet row gets funds next to its slots
+	if _, err := store.DB.Exec("UPDATE wallets SET balance = 7 WHERE address = $1", hotAddress); err != nil {
+		t.Fatalf("Failed to corrupt wallet: %v", err)
+	}
+
+	report, err := store.Verify(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	checks := checksFound(report)
+	if !checks[CheckTotalSupply] || !checks[CheckShardedRowBalance] {
+		t.Errorf("Expected total supply and sharded row discrepancies, got: %+v", report.Discrepancies)
+	}
+	if !checks[CheckMintedMinusBurned] || !checks[CheckDerivedBalance] || report.OK {
+		t.Errorf("Expected the ledger entries to disagree, got: %+v", report.Discrepancies)
+	}
+}
+
+// 3. Ledger entries in every mode
+// Goal: Every transaction mode records entries that add up to the balances.
+func TestVerify_EntriesInEveryMode(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		if _, ok := store.(*MemoryStore); ok {
+			t.Skip("The in-memory store keeps no ledger entries")
+		}
+		ctx := context.Background()
+		if err := store.CreateWallet(ctx, "0xsource", 100); err != nil {
+			t.Fatalf("CreateWallet failed: %v", err)
+		}
+		for _, to := range []string{"0xa", "0xb", "0xa"} {
+			if _, err := store.Transfer(ctx, "0xsource", to, 10); err != nil {
+				t.Fatalf("Transfer failed: %v", err)
+			}
+		}
+		// Failed transfers leave no entry behind
+		if _, err := store.Transfer(ctx, "0xsource", "0xa", 1000); err == nil {
+			t.Fatalf("Expected insufficient balance")
+		}
+
+		report, err := postgresOf(t, store).Verify(ctx, 100)
+		if err != nil {
+			t.Fatalf("Verify failed: %v", err)
+		}
+		if !report.OK || report.Entries != 4 {
+			t.Errorf("Expected 4 consistent entries, got: %+v", report)
+		}
+	})
+}
+
+// 4. Orphan entries
+// Goal: Entries of a wallet that was removed are reported.
+func TestVerify_OrphanEntries(t *testing.T) {
+	store := NewPostgresStore(getDB(t))
+	ctx := context.Background()
+	if err := store.CreateWallet(ctx, "0xgone", 50); err != nil {
+		t.Fatalf("CreateWallet failed: %v", err)
+	}
+	if _, err := store.DB.Exec("DELETE FROM wallets WHERE address = '0xgone'"); err != nil {
+		t.Fatalf("Failed to delete wallet: %v", err)
+	}
+
+	report, err := store.Verify(ctx, -1)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	checks := checksFound(report)
+	if !checks[CheckOrphanEntry] || !checks[CheckMintedMinusBurned] || checks[CheckTotalSupply] {
+		t.Errorf("Expected orphan and minted discrepancies only, got: %+v", report.Discrepancies)
+	}
+}
@@ -0,0 +1,103 @@
+package loaders
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Loader batches the lookups of one request: keys asked for within wait of the first one are fetched
+// together, with a single call of fetch, and every key is fetched at most once.
+type Loader[K comparable, V any] struct {
+	fetch    func(ctx context.Context, keys []K) (map[K]V, error)
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	results map[K]*result[V]
+	// pending collects the keys of the next fetch, nil until a key is asked for
+	pending *batch[K, V]
+}
+
+type result[V any] struct {
+	done  chan struct{}
+	value V
+	found bool
+	err   error
+}
+
+type batch[K comparable, V any] struct {
+	keys    []K
+	results []*result[V]
+	sent    bool
+}
+
+// NewLoader returns a loader fetching up to maxBatch keys at once.
+func NewLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error), wait time.Duration, maxBatch int) *Loader[K, V] {
+	return &Loader[K, V]{fetch: fetch, wait: wait, maxBatch: maxBatch, results: map[K]*result[V]{}}
+}
+
+// Load returns the value of key; found is false when fetch didn't return it.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (value V, found bool, err error) {
+	l.mu.Lock()
+	r, ok := l.results[key]
+	if !ok {
+		r = &result[V]{done: make(chan struct{})}
+		l.results[key] = r
+		if l.pending == nil {
+			b := &batch[K, V]{}
+			l.pending = b
+			// The fetch runs with the context of the first lookup; all of them come from the same request
+			time.AfterFunc(l.wait, func() { l.dispatch(ctx, b) })
+		}
+		l.pending.keys = append(l.pending.keys, key)
+		l.pending.results = append(l.pending.results, r)
+		if len(l.pending.keys) >= l.maxBatch {
+			go l.dispatch(ctx, l.pending)
+			l.pending = nil
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.value, r.found, r.err
+	case <-ctx.Done():
+		return value, false, ctx.Err()
+	}
+}
+
+// dispatch fetches a batch, once: full batches are sent right away, before their timer fires.
+func (l *Loader[K, V]) dispatch(ctx context.Context, b *batch[K, V]) {
+	l.mu.Lock()
+	if b.sent {
+		l.mu.Unlock()
+		return
+	}
+	b.sent = true
+	if l.pending == b {
+		l.pending = nil
+	}
+	l.mu.Unlock()
+
+	values, err := l.fetch(ctx, b.keys)
+	for i, r := range b.results {
+		if err != nil {
+			r.err = err
+		} else {
+			r.value, r.found = values[b.keys[i]]
+		}
+		close(r.done)
+	}
+
+	// A failed lookup is not kept, so a later field of the request can try again
+	if err != nil {
+		l.mu.Lock()
+		for i, key := range b.keys {
+			if l.results[key] == b.results[i] {
+				delete(l.results, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,200 @@
+// Package loaders batches the lookups of the GraphQL resolvers within a request: wallets, their metadata
+// and ledger entries asked for by many fields are read with one query per kind instead of one per field (N+1).
+package loaders
+
+import (
+	"btp-transfer/ledger"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// wait is how long a lookup waits for others to join its batch. gqlgen resolves the fields of a list
+// concurrently, so they ask within microseconds of each other.
+const wait = time.Millisecond
+
+// maxBatch caps the keys of one query
+const maxBatch = 500
+
+// Source is where the loaders read from.
+type Source struct {
+	Balances ledger.BalanceReader
+	// History serves ledger entries; nil when the store keeps none (memory)
+	History ledger.History
+	// Directory serves wallet metadata; nil when the store keeps none (memory)
+	Directory ledger.Directory
+}
+
+// OneByOne reads balances one address at a time, for stores without batch reads.
+func OneByOne(store ledger.Store) ledger.BalanceReader {
+	return oneByOne{store}
+}
+
+type oneByOne struct {
+	store ledger.Store
+}
+
+func (o oneByOne) Balances(ctx context.Context, addresses []string) (map[string]int64, error) {
+	balances := make(map[string]int64, len(addresses))
+	for _, address := range addresses {
+		balance, err := o.store.Balance(ctx, address)
+		if errors.Is(err, ledger.ErrWalletNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		balances[address] = balance
+	}
+	return balances, nil
+}
+
+// pageKey asks for the entries of a wallet, as History.WalletEntries does.
+type pageKey struct {
+	address string
+	before  int64
+	limit   int
+}
+
+// pagesOf reads the pages of many wallets with one query for each page position, usually just one:
+// the same field of a list asks with the same before and limit for every wallet.
+func pagesOf(history ledger.History) func(ctx context.Context, keys []pageKey) (map[pageKey][]ledger.Entry, error) {
+	return func(ctx context.Context, keys []pageKey) (map[pageKey][]ledger.Entry, error) {
+		type position struct {
+			before int64
+			limit  int
+		}
+		var positions []position
+		addresses := map[position][]string{}
+		for _, key := range keys {
+			p := position{key.before, key.limit}
+			if _, ok := addresses[p]; !ok {
+				positions = append(positions, p)
+			}
+			addresses[p] = append(addresses[p], key.address)
+		}
+
+		pages := make(map[pageKey][]ledger.Entry, len(keys))
+		for _, p := range positions {
+			entries, err := history.WalletsEntries(ctx, addresses[p], p.before, p.limit)
+			if err != nil {
+				return nil, err
+			}
+			for address, page := range entries {
+				pages[pageKey{address, p.before, p.limit}] = page
+			}
+		}
+		return pages, nil
+	}
+}
+
+// Loaders holds the loaders of one request.
+type Loaders struct {
+	wallets   *Loader[string, int64]
+	transfers *Loader[int64, ledger.Entry]
+	pages     *Loader[pageKey, []ledger.Entry]
+	metadata  *Loader[string, ledger.Metadata]
+	history   ledger.History
+}
+
+// New returns loaders with nothing cached, for one request.
+func New(src Source) *Loaders {
+	l := &Loaders{wallets: NewLoader(src.Balances.Balances, wait, maxBatch), history: src.History}
+	if src.History != nil {
+		l.transfers = NewLoader(src.History.Entries, wait, maxBatch)
+		l.pages = NewLoader(pagesOf(src.History), wait, maxBatch)
+	}
+	if src.Directory != nil {
+		l.metadata = NewLoader(src.Directory.Metadata, wait, maxBatch)
+	}
+	return l
+}
+
+// Balance returns the balance of a wallet, WalletNotFoundError if it doesn't exist.
+func (l *Loaders) Balance(ctx context.Context, address string) (int64, error) {
+	balance, found, err := l.wallets.Load(ctx, address)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, &ledger.WalletNotFoundError{Address: address}
+	}
+	return balance, nil
+}
+
+// Transfer returns the ledger entry with the given ID, nil if there is none or the store keeps no entries.
+func (l *Loaders) Transfer(ctx context.Context, id int64) (*ledger.Entry, error) {
+	if l.transfers == nil {
+		return nil, nil
+	}
+	entry, found, err := l.transfers.Load(ctx, id)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// History returns the history of the source with the pages of wallets read together, nil if the source has none.
+func (l *Loaders) History() ledger.History {
+	if l.history == nil {
+		return nil
+	}
+	return pagedHistory{l}
+}
+
+// pagedHistory reads WalletEntries through the loaders and the rest straight from the source.
+type pagedHistory struct {
+	l *Loaders
+}
+
+func (h pagedHistory) WalletEntries(ctx context.Context, address string, before int64, limit int) ([]ledger.Entry, error) {
+	entries, _, err := h.l.pages.Load(ctx, pageKey{address, before, limit})
+	return entries, err
+}
+
+func (h pagedHistory) WalletsEntries(ctx context.Context, addresses []string, before int64, limit int) (map[string][]ledger.Entry, error) {
+	return h.l.history.WalletsEntries(ctx, addresses, before, limit)
+}
+
+func (h pagedHistory) Entries(ctx context.Context, ids []int64) (map[int64]ledger.Entry, error) {
+	return h.l.history.Entries(ctx, ids)
+}
+
+// Metadata returns the metadata of a wallet, empty if it has none or the store keeps none.
+func (l *Loaders) Metadata(ctx context.Context, address string) (ledger.Metadata, error) {
+	empty := ledger.Metadata{Tags: []string{}, Attributes: map[string]any{}}
+	if l.metadata == nil {
+		return empty, nil
+	}
+	metadata, found, err := l.metadata.Load(ctx, address)
+	if err != nil || !found {
+		return empty, err
+	}
+	return metadata, nil
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying l.
+func NewContext(ctx context.Context, l *Loaders) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// For returns the loaders of the request, nil outside of Middleware.
+func For(ctx context.Context) *Loaders {
+	l, _ := ctx.Value(contextKey{}).(*Loaders)
+	return l
+}
+
+// Middleware gives every request loaders of its own, so nothing is cached across requests.
+// Websocket connections get none: they run many operations, which must not see each other's results.
+func Middleware(src Source, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), New(src))))
+	})
+}
@@ -0,0 +1,187 @@
+package loaders
+
+import (
+	"btp-transfer/ledger"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// countingReader serves fixed balances and records the addresses of every call
+type countingReader struct {
+	mu       sync.Mutex
+	balances map[string]int64
+	calls    [][]string
+	err      error
+}
+
+func (c *countingReader) Balances(ctx context.Context, addresses []string) (map[string]int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, addresses)
+	if c.err != nil {
+		return nil, c.err
+	}
+	found := map[string]int64{}
+	for _, address := range addresses {
+		if balance, ok := c.balances[address]; ok {
+			found[address] = balance
+		}
+	}
+	return found, nil
+}
+
+// loadAll looks up addresses concurrently, like gqlgen resolving the fields of a list
+func loadAll(l *Loaders, addresses []string) ([]int64, []error) {
+	balances := make([]int64, len(addresses))
+	errs := make([]error, len(addresses))
+	var wg sync.WaitGroup
+	for i, address := range addresses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			balances[i], errs[i] = l.Balance(context.Background(), address)
+		}()
+	}
+	wg.Wait()
+	return balances, errs
+}
+
+// 1. Batching
+// Goal: Concurrent lookups are read with one call, each address once; a missing wallet is WalletNotFound.
+func TestLoaders_Batching(t *testing.T) {
+	reader := &countingReader{balances: map[string]int64{"0xa": 10, "0xb": 20}}
+	l := New(Source{Balances: reader})
+
+	balances, errs := loadAll(l, []string{"0xa", "0xb", "0xa", "0xc"})
+	if len(reader.calls) != 1 || len(reader.calls[0]) != 3 {
+		t.Fatalf("Expected one call with 3 addresses, got %v", reader.calls)
+	}
+	if balances[0] != 10 || balances[1] != 20 || balances[2] != 10 {
+		t.Errorf("Expected balances 10, 20, 10, got %v", balances)
+	}
+	if !errors.Is(errs[3], ledger.ErrWalletNotFound) {
+		t.Errorf("Expected WalletNotFound for 0xc, got %v", errs[3])
+	}
+
+	// Already loaded addresses are not read again within the request
+	if _, err := l.Balance(context.Background(), "0xb"); err != nil || len(reader.calls) != 1 {
+		t.Errorf("Expected 0xb from the cache, got %v after %d calls", err, len(reader.calls))
+	}
+}
+
+// 2. Errors
+// Goal: A failed read fails every lookup of the batch and is tried again by the next one.
+func TestLoaders_Errors(t *testing.T) {
+	reader := &countingReader{balances: map[string]int64{"0xa": 10}, err: errors.New("connection lost")}
+	l := New(Source{Balances: reader})
+
+	_, errs := loadAll(l, []string{"0xa", "0xb"})
+	for i, err := range errs {
+		if err == nil || errors.Is(err, ledger.ErrWalletNotFound) {
+			t.Errorf("Expected the read error for lookup %d, got %v", i, err)
+		}
+	}
+
+	reader.err = nil
+	if balance, err := l.Balance(context.Background(), "0xa"); err != nil || balance != 10 {
+		t.Errorf("Expected balance 10 on retry, got %d: %v", balance, err)
+	}
+	if len(reader.calls) != 2 {
+		t.Errorf("Expected 2 calls, got %d", len(reader.calls))
+	}
+}
+
+// countingHistory serves one entry received by every wallet but 0xnone and records the calls of WalletsEntries
+type countingHistory struct {
+	mu    sync.Mutex
+	calls [][]string
+}
+
+func (c *countingHistory) WalletEntries(ctx context.Context, address string, before int64, limit int) ([]ledger.Entry, error) {
+	entries, err := c.WalletsEntries(ctx, []string{address}, before, limit)
+	return entries[address], err
+}
+
+func (c *countingHistory) WalletsEntries(ctx context.Context, addresses []string, before int64, limit int) (map[string][]ledger.Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, addresses)
+	entries := map[string][]ledger.Entry{}
+	for _, address := range addresses {
+		if address != "0xnone" {
+			entries[address] = []ledger.Entry{{ID: before - 1, Kind: ledger.EntryTransfer, To: address, Amount: int64(limit)}}
+		}
+	}
+	return entries, nil
+}
+
+func (c *countingHistory) Entries(ctx context.Context, ids []int64) (map[int64]ledger.Entry, error) {
+	return nil, nil
+}
+
+// 3. Wallet pages
+// Goal: Pages of many wallets asked for at the same position are read with one call; a wallet without entries has an empty page.
+func TestLoaders_Pages(t *testing.T) {
+	history := &countingHistory{}
+	l := New(Source{Balances: &countingReader{}, History: history})
+
+	keys := []pageKey{{"0xa", 10, 3}, {"0xb", 10, 3}, {"0xnone", 10, 3}, {"0xa", 5, 3}}
+	pages := make([][]ledger.Entry, len(keys))
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pages[i], errs[i] = l.History().WalletEntries(context.Background(), key.address, key.before, key.limit)
+		}()
+	}
+	wg.Wait()
+
+	if len(history.calls) != 2 {
+		t.Fatalf("Expected one call per position, got %v", history.calls)
+	}
+	for i, key := range keys {
+		if errs[i] != nil {
+			t.Fatalf("Expected the page of %s, got %v", key.address, errs[i])
+		}
+		if key.address == "0xnone" {
+			if len(pages[i]) != 0 {
+				t.Errorf("Expected an empty page for 0xnone, got %+v", pages[i])
+			}
+			continue
+		}
+		if len(pages[i]) != 1 || pages[i][0].To != key.address || pages[i][0].ID != key.before-1 {
+			t.Errorf("Expected the page of %s below %d, got %+v", key.address, key.before, pages[i])
+		}
+	}
+	if New(Source{Balances: &countingReader{}}).History() != nil {
+		t.Error("Expected no history without a source history")
+	}
+}
+
+// 4. Middleware
+// Goal: Every request gets loaders of its own, websocket upgrades none.
+func TestMiddleware(t *testing.T) {
+	var got []*Loaders
+	h := Middleware(Source{Balances: &countingReader{}}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, For(r.Context()))
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/query", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/query", nil))
+	ws := httptest.NewRequest(http.MethodGet, "/query", nil)
+	ws.Header.Set("Upgrade", "websocket")
+	h.ServeHTTP(httptest.NewRecorder(), ws)
+
+	if got[0] == nil || got[1] == nil || got[0] == got[1] {
+		t.Errorf("Expected distinct loaders per request, got %p and %p", got[0], got[1])
+	}
+	if got[2] != nil {
+		t.Error("Expected no loaders for a websocket upgrade")
+	}
+}
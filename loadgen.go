@@ -0,0 +1,93 @@
+package main
+
+import (
+	"btp-transfer/genesis"
+	"btp-transfer/loadgen"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// runLoadgen sends a corpus or synthetic transfers to a running server and reports how it coped.
+func runLoadgen(args []string) error {
+	var (
+		url, corpusPath, principal string
+		loop, asJSON               bool
+		opts                       loadgen.Options
+		synth                      loadgen.SyntheticOptions
+		timeout                    time.Duration
+	)
+	fs := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	fs.StringVar(&url, "url", "http://localhost:8080/query", "GraphQL endpoint of the server under load")
+	fs.StringVar(&corpusPath, "corpus", "", "JSONL file of {query, operationName, variables} requests to replay; synthetic transfers without it")
+	fs.BoolVar(&loop, "loop", false, "replay the corpus from the start when it runs out")
+	fs.IntVar(&opts.Concurrency, "concurrency", 8, "requests in flight at most")
+	fs.Float64Var(&opts.Rate, "rate", 0, "requests started per second at most, 0 = as fast as possible")
+	fs.DurationVar(&opts.Duration, "duration", 30*time.Second, "stop starting requests after this long, 0 = no limit")
+	fs.Int64Var(&opts.Requests, "requests", 0, "stop after this many requests, 0 = no limit")
+	fs.DurationVar(&timeout, "timeout", 10*time.Second, "timeout of one request")
+	fs.StringVar(&principal, "principal", "loadgen", "sent as X-Principal, the audit log shows it")
+	fs.BoolVar(&asJSON, "json", false, "print the report as JSON")
+	fs.IntVar(&synth.Wallets, "wallets", 100, "synthetic: number of generated wallets")
+	fs.StringVar(&synth.Distribution, "distribution", loadgen.DistributionZipf, "synthetic: choice of addresses, uniform or zipf")
+	fs.Float64Var(&synth.ZipfS, "zipf-s", 1.2, "synthetic: Zipf exponent, greater than 1; larger is more skewed")
+	fs.Int64Var(&synth.MaxAmount, "max-amount", 10, "synthetic: largest amount of a transfer")
+	fs.Int64Var(&synth.Fund, "fund", 1000, "synthetic: tokens sent to every generated wallet before the run")
+	fs.StringVar(&synth.Funder, "funder", genesis.DefaultAddress, "synthetic: wallet the generated wallets are funded from")
+	fs.StringVar(&synth.Prefix, "prefix", strconv.FormatInt(time.Now().Unix(), 36), "synthetic: makes the generated addresses unique to a run")
+	fs.Uint64Var(&synth.Seed, "seed", uint64(time.Now().UnixNano()), "synthetic: random seed, the same seed and prefix replay the same transfers")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Ctrl-C stops the run early; the report and the consistency check still happen
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	client := &loadgen.Client{URL: url, HTTP: &http.Client{Timeout: timeout}, Principal: principal}
+
+	var report *loadgen.Report
+	if corpusPath != "" {
+		f, err := os.Open(corpusPath)
+		if err != nil {
+			return err
+		}
+		corpus, err := loadgen.ReadCorpus(f, loop)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", corpusPath, err)
+		}
+		report = loadgen.Run(ctx, client, corpus, opts)
+		report.Consistency = loadgen.CheckLedger(context.Background(), client)
+	} else {
+		source, err := loadgen.NewSynthetic(synth)
+		if err != nil {
+			return err
+		}
+		if err := source.Fund(ctx, client); err != nil {
+			return err
+		}
+		report = loadgen.Run(ctx, client, source, opts)
+		report.Consistency = source.Check(context.Background(), client)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		report.Print(os.Stdout)
+	}
+	if report.Consistency.Status == loadgen.ConsistencyFailed {
+		return fmt.Errorf("consistency check failed")
+	}
+	return nil
+}
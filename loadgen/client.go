@@ -0,0 +1,108 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Codes of failures that don't come from the API itself
+const (
+	// CodeTransport is a request that got no response (connection refused, timeout...)
+	CodeTransport = "TRANSPORT_ERROR"
+	// CodeUnknown is a GraphQL error without extensions.code
+	CodeUnknown = "UNKNOWN_ERROR"
+)
+
+// maxResponseBytes caps how much of a response is read
+const maxResponseBytes = 1 << 20
+
+// Request is one GraphQL request, also the format of a corpus line.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Result is the outcome of one request.
+type Result struct {
+	Latency time.Duration
+	// Code is empty on success, otherwise extensions.code of the first error, HTTP_<status>,
+	// CodeUnknown or CodeTransport
+	Code    string
+	Message string
+	Data    json.RawMessage
+}
+
+// Client sends GraphQL requests to one endpoint.
+type Client struct {
+	URL  string
+	HTTP *http.Client
+	// Principal is sent in the X-Principal header, so the load shows up as such in the audit log
+	Principal string
+}
+
+// graphQLResponse is the part of a response the client looks at
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string `json:"message"`
+		Extensions struct {
+			Code string `json:"code"`
+		} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// Do sends req and classifies the response.
+func (c *Client) Do(ctx context.Context, req Request) Result {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return Result{Code: CodeTransport, Message: err.Error()}
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return Result{Code: CodeTransport, Message: err.Error()}
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.Principal != "" {
+		httpReq.Header.Set("X-Principal", c.Principal)
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	start := time.Now()
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return Result{Latency: time.Since(start), Code: CodeTransport, Message: err.Error()}
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	res := Result{Latency: time.Since(start)}
+	if err != nil {
+		res.Code, res.Message = CodeTransport, err.Error()
+		return res
+	}
+
+	// gqlgen answers invalid operations with 422 and a regular error body
+	var parsed graphQLResponse
+	if err := json.Unmarshal(raw, &parsed); err == nil && len(parsed.Errors) > 0 {
+		first := parsed.Errors[0]
+		res.Code, res.Message = first.Extensions.Code, first.Message
+		if res.Code == "" {
+			res.Code = CodeUnknown
+		}
+		return res
+	}
+	if resp.StatusCode != http.StatusOK {
+		res.Code, res.Message = fmt.Sprintf("HTTP_%d", resp.StatusCode), http.StatusText(resp.StatusCode)
+		return res
+	}
+	res.Data = parsed.Data
+	return res
+}
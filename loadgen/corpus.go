@@ -0,0 +1,67 @@
+package loadgen
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Corpus replays recorded requests in order, once or in a loop.
+type Corpus struct {
+	requests []Request
+	loop     bool
+	next     int
+}
+
+// ReadCorpus reads one GraphQL request per line ({"query": ..., "variables": ..., "operationName": ...}).
+// Blank lines and lines starting with # are skipped.
+func ReadCorpus(r io.Reader, loop bool) (*Corpus, error) {
+	c := &Corpus{loop: loop}
+	scanner := bufio.NewScanner(r)
+	// Operations with large variables don't fit the default 64 KiB
+	scanner.Buffer(make([]byte, 0, 64*1024), maxResponseBytes)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var req Request
+		if err := json.Unmarshal([]byte(text), &req); err != nil {
+			return nil, fmt.Errorf("corpus line %d: %w", line, err)
+		}
+		if req.Query == "" {
+			return nil, fmt.Errorf("corpus line %d: query is missing", line)
+		}
+		c.requests = append(c.requests, req)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(c.requests) == 0 {
+		return nil, fmt.Errorf("corpus has no requests")
+	}
+	return c, nil
+}
+
+// Len is the number of requests in the corpus.
+func (c *Corpus) Len() int {
+	return len(c.requests)
+}
+
+// Next implements Source.
+func (c *Corpus) Next() (Request, bool) {
+	if c.next == len(c.requests) {
+		if !c.loop {
+			return Request{}, false
+		}
+		c.next = 0
+	}
+	req := c.requests[c.next]
+	c.next++
+	return req, true
+}
+
+// Done implements Source, a corpus doesn't track outcomes.
+func (c *Corpus) Done(Request, Result) {}
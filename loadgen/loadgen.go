@@ -0,0 +1,95 @@
+// Package loadgen sends GraphQL traffic to a running server and reports how it coped: throughput,
+// latency percentiles, error codes, and whether the balances are consistent afterwards.
+//
+// Traffic comes from a Source: a recorded corpus replayed as is (Corpus), or synthetic transfers
+// between generated wallets with a uniform or Zipfian choice of addresses (Synthetic).
+package loadgen
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Source produces the requests of a run. Next is never called concurrently, Done may be.
+type Source interface {
+	// Next returns the next request, false when the source is exhausted
+	Next() (Request, bool)
+	// Done is told the result of every request returned by Next
+	Done(req Request, res Result)
+}
+
+// Options bound a run. Without Duration and Requests, it runs until the source is exhausted
+// or ctx is canceled.
+type Options struct {
+	// Concurrency is the number of requests in flight at most (default 1)
+	Concurrency int
+	// Rate caps the requests started per second, 0 means as fast as the workers go
+	Rate float64
+	// Duration stops starting new requests after this long
+	Duration time.Duration
+	// Requests stops after this many requests
+	Requests int64
+}
+
+// Run sends the requests of source until opts or ctx stop it and reports the results.
+// Requests in flight when the run ends are completed, not canceled, so their outcome is known;
+// canceling ctx cancels them.
+func Run(ctx context.Context, client *Client, source Source, opts Options) *Report {
+	concurrency := max(opts.Concurrency, 1)
+
+	// Only gates new requests, see above
+	running := ctx
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		running, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	var pace <-chan time.Time
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+		defer ticker.Stop()
+		pace = ticker.C
+	}
+
+	rec := newRecorder()
+	var next sync.Mutex
+	var started atomic.Int64
+	var wg sync.WaitGroup
+	start := time.Now()
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if pace != nil {
+					select {
+					case <-running.Done():
+						return
+					case <-pace:
+					}
+				}
+				if running.Err() != nil {
+					return
+				}
+				if opts.Requests > 0 && started.Add(1) > opts.Requests {
+					return
+				}
+
+				next.Lock()
+				req, ok := source.Next()
+				next.Unlock()
+				if !ok {
+					return
+				}
+				res := client.Do(ctx, req)
+				source.Done(req, res)
+				rec.add(res)
+			}
+		}()
+	}
+	wg.Wait()
+	return rec.report(time.Since(start))
+}
@@ -0,0 +1,131 @@
+package loadgen
+
+import (
+	"btp-transfer/graph"
+	"btp-transfer/ledger"
+	"context"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+const funder = "0x0000000000000000000000000000000000000000"
+
+// newServer serves the GraphQL API over a memory store with a funded genesis wallet.
+func newServer(t *testing.T) (*Client, *ledger.MemoryStore) {
+	t.Helper()
+	store := ledger.NewMemoryStore()
+	if err := store.CreateWallet(context.Background(), funder, 1_000_000); err != nil {
+		t.Fatalf("Failed to create funder: %v", err)
+	}
+	srv := httptest.NewServer(graph.NewHandler(&graph.Resolver{Store: store}, graph.Options{
+		Limits: graph.Limits{MaxDepth: 10, MaxComplexity: 200, MaxBodyBytes: 1 << 20},
+	}))
+	t.Cleanup(srv.Close)
+	return &Client{URL: srv.URL, HTTP: srv.Client(), Principal: "loadgen-test"}, store
+}
+
+// 1. Synthetic traffic
+// Goal: Zipfian transfers between funded wallets keep the balances the load generator expects.
+func TestRun_Synthetic(t *testing.T) {
+	client, _ := newServer(t)
+	source, err := NewSynthetic(SyntheticOptions{
+		Wallets: 20, Distribution: DistributionZipf, ZipfS: 1.5, MaxAmount: 50,
+		Funder: funder, Fund: 100, Prefix: "t", Seed: 1,
+	})
+	if err != nil {
+		t.Fatalf("NewSynthetic failed: %v", err)
+	}
+	if err := source.Fund(context.Background(), client); err != nil {
+		t.Fatalf("Fund failed: %v", err)
+	}
+
+	report := Run(context.Background(), client, source, Options{Concurrency: 8, Requests: 500})
+	if report.Requests != 500 {
+		t.Errorf("Expected 500 requests, got %d", report.Requests)
+	}
+	if report.Succeeded == 0 {
+		t.Errorf("Expected successful transfers, got errors: %v", report.Errors)
+	}
+	// Amounts up to 50 from wallets of about 100 overdraw sometimes, nothing else fails
+	for code := range report.Errors {
+		if code != "INSUFFICIENT_BALANCE" {
+			t.Errorf("Unexpected error code %s: %v", code, report.Errors)
+		}
+	}
+
+	c := source.Check(context.Background(), client)
+	if c.Status != ConsistencyOK || c.WalletsChecked != 20 {
+		t.Errorf("Expected consistent balances of 20 wallets, got %+v", c)
+	}
+	// The memory store can't verify its ledger
+	if c.LedgerVerified {
+		t.Errorf("Expected no ledger verification without a database")
+	}
+}
+
+// 2. Inconsistent balances
+// Goal: A transfer that changes balances behind the generator's back fails the check.
+func TestCheck_DetectsDrift(t *testing.T) {
+	client, store := newServer(t)
+	source, err := NewSynthetic(SyntheticOptions{
+		Wallets: 3, Distribution: DistributionUniform, MaxAmount: 5, Funder: funder, Fund: 10, Prefix: "d",
+	})
+	if err != nil {
+		t.Fatalf("NewSynthetic failed: %v", err)
+	}
+	if err := source.Fund(context.Background(), client); err != nil {
+		t.Fatalf("Fund failed: %v", err)
+	}
+	addresses := source.Addresses()
+	if _, err := store.Transfer(context.Background(), addresses[0], addresses[1], 3); err != nil {
+		t.Fatalf("Transfer failed: %v", err)
+	}
+
+	c := source.Check(context.Background(), client)
+	if c.Status != ConsistencyFailed || len(c.Problems) != 2 {
+		t.Errorf("Expected 2 wrong balances, got %+v", c)
+	}
+}
+
+// 3. Corpus replay
+// Goal: The example corpus replays in order and its failures are counted by error code.
+func TestRun_Corpus(t *testing.T) {
+	client, _ := newServer(t)
+	f, err := os.Open("example.jsonl")
+	if err != nil {
+		t.Fatalf("Failed to open the corpus: %v", err)
+	}
+	defer f.Close()
+	corpus, err := ReadCorpus(f, true)
+	if err != nil {
+		t.Fatalf("ReadCorpus failed: %v", err)
+	}
+
+	// Twice around the loop, one request at a time to keep the order
+	report := Run(context.Background(), client, corpus, Options{Concurrency: 1, Requests: int64(2 * corpus.Len())})
+	want := map[string]int64{"INSUFFICIENT_BALANCE": 2, "WALLET_NOT_FOUND": 2}
+	if report.Succeeded != 4 || len(report.Errors) != len(want) {
+		t.Fatalf("Expected 4 successes and errors %v, got %d and %v", want, report.Succeeded, report.Errors)
+	}
+	for code, n := range want {
+		if report.Errors[code] != n {
+			t.Errorf("Expected %d %s, got %d", n, code, report.Errors[code])
+		}
+	}
+}
+
+// 4. Percentiles
+// Goal: Nearest-rank percentiles of 1..100 ms are the rank itself.
+func TestLatencySummary(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	got := latencySummary(latencies)
+	want := Latency{Mean: 50.5, P50: 50, P90: 90, P95: 95, P99: 99, Max: 100}
+	if got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,140 @@
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Report summarizes a run.
+type Report struct {
+	Requests        int64   `json:"requests"`
+	Succeeded       int64   `json:"succeeded"`
+	Failed          int64   `json:"failed"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	// Throughput is in completed requests per second
+	Throughput float64 `json:"throughput_rps"`
+	Latency    Latency `json:"latency_ms"`
+	// Errors counts failed requests by code
+	Errors      map[string]int64 `json:"errors"`
+	Consistency *Consistency     `json:"consistency,omitempty"`
+}
+
+// Latency percentiles of all requests, in milliseconds.
+type Latency struct {
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// Outcomes of the consistency check
+const (
+	ConsistencyOK = "ok"
+	// ConsistencyFailed means balances or ledger invariants are wrong
+	ConsistencyFailed = "failed"
+	// ConsistencyInconclusive means some requests may or may not have been applied (no response,
+	// internal error), so expected balances are not known exactly
+	ConsistencyInconclusive = "inconclusive"
+)
+
+// Consistency is the result of the check run after the traffic.
+type Consistency struct {
+	Status string `json:"status"`
+	// WalletsChecked is the number of wallets whose balance was compared to the expected one
+	WalletsChecked int `json:"wallets_checked"`
+	// LedgerVerified tells whether the server checked its ledger invariants (verifyLedger)
+	LedgerVerified bool     `json:"ledger_verified"`
+	Problems       []string `json:"problems"`
+}
+
+// recorder collects the results of a run.
+type recorder struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    map[string]int64
+}
+
+func newRecorder() *recorder {
+	return &recorder{errors: map[string]int64{}}
+}
+
+func (r *recorder) add(res Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies = append(r.latencies, res.Latency)
+	if res.Code != "" {
+		r.errors[res.Code]++
+	}
+}
+
+func (r *recorder) report(elapsed time.Duration) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := &Report{
+		Requests:        int64(len(r.latencies)),
+		DurationSeconds: elapsed.Seconds(),
+		Errors:          maps.Clone(r.errors),
+	}
+	for _, n := range r.errors {
+		report.Failed += n
+	}
+	report.Succeeded = report.Requests - report.Failed
+	if elapsed > 0 {
+		report.Throughput = float64(report.Requests) / elapsed.Seconds()
+	}
+	report.Latency = latencySummary(r.latencies)
+	return report
+}
+
+// latencySummary computes nearest-rank percentiles.
+func latencySummary(latencies []time.Duration) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	percentile := func(p float64) float64 {
+		rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+		return ms(sorted[min(max(rank, 0), len(sorted)-1)])
+	}
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	return Latency{
+		Mean: ms(total / time.Duration(len(sorted))),
+		P50:  percentile(50),
+		P90:  percentile(90),
+		P95:  percentile(95),
+		P99:  percentile(99),
+		Max:  ms(sorted[len(sorted)-1]),
+	}
+}
+
+// Print writes the report for humans.
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "requests:    %d in %.1fs (%.1f req/s)\n", r.Requests, r.DurationSeconds, r.Throughput)
+	fmt.Fprintf(w, "succeeded:   %d\n", r.Succeeded)
+	fmt.Fprintf(w, "failed:      %d\n", r.Failed)
+	for _, code := range slices.Sorted(maps.Keys(r.Errors)) {
+		fmt.Fprintf(w, "  %-24s %d\n", code, r.Errors[code])
+	}
+	l := r.Latency
+	fmt.Fprintf(w, "latency ms:  mean %.2f  p50 %.2f  p90 %.2f  p95 %.2f  p99 %.2f  max %.2f\n", l.Mean, l.P50, l.P90, l.P95, l.P99, l.Max)
+	if c := r.Consistency; c != nil {
+		fmt.Fprintf(w, "consistency: %s (%d wallets checked, ledger verified: %t)\n", c.Status, c.WalletsChecked, c.LedgerVerified)
+		for _, p := range c.Problems {
+			fmt.Fprintf(w, "  %s\n", p)
+		}
+	}
+}
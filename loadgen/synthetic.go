@@ -0,0 +1,206 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+)
+
+// Address distributions of synthetic traffic
+const (
+	DistributionUniform = "uniform"
+	// DistributionZipf makes a few wallets take most of the traffic, like exchange hot wallets
+	DistributionZipf = "zipf"
+)
+
+const transferMutation = `mutation Transfer($from: String!, $to: String!, $amount: Int64!) {
+  transfer(from_address: $from, to_address: $to, amount: $amount)
+}`
+
+// SyntheticOptions describe generated traffic: transfers between Wallets generated wallets.
+type SyntheticOptions struct {
+	Wallets      int
+	Distribution string
+	// ZipfS is the exponent of the Zipf distribution, greater than 1; larger is more skewed
+	ZipfS float64
+	// MaxAmount bounds the amount of a transfer, drawn uniformly from 1..MaxAmount
+	MaxAmount int64
+	// Funder is the existing wallet that funds every generated wallet with Fund tokens first
+	Funder string
+	Fund   int64
+	// Prefix makes the generated addresses unique to a run
+	Prefix string
+	Seed   uint64
+}
+
+// Synthetic generates transfers and tracks the balances they should leave behind.
+type Synthetic struct {
+	opts      SyntheticOptions
+	addresses []string
+	rng       *rand.Rand
+	zipf      *rand.Zipf
+
+	mu       sync.Mutex
+	expected map[string]int64
+	// unknownOutcomes counts transfers that may or may not have been applied
+	unknownOutcomes int64
+}
+
+// NewSynthetic validates opts and generates the wallet addresses.
+func NewSynthetic(opts SyntheticOptions) (*Synthetic, error) {
+	if opts.Wallets < 2 {
+		return nil, fmt.Errorf("synthetic traffic needs at least 2 wallets, got %d", opts.Wallets)
+	}
+	if opts.MaxAmount <= 0 {
+		return nil, fmt.Errorf("max amount must be positive, got %d", opts.MaxAmount)
+	}
+	if opts.Fund < 0 {
+		return nil, fmt.Errorf("fund must not be negative, got %d", opts.Fund)
+	}
+	s := &Synthetic{
+		opts:     opts,
+		rng:      rand.New(rand.NewPCG(opts.Seed, opts.Seed^0x9e3779b97f4a7c15)),
+		expected: map[string]int64{},
+	}
+	switch opts.Distribution {
+	case DistributionUniform:
+	case DistributionZipf:
+		if opts.ZipfS <= 1 {
+			return nil, fmt.Errorf("zipf exponent must be greater than 1, got %g", opts.ZipfS)
+		}
+		s.zipf = rand.NewZipf(s.rng, opts.ZipfS, 1, uint64(opts.Wallets-1))
+	default:
+		return nil, fmt.Errorf("distribution must be %q or %q, got: %q", DistributionUniform, DistributionZipf, opts.Distribution)
+	}
+	for i := range opts.Wallets {
+		s.addresses = append(s.addresses, fmt.Sprintf("0xload%s%06d", opts.Prefix, i))
+	}
+	return s, nil
+}
+
+// Addresses returns the generated wallets, the most used first with a Zipf distribution.
+func (s *Synthetic) Addresses() []string {
+	return s.addresses
+}
+
+func (s *Synthetic) pick() string {
+	if s.zipf != nil {
+		return s.addresses[s.zipf.Uint64()]
+	}
+	return s.addresses[s.rng.IntN(len(s.addresses))]
+}
+
+// Next implements Source, it never runs out.
+func (s *Synthetic) Next() (Request, bool) {
+	from := s.pick()
+	to := s.pick()
+	for to == from {
+		to = s.pick()
+	}
+	return transferRequest(from, to, 1+s.rng.Int64N(s.opts.MaxAmount)), true
+}
+
+// Done implements Source: applied transfers move the expected balances.
+func (s *Synthetic) Done(req Request, res Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case res.Code == "":
+		from, to, amount := req.Variables["from"].(string), req.Variables["to"].(string), req.Variables["amount"].(int64)
+		s.expected[from] -= amount
+		s.expected[to] += amount
+	case uncertain(res.Code):
+		s.unknownOutcomes++
+	}
+}
+
+// uncertain is true for failures after which a transfer may or may not have been committed:
+// no response, an internal error or a 5xx status.
+func uncertain(code string) bool {
+	switch code {
+	case CodeTransport, CodeUnknown, "INTERNAL_SERVER_ERROR":
+		return true
+	}
+	return strings.HasPrefix(code, "HTTP_5")
+}
+
+// Fund records the current balance of every generated wallet and sends it Fund tokens from Funder.
+func (s *Synthetic) Fund(ctx context.Context, client *Client) error {
+	for _, address := range s.addresses {
+		balance, err := probeBalance(ctx, client, address)
+		if err != nil {
+			return err
+		}
+		s.expected[address] = balance
+		if s.opts.Fund == 0 {
+			continue
+		}
+		req := transferRequest(s.opts.Funder, address, s.opts.Fund)
+		res := client.Do(ctx, req)
+		if res.Code != "" {
+			return fmt.Errorf("failed to fund %s from %s: %s: %s", address, s.opts.Funder, res.Code, res.Message)
+		}
+		s.expected[address] += s.opts.Fund
+	}
+	return nil
+}
+
+// Check compares the balance of every generated wallet with the transfers that succeeded,
+// then asks the server to verify its ledger.
+func (s *Synthetic) Check(ctx context.Context, client *Client) *Consistency {
+	c := &Consistency{Status: ConsistencyOK, Problems: []string{}}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.unknownOutcomes > 0 {
+		c.Status = ConsistencyInconclusive
+		c.Problems = append(c.Problems, fmt.Sprintf("%d transfers have an unknown outcome, balances are not compared", s.unknownOutcomes))
+	} else {
+		for _, address := range s.addresses {
+			balance, err := probeBalance(ctx, client, address)
+			if err != nil {
+				c.Status = ConsistencyInconclusive
+				c.Problems = append(c.Problems, err.Error())
+				break
+			}
+			c.WalletsChecked++
+			if want := s.expected[address]; balance != want {
+				c.Status = ConsistencyFailed
+				c.Problems = append(c.Problems, fmt.Sprintf("%s: balance is %d, expected %d", address, balance, want))
+			}
+		}
+	}
+
+	verifyLedger(ctx, client, c)
+	return c
+}
+
+func transferRequest(from, to string, amount int64) Request {
+	return Request{
+		Query:         transferMutation,
+		OperationName: "Transfer",
+		Variables:     map[string]any{"from": from, "to": to, "amount": amount},
+	}
+}
+
+// probeBalance reads the balance of a wallet with a transfer to itself, which changes nothing
+// and returns the balance; a wallet that doesn't exist has 0.
+func probeBalance(ctx context.Context, client *Client, address string) (int64, error) {
+	res := client.Do(ctx, transferRequest(address, address, 1))
+	if res.Code == "WALLET_NOT_FOUND" {
+		return 0, nil
+	}
+	if res.Code != "" {
+		return 0, fmt.Errorf("failed to read the balance of %s: %s: %s", address, res.Code, res.Message)
+	}
+	var data struct {
+		Transfer int64 `json:"transfer"`
+	}
+	if err := json.Unmarshal(res.Data, &data); err != nil {
+		return 0, fmt.Errorf("failed to read the balance of %s: %w", address, err)
+	}
+	return data.Transfer, nil
+}
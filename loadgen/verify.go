@@ -0,0 +1,59 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+const verifyLedgerQuery = `query VerifyLedger { verifyLedger { ok discrepancies { check address detail } } }`
+
+// verifyLedger asks the server to check its ledger invariants and adds what it finds to c.
+// A server without a database can't, which is not a failure.
+func verifyLedger(ctx context.Context, client *Client, c *Consistency) {
+	res := client.Do(ctx, Request{Query: verifyLedgerQuery, OperationName: "VerifyLedger"})
+	if res.Code == "LEDGER_UNVERIFIABLE" {
+		return
+	}
+	fail := func(status, problem string) {
+		if c.Status != ConsistencyFailed {
+			c.Status = status
+		}
+		c.Problems = append(c.Problems, problem)
+	}
+	if res.Code != "" {
+		fail(ConsistencyInconclusive, fmt.Sprintf("ledger verification failed: %s: %s", res.Code, res.Message))
+		return
+	}
+
+	var data struct {
+		VerifyLedger struct {
+			OK            bool `json:"ok"`
+			Discrepancies []struct {
+				Check   string  `json:"check"`
+				Address *string `json:"address"`
+				Detail  string  `json:"detail"`
+			} `json:"discrepancies"`
+		} `json:"verifyLedger"`
+	}
+	if err := json.Unmarshal(res.Data, &data); err != nil {
+		fail(ConsistencyInconclusive, fmt.Sprintf("ledger verification failed: %v", err))
+		return
+	}
+	c.LedgerVerified = true
+	for _, d := range data.VerifyLedger.Discrepancies {
+		problem := d.Check + ": " + d.Detail
+		if d.Address != nil {
+			problem = fmt.Sprintf("%s: %s: %s", d.Check, *d.Address, d.Detail)
+		}
+		fail(ConsistencyFailed, problem)
+	}
+}
+
+// CheckLedger is the consistency check of traffic whose effect is not tracked, like a replayed corpus:
+// only the server's own ledger verification.
+func CheckLedger(ctx context.Context, client *Client) *Consistency {
+	c := &Consistency{Status: ConsistencyOK, Problems: []string{}}
+	verifyLedger(ctx, client, c)
+	return c
+}
@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"btp-transfer/ledger"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	alice = "0x0000000000000000000000000000000000000001"
+	bob   = "0x0000000000000000000000000000000000000002"
+)
+
+// lines decodes JSON log output, one record per line
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Log line is not JSON %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// 1. Request IDs
+// Goal: A well-formed X-Request-ID is kept, a missing or malformed one is replaced by a generated ID.
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFrom(r.Context())
+	}))
+
+	for _, tc := range []struct {
+		sent string
+		kept bool
+	}{
+		{"abc-123", true},
+		{"", false},
+		{"bad id\nwith newline", false},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/query", nil)
+		if tc.sent != "" {
+			req.Header.Set(RequestIDHeader, tc.sent)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		got := rec.Header().Get(RequestIDHeader)
+		if got != seen || got == "" {
+			t.Errorf("Expected the same non-empty ID in context and response, got %q and %q", seen, got)
+		}
+		if (got == tc.sent) != tc.kept {
+			t.Errorf("Sent %q, got %q (kept: %v)", tc.sent, got, tc.kept)
+		}
+	}
+}
+
+// 2. Transfer log
+// Goal: Each transfer is logged with request ID, addresses, amount, outcome, code and duration.
+func TestStore_Transfer(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", FormatJSON)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ctx := WithRequestID(context.Background(), "req-1")
+	mem := ledger.NewMemoryStore()
+	if err := mem.CreateWallet(ctx, alice, 10); err != nil {
+		t.Fatalf("CreateWallet failed: %v", err)
+	}
+	store := NewStore(mem, logger)
+
+	store.Transfer(ctx, alice, bob, 5)
+	store.Transfer(ctx, alice, bob, 50)
+
+	records := lines(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 log lines, got %d: %s", len(records), buf.String())
+	}
+	ok, rejected := records[0], records[1]
+	if ok["msg"] != "transfer" || ok["request_id"] != "req-1" || ok["from"] != alice || ok["to"] != bob ||
+		ok["amount"] != float64(5) || ok["outcome"] != "success" || ok["duration_ms"] == nil {
+		t.Errorf("Unexpected success record: %v", ok)
+	}
+	if rejected["level"] != "WARN" || rejected["outcome"] != "error" || rejected["code"] != ledger.CodeInsufficientBalance {
+		t.Errorf("Unexpected rejection record: %v", rejected)
+	}
+}
+
+// 3. Redaction and level
+// Goal: Sensitive attributes are masked, records below the level are dropped.
+func TestNew_RedactionAndLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "warn", FormatJSON)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	logger.Info("dropped")
+	logger.Warn("connecting", "database_url", "postgres://u:s3cret@db/btp", slog.Group("auth", "Authorization", "Bearer abc"))
+
+	records := lines(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("Expected only the warning, got: %s", buf.String())
+	}
+	if strings.Contains(buf.String(), "s3cret") || strings.Contains(buf.String(), "Bearer") {
+		t.Errorf("Sensitive values leaked: %s", buf.String())
+	}
+
+	if _, err := New(&buf, "loud", FormatJSON); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+	if _, err := New(&buf, "info", "xml"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
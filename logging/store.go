@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"btp-transfer/ledger"
+)
+
+// Store logs every transfer of the wrapped ledger.Store.
+type Store struct {
+	ledger.Store
+	logger *slog.Logger
+}
+
+// NewStore wraps s so its transfers are logged to logger.
+func NewStore(s ledger.Store, logger *slog.Logger) *Store {
+	return &Store{Store: s, logger: logger}
+}
+
+// Transfer calls the wrapped store and logs the attempt with its outcome, error code and duration.
+// Rejected transfers (e.g. insufficient balance) are logged as warnings, unexpected errors as errors.
+func (s *Store) Transfer(ctx context.Context, fromAddress, toAddress string, amount int64) (int64, error) {
+	start := time.Now()
+	balance, err := s.Store.Transfer(ctx, fromAddress, toAddress, amount)
+	s.log(ctx, start, err,
+		slog.String("from", fromAddress),
+		slog.String("to", toAddress),
+		slog.Int64("amount", amount),
+	)
+	return balance, err
+}
+
+// TransferSigned calls the wrapped store and logs the attempt like Transfer, with the hash and nonce
+// of the transaction.
+func (s *Store) TransferSigned(ctx context.Context, t ledger.SignedTransfer) (int64, error) {
+	start := time.Now()
+	balance, err := ledger.TransferSigned(ctx, s.Store, t)
+	s.log(ctx, start, err,
+		slog.String("from", t.From),
+		slog.String("to", t.To),
+		slog.Int64("amount", t.Amount),
+		slog.String("tx_hash", t.Hash),
+		slog.Uint64("nonce", t.Nonce),
+	)
+	return balance, err
+}
+
+func (s *Store) log(ctx context.Context, start time.Time, err error, attrs ...slog.Attr) {
+	duration := time.Since(start)
+
+	level, outcome := slog.LevelInfo, "success"
+	var extra []slog.Attr
+	if err != nil {
+		code, _, known := ledger.Code(err)
+		level, outcome = slog.LevelWarn, "error"
+		if !known {
+			code, level = ledger.CodeInternal, slog.LevelError
+		}
+		extra = []slog.Attr{slog.String("code", code), slog.String("error", err.Error())}
+	}
+	attrs = append(attrs,
+		slog.String("outcome", outcome),
+		slog.Float64("duration_ms", float64(duration.Microseconds())/1000),
+	)
+	s.logger.LogAttrs(ctx, level, "transfer", append(attrs, extra...)...)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"btp-transfer/config"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// command is a subcommand of the binary
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"serve", "start the GraphQL server (default)", runServe},
+	{"migrate", "manage the database schema: up | down [steps] | status", runMigrate},
+	{"seed", "create the wallets listed in a genesis file", runSeed},
+	{"balance", "print the balance of a wallet: balance <address>", runBalance},
+	{"transfer", "transfer tokens directly against the store", runTransfer},
+	{"verify", "check the ledger invariants", runVerify},
+	{"export", "write the ledger entries or balances as CSV or JSONL", runExport},
+	{"check-export", "verify an export file against its trailer: check-export <file>", runCheckExport},
+	{"loadgen", "send a corpus or synthetic transfers to a server and report how it coped", runLoadgen},
+	{"config", "print the effective configuration, secrets redacted", runConfig},
+}
+
+func main() {
+	// No subcommand starts the server, as the binary always did
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" {
+		usage()
+		return
+	}
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		err := cmd.run(args)
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\ncommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '<command> -h' to list its flags. Every flag falls back to the environment variable named in its help.\n")
+}
+
+// runConfig prints the configuration the other commands would run with.
+func runConfig(args []string) error {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	cfg, err := parseConfig(fs, args, nil)
+	if err != nil {
+		return err
+	}
+	fmt.Print(cfg.Redacted())
+	return nil
+}
+
+// parseConfig builds the configuration from defaults, the config file, the environment and flags, in that order,
+// and validates the result. bind registers the flags of the subcommand on top of the flags every command shares.
+func parseConfig(fs *flag.FlagSet, args []string, bind func(fs *flag.FlagSet, cfg *config.Config)) (*config.Config, error) {
+	register := func(fs *flag.FlagSet, cfg *config.Config) {
+		fs.StringVar(&cfg.Store, "store", cfg.Store, "ledger store: postgres or memory (env STORE)")
+		fs.StringVar(&cfg.DatabaseURL, "database-url", cfg.DatabaseURL, "PostgreSQL connection string (env DATABASE_URL)")
+		if bind != nil {
+			bind(fs, cfg)
+		}
+	}
+
+	// First pass finds the config file and which flags are given; defaults shown by -h are the built-in ones
+	configFile := os.Getenv("CONFIG_FILE")
+	fs.StringVar(&configFile, "config", configFile, "YAML configuration file (env CONFIG_FILE)")
+	register(fs, config.Defaults())
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.FromEnv(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Flags given on the command line override every other layer
+	layered := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	register(layered, cfg)
+	var errs []error
+	fs.Visit(func(f *flag.Flag) {
+		if layered.Lookup(f.Name) != nil {
+			errs = append(errs, layered.Set(f.Name, f.Value.String()))
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// maxOperationNames caps the number of distinct operation label values, as operation names
+// come from clients. Names seen after the cap is reached are reported as "other".
+const maxOperationNames = 100
+
+// Operation label values for requests without a usable name
+const (
+	OperationAnonymous = "anonymous"
+	OperationOther     = "other"
+)
+
+// GraphQL returns a gqlgen extension recording the latency of each operation.
+func (m *Metrics) GraphQL() graphql.HandlerExtension {
+	return operationTimer{m: m}
+}
+
+type operationTimer struct {
+	m *Metrics
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = operationTimer{}
+
+func (operationTimer) ExtensionName() string {
+	return "OperationMetrics"
+}
+
+func (operationTimer) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptResponse times the whole operation, from reading the request to the response.
+// Subscriptions are not measured, the interceptor runs once per event for them.
+func (t operationTimer) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	opCtx := graphql.GetOperationContext(ctx)
+	if opCtx.Operation != nil && opCtx.Operation.Operation == ast.Subscription {
+		return next(ctx)
+	}
+	resp := next(ctx)
+
+	// operationName of the request is optional when the document has a single operation
+	name, kind := opCtx.OperationName, "unknown"
+	if opCtx.Operation != nil {
+		name, kind = opCtx.Operation.Name, string(opCtx.Operation.Operation)
+	}
+	if name == "" {
+		name = OperationAnonymous
+	} else {
+		name = t.m.operationNames.get(name)
+	}
+	t.m.operations.WithLabelValues(name, kind).Observe(time.Since(opCtx.Stats.OperationStart).Seconds())
+	return resp
+}
+
+// nameLimiter lets through the first max distinct names and maps the rest to OperationOther.
+type nameLimiter struct {
+	max   int
+	mu    sync.RWMutex
+	names map[string]struct{}
+}
+
+func newNameLimiter(max int) *nameLimiter {
+	return &nameLimiter{max: max, names: map[string]struct{}{}}
+}
+
+func (l *nameLimiter) get(name string) string {
+	l.mu.RLock()
+	_, known := l.names[name]
+	l.mu.RUnlock()
+	if known {
+		return name
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, known := l.names[name]; known {
+		return name
+	}
+	if len(l.names) >= l.max {
+		return OperationOther
+	}
+	l.names[name] = struct{}{}
+	return name
+}
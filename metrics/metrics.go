@@ -0,0 +1,130 @@
+// Package metrics exports Prometheus metrics of the service at /metrics.
+//
+// The ledger and GraphQL packages don't import Prometheus: transfers are measured by a decorator
+// around ledger.Store, lock waits through ledger.Observer, GraphQL operations by a gqlgen extension.
+package metrics
+
+import (
+	"btp-transfer/ledger"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "btp"
+
+// Metrics holds the collectors and their registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	transfers        *prometheus.CounterVec
+	transferDuration *prometheus.HistogramVec
+	lockWait         prometheus.Histogram
+	txRetries        *prometheus.CounterVec
+	operations       *prometheus.HistogramVec
+	inFlight         prometheus.Gauge
+	discrepancies    prometheus.Gauge
+	lastVerified     prometheus.Gauge
+
+	operationNames *nameLimiter
+}
+
+// New registers all collectors, including the Go runtime and process ones, in a new registry.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		transfers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transfers_total",
+			Help:      "Transfers by outcome (success, error) and error code.",
+		}, []string{"outcome", "code"}),
+		transferDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "transfer_duration_seconds",
+			Help:      "Time to execute a transfer, including retries and batching.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"outcome"}),
+		lockWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "transfer_lock_wait_seconds",
+			Help:      "Time a transfer waited for its wallet row locks.",
+			Buckets:   []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, 1},
+		}),
+		txRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tx_retries_total",
+			Help:      "Retried transactions by SQLSTATE, and requests that exhausted the retries (reason=exhausted).",
+		}, []string{"reason"}),
+		operations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "graphql_operation_duration_seconds",
+			Help:      "GraphQL operation latency by operation name and type.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "type"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests being served.",
+		}),
+		discrepancies: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "ledger_discrepancies",
+			Help:      "Discrepancies found by the last periodic ledger verification.",
+		}),
+		lastVerified: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "ledger_last_verified_timestamp_seconds",
+			Help:      "Time of the last completed periodic ledger verification.",
+		}),
+		operationNames: newNameLimiter(maxOperationNames),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.transfers,
+		m.transferDuration,
+		m.lockWait,
+		m.operations,
+		m.inFlight,
+		m.discrepancies,
+		m.lastVerified,
+		m.txRetries,
+	)
+	return m
+}
+
+// WatchDB exports the connection pool statistics of db (sql.DB.Stats).
+func (m *Metrics) WatchDB(name string, db *sql.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// InFlight counts requests being served by next.
+func (m *Metrics) InFlight(next http.Handler) http.Handler {
+	return promhttp.InstrumentHandlerInFlight(m.inFlight, next)
+}
+
+// LockWait implements ledger.Observer.
+func (m *Metrics) LockWait(d time.Duration) {
+	m.lockWait.Observe(d.Seconds())
+}
+
+// TxRetried implements ledger.Observer.
+func (m *Metrics) TxRetried(reason string) {
+	m.txRetries.WithLabelValues(reason).Inc()
+}
+
+// LedgerVerified exports the outcome of a ledger verification.
+func (m *Metrics) LedgerVerified(report *ledger.Report) {
+	m.discrepancies.Set(float64(len(report.Discrepancies)))
+	m.lastVerified.Set(float64(report.CheckedAt.Unix()))
+}
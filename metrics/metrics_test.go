@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"btp-transfer/graph"
+	"btp-transfer/ledger"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const (
+	alice = "0x0000000000000000000000000000000000000001"
+	bob   = "0x0000000000000000000000000000000000000002"
+)
+
+// 1. Transfers by outcome and code
+// Goal: Successes and failures are counted with the ledger error code; unknown errors are internal.
+func TestStore_Transfer(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+	mem := ledger.NewMemoryStore()
+	if err := mem.CreateWallet(ctx, alice, 10); err != nil {
+		t.Fatalf("CreateWallet failed: %v", err)
+	}
+	store := m.Store(mem)
+
+	if _, err := store.Transfer(ctx, alice, bob, 5); err != nil {
+		t.Fatalf("Transfer failed: %v", err)
+	}
+	if _, err := store.Transfer(ctx, alice, bob, 50); err == nil {
+		t.Fatal("Expected insufficient balance")
+	}
+
+	if got := testutil.ToFloat64(m.transfers.WithLabelValues(OutcomeSuccess, "")); got != 1 {
+		t.Errorf("Expected 1 successful transfer, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.transfers.WithLabelValues(OutcomeError, ledger.CodeInsufficientBalance)); got != 1 {
+		t.Errorf("Expected 1 %s transfer, got %v", ledger.CodeInsufficientBalance, got)
+	}
+	if got := testutil.CollectAndCount(m.transferDuration); got != 2 {
+		t.Errorf("Expected latency of both outcomes, got %d series", got)
+	}
+
+	m.Store(failingStore{}).Transfer(ctx, alice, bob, 1)
+	if got := testutil.ToFloat64(m.transfers.WithLabelValues(OutcomeError, ledger.CodeInternal)); got != 1 {
+		t.Errorf("Expected 1 internal error, got %v", got)
+	}
+}
+
+type failingStore struct{ ledger.Store }
+
+func (failingStore) Transfer(context.Context, string, string, int64) (int64, error) {
+	return 0, fmt.Errorf("connection refused")
+}
+
+// 2. GraphQL operations
+// Goal: Latency is recorded per operation name, anonymous operations get their own label.
+func TestGraphQL_Operations(t *testing.T) {
+	m := New()
+	mem := ledger.NewMemoryStore()
+	if err := mem.CreateWallet(context.Background(), alice, 10); err != nil {
+		t.Fatalf("CreateWallet failed: %v", err)
+	}
+	h := graph.NewHandler(&graph.Resolver{Store: mem}, graph.Options{
+		Limits:     graph.Limits{MaxDepth: 10, MaxComplexity: 100, MaxBodyBytes: 1 << 20},
+		Extensions: []graphql.HandlerExtension{m.GraphQL()},
+	})
+	transfer := fmt.Sprintf(`transfer(from_address: \"%s\", to_address: \"%s\", amount: 1)`, alice, bob)
+	for _, body := range []string{
+		`{"query": "mutation Pay { ` + transfer + ` }"}`,
+		`{"query": "mutation { ` + transfer + ` }"}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if strings.Contains(rec.Body.String(), "errors") {
+			t.Fatalf("Unexpected errors: %s", rec.Body.String())
+		}
+	}
+
+	if got := testutil.CollectAndCount(m.operations); got != 2 {
+		t.Errorf("Expected 2 operation series, got %d", got)
+	}
+	body := scrape(t, m)
+	for _, name := range []string{"Pay", OperationAnonymous} {
+		if !strings.Contains(body, fmt.Sprintf(`operation=%q,type="mutation"`, name)) {
+			t.Errorf("Expected series for operation %s", name)
+		}
+	}
+}
+
+// 3. Operation name cardinality
+// Goal: Client-chosen names beyond the cap are folded into "other".
+func TestNameLimiter(t *testing.T) {
+	l := newNameLimiter(2)
+	l.get("a")
+	l.get("b")
+	if got := l.get("c"); got != OperationOther {
+		t.Errorf("Expected %s over the cap, got %s", OperationOther, got)
+	}
+	if got := l.get("a"); got != "a" {
+		t.Errorf("Expected known name kept, got %s", got)
+	}
+}
+
+// 4. Exposition
+// Goal: /metrics serves lock waits, retries, in-flight requests and Go runtime metrics.
+func TestHandler(t *testing.T) {
+	m := New()
+	m.LockWait(3 * time.Millisecond)
+	m.TxRetried(ledger.RetryExhausted)
+	m.InFlight(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	body := scrape(t, m)
+	for _, want := range []string{
+		"btp_transfer_lock_wait_seconds_count 1",
+		`btp_tx_retries_total{reason="exhausted"} 1`,
+		"btp_http_requests_in_flight 0",
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in /metrics", want)
+		}
+	}
+}
+
+// 5. Signed transfers
+// Goal: Transfers of the JSON-RPC facade are counted like the others; a store without them is internal.
+func TestStore_TransferSigned(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+	transfer := ledger.SignedTransfer{From: alice, To: bob, Amount: 1}
+
+	if _, err := m.Store(signingStore{}).TransferSigned(ctx, transfer); err != nil {
+		t.Fatalf("TransferSigned failed: %v", err)
+	}
+	if got := testutil.ToFloat64(m.transfers.WithLabelValues(OutcomeSuccess, "")); got != 1 {
+		t.Errorf("Expected 1 successful transfer, got %v", got)
+	}
+
+	if _, err := m.Store(ledger.NewMemoryStore()).TransferSigned(ctx, transfer); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+	if got := testutil.ToFloat64(m.transfers.WithLabelValues(OutcomeError, ledger.CodeInternal)); got != 1 {
+		t.Errorf("Expected 1 internal error, got %v", got)
+	}
+}
+
+type signingStore struct{ ledger.Store }
+
+func (signingStore) TransferSigned(context.Context, ledger.SignedTransfer) (int64, error) {
+	return 9, nil
+}
+
+func scrape(t *testing.T, m *Metrics) string {
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from /metrics, got %d: %s", rec.Code, body)
+	}
+	return string(body)
+}
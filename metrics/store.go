@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"btp-transfer/ledger"
+)
+
+// Outcome label values
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+// Store counts and times the transfers of the wrapped ledger.Store, signed ones included.
+type Store struct {
+	ledger.Store
+	m *Metrics
+}
+
+// Store wraps s so its transfers are measured.
+func (m *Metrics) Store(s ledger.Store) *Store {
+	return &Store{Store: s, m: m}
+}
+
+// Transfer calls the wrapped store and records its outcome and duration.
+func (s *Store) Transfer(ctx context.Context, fromAddress, toAddress string, amount int64) (int64, error) {
+	start := time.Now()
+	balance, err := s.Store.Transfer(ctx, fromAddress, toAddress, amount)
+	s.observe(start, err)
+	return balance, err
+}
+
+// TransferSigned calls the wrapped store and records it like Transfer.
+func (s *Store) TransferSigned(ctx context.Context, t ledger.SignedTransfer) (int64, error) {
+	start := time.Now()
+	balance, err := ledger.TransferSigned(ctx, s.Store, t)
+	s.observe(start, err)
+	return balance, err
+}
+
+func (s *Store) observe(start time.Time, err error) {
+	outcome, code := OutcomeSuccess, ""
+	if err != nil {
+		outcome = OutcomeError
+		// Errors without a ledger code are labeled like the GraphQL error presenter reports them
+		code = ledger.CodeInternal
+		if c, _, ok := ledger.Code(err); ok {
+			code = c
+		}
+	}
+	s.m.transfers.WithLabelValues(outcome, code).Inc()
+	s.m.transferDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+}
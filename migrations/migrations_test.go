@@ -0,0 +1,46 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+// 1. Embedded scripts
+// Goal: Every migration has both scripts, versions are ordered and checksums are set.
+func TestLoad_Embedded(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load embedded migrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("No migrations embedded")
+	}
+	for i, m := range migrations {
+		if i > 0 && m.Version <= migrations[i-1].Version {
+			t.Errorf("Migrations out of order: %d after %d", m.Version, migrations[i-1].Version)
+		}
+		if m.Checksum == "" {
+			t.Errorf("Migration %d has no checksum", m.Version)
+		}
+	}
+}
+
+// 2. Broken sets of scripts
+// Goal: A missing down script or a stray file is refused instead of half-applied.
+func TestLoad_Invalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"missing down": {
+			"sql/0001_a.up.sql": {Data: []byte("SELECT 1")},
+		},
+		"unexpected file": {
+			"sql/0001_a.up.sql":   {Data: []byte("SELECT 1")},
+			"sql/0001_a.down.sql": {Data: []byte("SELECT 1")},
+			"sql/notes.txt":       {Data: []byte("hello")},
+		},
+	}
+	for name, fsys := range tests {
+		if _, err := load(fsys, "sql"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package migrations_test
+
+import (
+	"btp-transfer/migrations"
+	"btp-transfer/testdb"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+// emptyDB returns an empty database of its own, so reverting migrations here doesn't pull the tables
+// from under the tests of other packages.
+func emptyDB(t *testing.T) *sql.DB {
+	db := testdb.Open(t, "btp_migrations_test")
+	_, err := db.Exec("DROP TABLE IF EXISTS ledger_entries, audit_events, genesis, persisted_queries, wallet_shards, wallets, schema_migrations")
+	if err != nil {
+		t.Fatalf("Failed to clean test DB: %v", err)
+	}
+	return db
+}
+
+// 3. Up, status and down
+// Goal: Migrations are applied once, reported as applied and can be reverted.
+func TestMigrator_UpDownStatus(t *testing.T) {
+	db := emptyDB(t)
+	ctx := context.Background()
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+	embedded, err := migrations.Load()
+	if err != nil {
+		t.Fatalf("Failed to load embedded migrations: %v", err)
+	}
+	all := len(embedded)
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if len(applied) != all {
+		t.Errorf("Expected %d applied migrations, got %d", all, len(applied))
+	}
+
+	if current, err := migrator.Current(ctx); err != nil || current != migrator.Latest() {
+		t.Errorf("Expected current version %d, got %d, err: %v", migrator.Latest(), current, err)
+	}
+
+	// Second run has nothing to do
+	if applied, err := migrator.Up(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("Expected no-op, got %d applied, err: %v", len(applied), err)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	for _, s := range statuses {
+		if !s.Applied {
+			t.Errorf("Migration %d should be applied", s.Version)
+		}
+	}
+
+	reverted, err := migrator.Down(ctx, all)
+	if err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	if len(reverted) != all || reverted[0].Version != migrator.Latest() {
+		t.Errorf("Expected all migrations reverted newest first, got %v", reverted)
+	}
+	var exists bool
+	if err := db.QueryRow("SELECT to_regclass('wallets') IS NOT NULL").Scan(&exists); err != nil || exists {
+		t.Errorf("Expected wallets table to be dropped, exists: %v, err: %v", exists, err)
+	}
+}
+
+// 4. Changed migration
+// Goal: A migration edited after it ran is detected and nothing else is applied.
+func TestMigrator_ChecksumMismatch(t *testing.T) {
+	db := emptyDB(t)
+	ctx := context.Background()
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if _, err := db.Exec("UPDATE schema_migrations SET checksum = 'edited' WHERE version = 1"); err != nil {
+		t.Fatalf("Failed to tamper with checksum: %v", err)
+	}
+
+	if _, err := migrator.Up(ctx); !errors.Is(err, migrations.ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch, got: %v", err)
+	}
+}
@@ -0,0 +1,6 @@
+-- Init wallets table
+-- IF NOT EXISTS: databases created before migrations existed already have it
+CREATE TABLE IF NOT EXISTS wallets (
+    address VARCHAR(255) PRIMARY KEY,
+    balance BIGINT NOT NULL CHECK (balance >= 0)
+);
@@ -0,0 +1,7 @@
+-- Merge slots back into wallets before dropping them, so no balance is lost
+UPDATE wallets w
+SET balance = w.balance + s.total
+FROM (SELECT address, SUM(balance) AS total FROM wallet_shards GROUP BY address) s
+WHERE w.address = s.address;
+
+DROP TABLE IF EXISTS wallet_shards;
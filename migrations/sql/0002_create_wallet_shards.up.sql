@@ -0,0 +1,8 @@
+-- Balance slots of sharded ("hot") wallets.
+-- The balance of a wallet is wallets.balance plus the sum of its slots.
+CREATE TABLE IF NOT EXISTS wallet_shards (
+    address VARCHAR(255) NOT NULL REFERENCES wallets (address),
+    slot    INT NOT NULL CHECK (slot >= 0),
+    balance BIGINT NOT NULL CHECK (balance >= 0),
+    PRIMARY KEY (address, slot)
+);
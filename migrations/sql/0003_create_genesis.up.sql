@@ -0,0 +1,12 @@
+-- The genesis the ledger was started from (at most one row)
+-- hash identifies the genesis file, so starting with a different one is detected
+CREATE TABLE genesis (
+    id           BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    hash         TEXT NOT NULL,
+    token_name   TEXT NOT NULL,
+    token_symbol TEXT NOT NULL,
+    decimals     INT NOT NULL CHECK (decimals >= 0),
+    max_supply   BIGINT NOT NULL CHECK (max_supply >= 0),
+    supply       BIGINT NOT NULL CHECK (supply >= 0),
+    applied_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,39 @@
+-- Append-only log of every state-changing operation: who (principal, ip, user_agent),
+-- what (operation, arguments), when and with which outcome.
+-- Rows are written in the transaction of the operation they describe.
+CREATE TABLE audit_events (
+    id          BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp(),
+    principal   TEXT NOT NULL,
+    ip          TEXT NOT NULL DEFAULT '',
+    user_agent  TEXT NOT NULL DEFAULT '',
+    request_id  TEXT NOT NULL DEFAULT '',
+    operation   TEXT NOT NULL,
+    arguments   JSONB NOT NULL DEFAULT '{}',
+    outcome     TEXT NOT NULL CHECK (outcome IN ('success', 'error')),
+    error_code  TEXT NOT NULL DEFAULT '',
+    result      JSONB
+);
+
+CREATE INDEX audit_events_occurred_at ON audit_events (occurred_at);
+CREATE INDEX audit_events_principal ON audit_events (principal, id);
+CREATE INDEX audit_events_operation ON audit_events (operation, id);
+CREATE INDEX audit_events_arguments ON audit_events USING GIN (arguments jsonb_path_ops);
+
+-- Nobody, including the table owner, may change or remove what has been recorded.
+-- (Dropping the table is still possible for its owner; grant the application only INSERT and SELECT.)
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only, % is not allowed', TG_OP
+        USING ERRCODE = 'insufficient_privilege';
+END;
+$$;
+
+CREATE TRIGGER audit_events_no_update_delete
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
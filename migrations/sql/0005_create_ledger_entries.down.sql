@@ -0,0 +1,2 @@
+-- Removes the history of movements, balances are kept
+DROP TABLE IF EXISTS ledger_entries;
@@ -0,0 +1,27 @@
+-- Every movement of tokens, so balances can be rebuilt from history and checked (see ledger.Verify):
+--   opening:  balance a wallet held before entries were recorded (from_address is NULL);
+--   mint:     tokens created, by the genesis or an explicit wallet creation (from_address is NULL);
+--   burn:     tokens destroyed (to_address is NULL);
+--   transfer: tokens moved between two wallets.
+-- There is no foreign key to wallets: entries are history, a missing wallet is reported as an orphan.
+CREATE TABLE ledger_entries (
+    id           BIGSERIAL PRIMARY KEY,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp(),
+    kind         TEXT NOT NULL CHECK (kind IN ('opening', 'mint', 'burn', 'transfer')),
+    from_address VARCHAR(255),
+    to_address   VARCHAR(255),
+    amount       BIGINT NOT NULL CHECK (amount > 0),
+    CHECK ((from_address IS NULL) = (kind IN ('opening', 'mint'))),
+    CHECK ((to_address IS NULL) = (kind = 'burn'))
+);
+
+-- Backfill: what wallets hold now (slots of hot wallets included) becomes their opening balance
+INSERT INTO ledger_entries (kind, to_address, amount)
+SELECT 'opening', address, balance
+FROM (
+    SELECT w.address,
+           w.balance + COALESCE((SELECT SUM(s.balance) FROM wallet_shards s WHERE s.address = w.address), 0) AS balance
+    FROM wallets w
+) AS current
+WHERE balance > 0
+ORDER BY address;
@@ -0,0 +1,3 @@
+-- Time-range exports. Entries are appended in time order, so a BRIN index is enough
+-- and costs next to nothing on the write path.
+CREATE INDEX ledger_entries_created_at ON ledger_entries USING BRIN (created_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS ledger_entries_to_address_id;
+DROP INDEX IF EXISTS ledger_entries_from_address_id;
@@ -0,0 +1,3 @@
+-- Transfer history of a wallet, newest first: one index per side, each read backwards by id.
+CREATE INDEX ledger_entries_from_address_id ON ledger_entries (from_address, id) WHERE from_address IS NOT NULL;
+CREATE INDEX ledger_entries_to_address_id ON ledger_entries (to_address, id) WHERE to_address IS NOT NULL;
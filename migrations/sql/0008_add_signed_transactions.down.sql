@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS ledger_entries_tx_hash;
+ALTER TABLE ledger_entries DROP COLUMN IF EXISTS tx_hash;
+ALTER TABLE wallets DROP COLUMN IF EXISTS nonce;
@@ -0,0 +1,5 @@
+-- Transfers submitted as signed Ethereum transactions (see the eth package):
+-- the nonce of the next transaction a wallet may sign, and the hash of the transaction of an entry.
+ALTER TABLE wallets ADD COLUMN nonce BIGINT NOT NULL DEFAULT 0 CHECK (nonce >= 0);
+ALTER TABLE ledger_entries ADD COLUMN tx_hash CHAR(66);
+CREATE UNIQUE INDEX ledger_entries_tx_hash ON ledger_entries (tx_hash) WHERE tx_hash IS NOT NULL;
@@ -0,0 +1,9 @@
+-- Cache of automatic persisted queries (APQ) shared by all instances: GraphQL operations by the
+-- SHA-256 of their text. Clients register operations again when they are missing, so rows can be
+-- deleted at any time; created_at lets old ones expire.
+CREATE TABLE persisted_queries (
+    hash CHAR(64) PRIMARY KEY,
+    query TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX persisted_queries_created_at ON persisted_queries (created_at);
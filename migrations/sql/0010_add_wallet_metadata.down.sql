@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS wallets_tags;
+DROP INDEX IF EXISTS wallets_label_trgm;
+DROP INDEX IF EXISTS wallets_address_pattern;
+ALTER TABLE wallets DROP COLUMN IF EXISTS attributes, DROP COLUMN IF EXISTS tags, DROP COLUMN IF EXISTS label;
+-- pg_trgm is left installed, other database objects may use it
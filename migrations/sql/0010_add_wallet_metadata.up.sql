@@ -0,0 +1,13 @@
+-- Metadata owners set on their wallets: a display label, tags and free-form JSON attributes.
+-- None of it plays a part in transfers.
+-- pg_trgm is a trusted extension (PostgreSQL 13+): the owner of the database can create it.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+ALTER TABLE wallets
+    ADD COLUMN label TEXT NOT NULL DEFAULT '',
+    ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN attributes JSONB NOT NULL DEFAULT '{}' CHECK (jsonb_typeof(attributes) = 'object');
+-- Search (searchWallets): address prefixes (LIKE 'abc%' whatever the collation), labels by trigram
+-- (ILIKE and word similarity) and tags by containment.
+CREATE INDEX wallets_address_pattern ON wallets (address varchar_pattern_ops);
+CREATE INDEX wallets_label_trgm ON wallets USING gin (label gin_trgm_ops);
+CREATE INDEX wallets_tags ON wallets USING gin (tags);
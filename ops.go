@@ -0,0 +1,77 @@
+package main
+
+import (
+	"btp-transfer/config"
+	"btp-transfer/ledger"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+)
+
+// runBalance prints the balance of the wallet given as the only argument.
+func runBalance(args []string) error {
+	fs := flag.NewFlagSet("balance", flag.ContinueOnError)
+	cfg, err := parseConfig(fs, args, nil)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: balance [flags] <address>")
+	}
+
+	store, closeStore, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	balance, err := store.Balance(context.Background(), strings.ToLower(fs.Arg(0)))
+	if err != nil {
+		return err
+	}
+	fmt.Println(balance)
+	return nil
+}
+
+// runTransfer moves tokens between wallets without going through the API, e.g. for operators fixing a mistake.
+func runTransfer(args []string) error {
+	var from, to string
+	var amount int64
+
+	fs := flag.NewFlagSet("transfer", flag.ContinueOnError)
+	fs.StringVar(&from, "from", "", "sender address")
+	fs.StringVar(&to, "to", "", "receiver address")
+	fs.Int64Var(&amount, "amount", 0, "number of tokens")
+	cfg, err := parseConfig(fs, args, func(fs *flag.FlagSet, cfg *config.Config) {
+		fs.StringVar(&cfg.TxMode, "tx-mode", cfg.TxMode, "transaction mode: locking, serializable or single (env TX_MODE)")
+	})
+	if err != nil {
+		return err
+	}
+
+	store, closeStore, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	// Same normalization as the GraphQL resolver
+	newBalance, err := store.Transfer(cliContext(), strings.ToLower(from), strings.ToLower(to), amount)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("transferred %d from %s to %s, sender balance: %d\n", amount, from, to, newBalance)
+	return nil
+}
+
+// cliContext attributes the operations of a command to the OS user in the audit log.
+func cliContext() context.Context {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return ledger.WithActor(context.Background(), ledger.Actor{Principal: "cli:" + name})
+}
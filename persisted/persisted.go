@@ -0,0 +1,136 @@
+// Package persisted stores the GraphQL operations of persisted queries: clients send the SHA-256 hash
+// of an operation instead of its text, once the server knows it (automatic persisted queries, APQ) or
+// because it was registered ahead of time (manifest).
+package persisted
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+)
+
+// Hash returns the hash identifying a query: the hex SHA-256 of its text, as APQ clients compute it.
+func Hash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadManifest reads the operations registered ahead of time and returns them by hash. The file is either
+// a JSON object mapping hashes to queries (Relay, graphql-codegen) or an Apollo persisted query manifest
+// ({"operations": [{"id": ..., "body": ...}]}). Every hash must match its query.
+func LoadManifest(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read persisted queries: %w", err)
+	}
+
+	var apollo struct {
+		Operations []struct {
+			ID   string `json:"id"`
+			Body string `json:"body"`
+		} `json:"operations"`
+	}
+	operations := map[string]string{}
+	if err := json.Unmarshal(content, &apollo); err == nil && apollo.Operations != nil {
+		for _, op := range apollo.Operations {
+			operations[op.ID] = op.Body
+		}
+	} else if err := json.Unmarshal(content, &operations); err != nil {
+		return nil, fmt.Errorf("failed to parse persisted queries %s: expected an object of hashes to queries or an Apollo manifest", path)
+	}
+
+	for hash, query := range operations {
+		if query == "" {
+			return nil, fmt.Errorf("persisted query %s of %s is empty", hash, path)
+		}
+		if Hash(query) != hash {
+			return nil, fmt.Errorf("persisted query %s of %s doesn't match the SHA-256 of its text", hash, path)
+		}
+	}
+	return operations, nil
+}
+
+// PostgresCache is an APQ cache shared by the instances using the same database. Each instance keeps the
+// operations it used recently in memory in front of it. Database errors are logged and count as misses:
+// the client then sends the whole operation again, so APQ degrades instead of failing the request.
+type PostgresCache struct {
+	db    *sql.DB
+	local *lru.LRU[string]
+}
+
+// NewPostgresCache returns a cache keeping up to size operations in memory.
+func NewPostgresCache(db *sql.DB, size int) *PostgresCache {
+	return &PostgresCache{db: db, local: lru.New[string](size)}
+}
+
+// Get returns the operation with the given hash.
+func (c *PostgresCache) Get(ctx context.Context, hash string) (string, bool) {
+	if query, ok := c.local.Get(ctx, hash); ok {
+		return query, true
+	}
+	var query string
+	err := c.db.QueryRowContext(ctx, "SELECT query FROM persisted_queries WHERE hash = $1", hash).Scan(&query)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.ErrorContext(ctx, "persisted query lookup failed", "hash", hash, "error", err)
+		}
+		return "", false
+	}
+	c.local.Add(ctx, hash, query)
+	return query, true
+}
+
+// Add stores an operation; the APQ extension has checked its hash.
+func (c *PostgresCache) Add(ctx context.Context, hash string, query string) {
+	c.local.Add(ctx, hash, query)
+	_, err := c.db.ExecContext(ctx, `
+		INSERT INTO persisted_queries (hash, query) VALUES ($1, $2)
+		ON CONFLICT (hash) DO NOTHING
+	`, hash, query)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to store persisted query", "hash", hash, "error", err)
+	}
+}
+
+// Prune deletes the operations stored before olderThan and returns how many there were. Clients register
+// the ones still in use again, so operations registered once by anyone don't pile up forever.
+func (c *PostgresCache) Prune(ctx context.Context, olderThan time.Time) (int64, error) {
+	res, err := c.db.ExecContext(ctx, "DELETE FROM persisted_queries WHERE created_at < $1", olderThan)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune persisted queries: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// PrunePeriodically deletes the operations older than ttl every hour (or every ttl when shorter) until ctx is done.
+func (c *PostgresCache) PrunePeriodically(ctx context.Context, ttl time.Duration) {
+	ticker := time.NewTicker(min(ttl, time.Hour))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n, err := c.Prune(ctx, time.Now().Add(-ttl))
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("persisted queries pruning failed", "error", err)
+			}
+			continue
+		}
+		if n > 0 {
+			slog.Info("persisted queries pruned", "deleted", n)
+		}
+	}
+}
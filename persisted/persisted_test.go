@@ -0,0 +1,73 @@
+package persisted
+
+import (
+	"btp-transfer/testdb"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const query = `{ wallet(address: "0xabc") { balance } }`
+
+// writeManifest stores a manifest in a temporary directory
+func writeManifest(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "queries.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	return path
+}
+
+// 1. Manifests
+// Goal: Both manifest formats are read; a hash that doesn't match its query is refused.
+func TestLoadManifest(t *testing.T) {
+	hash := Hash(query)
+	for name, content := range map[string]string{
+		"object": fmt.Sprintf(`{%q: %q}`, hash, query),
+		"apollo": fmt.Sprintf(`{"format": "apollo-persisted-query-manifest", "version": 1, "operations": [{"id": %q, "name": "W", "type": "query", "body": %q}]}`, hash, query),
+	} {
+		t.Run(name, func(t *testing.T) {
+			operations, err := LoadManifest(writeManifest(t, content))
+			if err != nil {
+				t.Fatalf("LoadManifest failed: %v", err)
+			}
+			if len(operations) != 1 || operations[hash] != query {
+				t.Errorf("Expected the operation by hash, got %v", operations)
+			}
+		})
+	}
+
+	if _, err := LoadManifest(writeManifest(t, fmt.Sprintf(`{%q: "{ other }"}`, hash))); err == nil {
+		t.Error("Expected error for a hash not matching its query")
+	}
+	if _, err := LoadManifest(writeManifest(t, `["not", "a", "manifest"]`)); err == nil {
+		t.Error("Expected error for a malformed manifest")
+	}
+}
+
+// 2. Shared cache
+// Goal: An operation added by one instance is found by another; pruning removes old operations.
+func TestPostgresCache(t *testing.T) {
+	db := testdb.Migrated(t, "btp_persisted_test", "persisted_queries")
+	ctx := context.Background()
+	hash := Hash(query)
+
+	NewPostgresCache(db, 10).Add(ctx, hash, query)
+	other := NewPostgresCache(db, 10)
+	if got, ok := other.Get(ctx, hash); !ok || got != query {
+		t.Fatalf("Expected the other instance to find the query, got %q, %v", got, ok)
+	}
+	if _, ok := other.Get(ctx, Hash("{ unknown }")); ok {
+		t.Error("Expected a miss for an unknown hash")
+	}
+
+	if n, err := other.Prune(ctx, time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Errorf("Expected 1 pruned operation, got %d: %v", n, err)
+	}
+	if _, ok := NewPostgresCache(db, 10).Get(ctx, hash); ok {
+		t.Error("Expected the pruned operation to be gone")
+	}
+}
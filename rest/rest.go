@@ -0,0 +1,162 @@
+// Package rest serves a versioned REST/JSON API under /v1, for clients that don't speak GraphQL.
+// It goes through the same service as the GraphQL resolvers and returns the same error codes.
+package rest
+
+import (
+	"btp-transfer/ledger"
+	"btp-transfer/service"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// OpenAPI is the OpenAPI 3 document of the API, served under /v1/openapi.yaml.
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
+
+// defaultPage is the number of transfers returned without the first parameter
+const defaultPage = 20
+
+// statuses maps error codes to HTTP statuses; codes not listed are 400 Bad Request
+var statuses = map[string]int{
+	ledger.CodeWalletNotFound:              http.StatusNotFound,
+	ledger.CodeInsufficientBalance:         http.StatusUnprocessableEntity,
+	ledger.CodeWalletExists:                http.StatusConflict,
+	ledger.CodeTransactionConflict:         http.StatusConflict,
+	service.CodeTransferHistoryUnavailable: http.StatusNotImplemented,
+}
+
+// TransferRequest is the body of POST /v1/transfers, named like the arguments of the transfer mutation.
+type TransferRequest struct {
+	FromAddress string `json:"from_address"`
+	ToAddress   string `json:"to_address"`
+	Amount      int64  `json:"amount"`
+}
+
+// TransferResponse is the outcome of a transfer.
+type TransferResponse struct {
+	FromAddress string `json:"from_address"`
+	ToAddress   string `json:"to_address"`
+	Amount      int64  `json:"amount"`
+	// Balance is the sender's balance after the transfer
+	Balance int64 `json:"balance"`
+}
+
+// ErrorResponse is the body of every error response.
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody carries the same code and details as extensions of GraphQL errors.
+type ErrorBody struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// Handler serves the API. Request bodies are capped at maxBodyBytes, like GraphQL requests.
+func Handler(svc *service.Service, maxBodyBytes int64) http.Handler {
+	h := &handler{svc: svc, maxBodyBytes: maxBodyBytes}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/transfers", h.transfer)
+	mux.HandleFunc("GET /v1/wallets/{address}", h.wallet)
+	mux.HandleFunc("GET /v1/wallets/{address}/transfers", h.transfers)
+	mux.HandleFunc("GET /v1/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(OpenAPI)
+	})
+	return mux
+}
+
+type handler struct {
+	svc          *service.Service
+	maxBodyBytes int64
+}
+
+func (h *handler) transfer(w http.ResponseWriter, r *http.Request) {
+	var req TransferRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeJSON(w, status, ErrorResponse{ErrorBody{Code: service.CodeBadUserInput, Message: fmt.Sprintf("invalid request body: %v", err)}})
+		return
+	}
+
+	balance, err := h.svc.Transfer(r.Context(), req.FromAddress, req.ToAddress, req.Amount)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, TransferResponse{
+		FromAddress: service.Normalize(req.FromAddress),
+		ToAddress:   service.Normalize(req.ToAddress),
+		Amount:      req.Amount,
+		Balance:     balance,
+	})
+}
+
+func (h *handler) wallet(w http.ResponseWriter, r *http.Request) {
+	wallet, err := h.svc.Wallet(r.Context(), r.PathValue("address"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, wallet)
+}
+
+func (h *handler) transfers(w http.ResponseWriter, r *http.Request) {
+	first := defaultPage
+	if v := r.URL.Query().Get("first"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, r, &service.Error{Code: service.CodeBadUserInput, Message: fmt.Sprintf("first must be an integer, got %q", v)})
+			return
+		}
+		first = n
+	}
+
+	// Unlike the GraphQL field, the wallet is the resource here: an unknown one is a 404
+	wallet, err := h.svc.Wallet(r.Context(), r.PathValue("address"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	page, err := h.svc.Transfers(r.Context(), wallet.Address, first, r.URL.Query().Get("after"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError answers with the code of err and the HTTP status of statuses. Errors without a code are
+// logged and answered 500 INTERNAL.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	code, details, ok := service.Code(err)
+	if !ok {
+		slog.ErrorContext(r.Context(), "rest: internal error", "method", r.Method, "path", r.URL.Path, "error", err)
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{ErrorBody{Code: ledger.CodeInternal, Message: "internal server error"}})
+		return
+	}
+	status, ok := statuses[code]
+	if !ok {
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, ErrorResponse{ErrorBody{Code: code, Message: err.Error(), Details: details}})
+}
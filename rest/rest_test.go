@@ -0,0 +1,176 @@
+package rest
+
+import (
+	"btp-transfer/ledger"
+	"btp-transfer/service"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+)
+
+// fakeHistory serves entries with IDs count..1 received by every wallet, newest first
+type fakeHistory struct {
+	count int64
+}
+
+func (f *fakeHistory) WalletEntries(_ context.Context, address string, before int64, limit int) ([]ledger.Entry, error) {
+	var entries []ledger.Entry
+	for id := f.count; id > 0 && len(entries) < limit; id-- {
+		if before == 0 || id < before {
+			entries = append(entries, ledger.Entry{ID: id, Kind: ledger.EntryTransfer, From: "0xpeer", To: address, Amount: id})
+		}
+	}
+	return entries, nil
+}
+
+func (f *fakeHistory) WalletsEntries(ctx context.Context, addresses []string, before int64, limit int) (map[string][]ledger.Entry, error) {
+	entries := map[string][]ledger.Entry{}
+	for _, address := range addresses {
+		if page, _ := f.WalletEntries(ctx, address, before, limit); len(page) > 0 {
+			entries[address] = page
+		}
+	}
+	return entries, nil
+}
+
+func (f *fakeHistory) Entries(_ context.Context, ids []int64) (map[int64]ledger.Entry, error) {
+	entries := map[int64]ledger.Entry{}
+	for _, id := range ids {
+		if id > 0 && id <= f.count {
+			entries[id] = ledger.Entry{ID: id, Kind: ledger.EntryTransfer, From: "0xpeer", To: "0xabc", Amount: id}
+		}
+	}
+	return entries, nil
+}
+
+// newHandler serves the API over a memory store holding 0xabc with 100 tokens
+func newHandler(t *testing.T, history ledger.History) http.Handler {
+	store := ledger.NewMemoryStore()
+	if err := store.CreateWallet(context.Background(), "0xabc", 100); err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	return Handler(&service.Service{Store: store, History: history}, 1<<10)
+}
+
+// do sends a request and decodes the JSON response into v
+func do(t *testing.T, h http.Handler, method, target, body string, v any) int {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("Failed to decode %s %s response %q: %v", method, target, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+// 1. Transfer and wallet
+// Goal: A transfer is applied with case-insensitive addresses and shows in the wallet balances.
+func TestTransfer(t *testing.T) {
+	h := newHandler(t, nil)
+
+	var res TransferResponse
+	status := do(t, h, http.MethodPost, "/v1/transfers", `{"from_address":"0xABC","to_address":"0xDEF","amount":30}`, &res)
+	if status != http.StatusOK || res.Balance != 70 || res.FromAddress != "0xabc" || res.ToAddress != "0xdef" {
+		t.Fatalf("Expected 200 with balance 70, got %d: %+v", status, res)
+	}
+
+	var wallet service.Wallet
+	if status := do(t, h, http.MethodGet, "/v1/wallets/0xDEF", "", &wallet); status != http.StatusOK || wallet.Balance != 30 {
+		t.Errorf("Expected 0xdef to hold 30, got %d: %+v", status, wallet)
+	}
+}
+
+// 2. Error codes
+// Goal: Errors carry the same codes as in GraphQL, with a matching HTTP status.
+func TestErrors(t *testing.T) {
+	h := newHandler(t, nil)
+	tests := []struct {
+		name, method, target, body string
+		status                     int
+		code                       string
+	}{
+		{"insufficient balance", http.MethodPost, "/v1/transfers", `{"from_address":"0xabc","to_address":"0xdef","amount":500}`, http.StatusUnprocessableEntity, ledger.CodeInsufficientBalance},
+		{"invalid amount", http.MethodPost, "/v1/transfers", `{"from_address":"0xabc","to_address":"0xdef","amount":-1}`, http.StatusBadRequest, ledger.CodeInvalidAmount},
+		{"missing address", http.MethodPost, "/v1/transfers", `{"from_address":"0xabc","amount":1}`, http.StatusBadRequest, ledger.CodeInvalidAddress},
+		{"unknown field", http.MethodPost, "/v1/transfers", `{"from":"0xabc","to_address":"0xdef","amount":1}`, http.StatusBadRequest, service.CodeBadUserInput},
+		{"body too large", http.MethodPost, "/v1/transfers", `{"from_address":"` + strings.Repeat("a", 2<<10) + `"}`, http.StatusRequestEntityTooLarge, service.CodeBadUserInput},
+		{"unknown wallet", http.MethodGet, "/v1/wallets/0xghost", "", http.StatusNotFound, ledger.CodeWalletNotFound},
+		{"no history", http.MethodGet, "/v1/wallets/0xabc/transfers", "", http.StatusNotImplemented, service.CodeTransferHistoryUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var res ErrorResponse
+			if status := do(t, h, tt.method, tt.target, tt.body, &res); status != tt.status || res.Error.Code != tt.code {
+				t.Errorf("Expected %d %s, got %d: %+v", tt.status, tt.code, status, res.Error)
+			}
+		})
+	}
+}
+
+// 3. Transfer history
+// Goal: Following end_cursor walks through all entries once; bad paging arguments are rejected.
+func TestTransfers_Paging(t *testing.T) {
+	h := newHandler(t, &fakeHistory{count: 5})
+
+	var ids []int64
+	target := "/v1/wallets/0xABC/transfers?first=2"
+	for range 5 {
+		var page service.TransferPage
+		if status := do(t, h, http.MethodGet, target, "", &page); status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", status)
+		}
+		for _, e := range page.Transfers {
+			ids = append(ids, e.ID)
+			if e.To != "0xabc" {
+				t.Errorf("Expected entries of 0xabc, got %+v", e)
+			}
+		}
+		if !page.HasNextPage {
+			break
+		}
+		target = "/v1/wallets/0xabc/transfers?first=2&after=" + page.EndCursor
+	}
+	if len(ids) != 5 || ids[0] != 5 || ids[4] != 1 {
+		t.Errorf("Expected entries 5..1, got %v", ids)
+	}
+
+	for _, query := range []string{"first=0", "first=101", "first=x", "after=bogus"} {
+		var res ErrorResponse
+		if status := do(t, h, http.MethodGet, "/v1/wallets/0xabc/transfers?"+query, "", &res); status != http.StatusBadRequest || res.Error.Code != service.CodeBadUserInput {
+			t.Errorf("%s: expected 400 %s, got %d: %+v", query, service.CodeBadUserInput, status, res.Error)
+		}
+	}
+}
+
+// 4. OpenAPI document
+// Goal: The served document is valid YAML and describes every route.
+func TestOpenAPI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newHandler(t, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/openapi.yaml", nil))
+
+	var doc struct {
+		OpenAPI string                    `yaml:"openapi"`
+		Paths   map[string]map[string]any `yaml:"paths"`
+	}
+	if err := yaml.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Failed to parse the document: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("Expected OpenAPI 3, got %q", doc.OpenAPI)
+	}
+	for path, method := range map[string]string{
+		"/v1/transfers":                   "post",
+		"/v1/wallets/{address}":           "get",
+		"/v1/wallets/{address}/transfers": "get",
+	} {
+		if _, ok := doc.Paths[path][method]; !ok {
+			t.Errorf("Expected %s %s in the document", method, path)
+		}
+	}
+}
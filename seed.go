@@ -0,0 +1,63 @@
+package main
+
+import (
+	"btp-transfer/config"
+	"btp-transfer/genesis"
+	"btp-transfer/ledger"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+)
+
+// loadGenesis reads the configured genesis file, or returns the built-in genesis when there is none.
+func loadGenesis(cfg *config.Config) (*genesis.Genesis, error) {
+	if cfg.GenesisFile == "" {
+		return genesis.Default(), nil
+	}
+	return genesis.Load(cfg.GenesisFile)
+}
+
+// applyGenesis writes the genesis into the store. In PostgreSQL it is recorded, so it is applied
+// only once and a different genesis is refused later.
+func applyGenesis(ctx context.Context, store ledger.Store, g *genesis.Genesis) error {
+	pg, ok := store.(*ledger.PostgresStore)
+	if !ok {
+		return genesis.Seed(ctx, store, g)
+	}
+
+	applied, err := genesis.Apply(ctx, pg.DB, g)
+	if err != nil {
+		return err
+	}
+	if applied {
+		slog.Info("genesis applied", "hash", g.Hash(), "wallets", len(g.Allocations)+len(g.Wallets), "supply", g.Supply(), "symbol", g.Token.Symbol)
+	}
+	return nil
+}
+
+// runSeed applies a genesis file. Seeding twice with the same file is harmless.
+func runSeed(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	cfg, err := parseConfig(fs, args, func(fs *flag.FlagSet, cfg *config.Config) {
+		fs.StringVar(&cfg.GenesisFile, "genesis", cfg.GenesisFile, "genesis file, JSON or YAML (env GENESIS_FILE)")
+	})
+	if err != nil {
+		return err
+	}
+	if cfg.GenesisFile == "" {
+		return fmt.Errorf("seed needs a genesis file (--genesis or GENESIS_FILE)")
+	}
+
+	g, err := loadGenesis(cfg)
+	if err != nil {
+		return err
+	}
+	store, closeStore, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	return applyGenesis(cliContext(), store, g)
+}
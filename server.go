@@ -2,66 +2,484 @@ package main
 
 import (
 	"btp-transfer/config"
+	"btp-transfer/eth"
+	"btp-transfer/export"
+	"btp-transfer/genesis"
 	"btp-transfer/graph"
+	"btp-transfer/health"
+	"btp-transfer/ledger"
+	"btp-transfer/loaders"
+	"btp-transfer/logging"
+	"btp-transfer/metrics"
+	"btp-transfer/migrations"
+	"btp-transfer/persisted"
+	"btp-transfer/rest"
+	"btp-transfer/service"
+	"btp-transfer/tracing"
+	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/99designs/gqlgen/graphql/playground"
 	_ "github.com/lib/pq"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 const defaultPort = "8080"
 
-func main() {
-	// Open connection and check whether configuration is correct
-	cfg, err := config.Load()
+// runServe starts the GraphQL server.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	cfg, err := parseConfig(fs, args, func(fs *flag.FlagSet, cfg *config.Config) {
+		fs.StringVar(&cfg.Port, "port", cfg.Port, "HTTP port (env PORT)")
+		fs.StringVar(&cfg.TxMode, "tx-mode", cfg.TxMode, "transaction mode: locking, serializable or single (env TX_MODE)")
+		fs.BoolVar(&cfg.MigrateOnStart, "migrate-on-start", cfg.MigrateOnStart, "apply pending migrations before serving (env MIGRATE_ON_START)")
+		fs.BoolVar(&cfg.BatchEnabled, "batch", cfg.BatchEnabled, "apply transfers in batches (env BATCH_ENABLED)")
+		fs.StringVar(&cfg.GenesisFile, "genesis", cfg.GenesisFile, "genesis file applied at first start (env GENESIS_FILE)")
+	})
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		return err
 	}
-	log.Printf("Configuration loaded. Port: %s", cfg.Port)
+	logger, err := logging.New(os.Stderr, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		return err
+	}
+	// Also routes the standard log package through the structured handler
+	slog.SetDefault(logger)
+
+	// Validate the genesis before touching the database
+	g, err := loadGenesis(cfg)
+	if err != nil {
+		return err
+	}
+	queries := graph.PersistedQueries{
+		Cache:         lru.New[string](cfg.APQCacheSize),
+		AllowListOnly: cfg.PersistedQueriesOnly,
+	}
+	if cfg.PersistedQueriesFile != "" {
+		if queries.Registered, err = persisted.LoadManifest(cfg.PersistedQueriesFile); err != nil {
+			return err
+		}
+	}
+	slog.Info("configuration loaded", "port", cfg.Port, "store", cfg.Store)
+	slog.Info("effective configuration", "config", cfg.Redacted())
+
+	// SIGINT/SIGTERM start the shutdown; a second signal kills the process right away
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	stopTracing, err := setupTracing(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	// Flushes the spans of the last requests
+	defer stopTracing()
+
+	store, closeStore, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	// Runs last, when nothing uses the pool anymore
+	defer closeStore()
+
+	metric := metrics.New()
+	// Not http.DefaultServeMux: packages register debug handlers there (expvar's /debug/vars publishes os.Args)
+	mux := http.NewServeMux()
+	resolver := &graph.Resolver{}
+	// Wallet reads of a request are batched straight from the store, past the transfer batcher
+	if b, ok := store.(ledger.BalanceReader); ok {
+		resolver.Balances = b
+	}
+	bg := newWorkers()
+	// Set when the Ethereum JSON-RPC facade is enabled
+	var rpc *rpcLedger
+	var rpcToken eth.Token
+	checker := health.NewChecker(cfg.HealthCheckTimeout)
+	checker.Add("workers", workersCheck(bg))
+
+	if pg, ok := store.(*ledger.PostgresStore); ok {
+		pg.Observer = metric
+		metric.WatchDB("ledger", pg.DB)
+		checker.Add("database", pg.DB.PingContext)
+		migrator, err := migrations.New(pg.DB)
+		if err != nil {
+			return err
+		}
+		checker.Add("migrations", migrationsCheck(migrator))
+
+		if cfg.MigrateOnStart {
+			if err := migrateUp(pg.DB); err != nil {
+				return err
+			}
+		}
+		slog.Info("transaction mode", "mode", pg.Mode, "max_attempts", pg.Retry.MaxAttempts)
+
+		if err := applyGenesis(ctx, pg, g); err != nil {
+			return err
+		}
+		if err := auditConfiguration(ctx, pg, cfg); err != nil {
+			return err
+		}
+		resolver.Audit = pg
+		resolver.History = pg
+		resolver.Directory = pg
+		resolver.TrustPrincipal = cfg.TrustPrincipalHeader
+		resolver.Operators = cfg.OperatorPrincipals
+		operators := &service.Service{TrustPrincipal: cfg.TrustPrincipalHeader, Operators: cfg.OperatorPrincipals}
+		mux.Handle("/export", operatorsOnly(export.Handler(pg.DB, cfg.HTTPWriteTimeout), operators))
+		if cfg.EthChainID > 0 {
+			rpcToken = eth.Token{
+				Address:  cfg.EthTokenAddress,
+				Name:     g.Token.Name,
+				Symbol:   g.Token.Symbol,
+				Decimals: g.Token.Decimals,
+				ChainID:  int64(cfg.EthChainID),
+			}
+			// Registered once the store is wrapped
+			rpc = &rpcLedger{PostgresStore: pg}
+			slog.Info("ethereum JSON-RPC enabled", "chain_id", cfg.EthChainID, "token", cfg.EthTokenAddress)
+		}
+
+		// One verification at a time, verifyLedger reuses the report of the periodic job
+		verifier := newVerifier(func(ctx context.Context) (*ledger.Report, error) {
+			return pg.Verify(ctx, g.Supply())
+		}, max(cfg.VerifyInterval, minReportAge))
+		resolver.Verify = verifier.Verify
+		if cfg.VerifyInterval > 0 {
+			bg.Go("verify", func(ctx context.Context) {
+				verifyPeriodically(ctx, cfg.VerifyInterval, verifier.Run, metric)
+			})
+			slog.Info("periodic ledger verification enabled", "interval", cfg.VerifyInterval.String())
+		}
+
+		if cfg.APQCache == config.APQCachePostgres {
+			cache := persisted.NewPostgresCache(pg.DB, cfg.APQCacheSize)
+			queries.Cache = cache
+			if cfg.APQCacheTTL > 0 {
+				bg.Go("persisted-queries", func(ctx context.Context) {
+					cache.PrunePeriodically(ctx, cfg.APQCacheTTL)
+				})
+			}
+		}
+
+		// Split hot wallets into slots (or merge back the ones no longer configured)
+		if err := pg.ApplySharding(ctx); err != nil {
+			return fmt.Errorf("failed to apply hot wallet sharding: %w", err)
+		}
+
+		// Group commit: transfers are queued and applied in batches
+		if cfg.BatchEnabled {
+			batcher := ledger.NewBatcher(pg, ledger.BatchOptions{
+				MaxSize: cfg.BatchMaxSize,
+				MaxWait: cfg.BatchMaxWait,
+			})
+			// Queued transfers are applied before the pool is closed
+			bg.OnStop("batcher", batcher.Close)
+			slog.Info("group commit enabled", "max_size", cfg.BatchMaxSize, "max_wait", cfg.BatchMaxWait.String())
+			store = batcher
+		}
+	}
+
+	// Canceled when the shutdown starts, closes websocket subscriptions
+	closing, closeSubscriptions := context.WithCancel(context.Background())
+	defer closeSubscriptions()
+
+	extensions := []graphql.HandlerExtension{metric.GraphQL()}
+	if cfg.TracingExporter != config.TracingNone {
+		extensions = append(extensions, tracing.GraphQL())
+	}
+
+	// Send store to Transfer function
+	wrapped := metric.Store(logging.NewStore(store, logger))
+	resolver.Store = wrapped
+	if rpc != nil {
+		rpc.signer = wrapped
+		mux.Handle("/rpc", metric.InFlight(eth.NewServer(rpc, rpcToken, cfg.MaxRequestBytes)))
+	}
+	srv := graph.NewHandler(resolver, graph.Options{
+		Limits: graph.Limits{
+			MaxDepth:      cfg.MaxQueryDepth,
+			MaxComplexity: cfg.MaxQueryComplexity,
+			MaxBodyBytes:  cfg.MaxRequestBytes,
+		},
+		Shutdown:         closing,
+		Extensions:       extensions,
+		PersistedQueries: queries,
+		GetMaxAge:        cfg.GraphQLGetMaxAge,
+		PrincipalHeader:  cfg.AuditPrincipalHeader,
+	})
+	if queries.AllowListOnly {
+		slog.Info("persisted query allow-list enabled", "operations", len(queries.Registered))
+	}
+
+	if cfg.PlaygroundEnabled {
+		mux.Handle("/", playground.Handler("GraphQL playground", "/query"))
+	}
+	// Each request batches its wallet and transfer lookups
+	mux.Handle("/query", metric.InFlight(loaders.Middleware(resolver.Source(), srv)))
+	// Same service, store and error codes as the GraphQL API
+	api := &service.Service{Store: resolver.Store, History: resolver.History, Directory: resolver.Directory, TrustPrincipal: resolver.TrustPrincipal, Operators: resolver.Operators}
+	mux.Handle("/v1/", metric.InFlight(rest.Handler(api, cfg.MaxRequestBytes)))
+	mux.Handle("/healthz", checker.LiveHandler())
+	mux.Handle("/readyz", checker.ReadyHandler())
+	mux.Handle("/metrics", metric.Handler())
+
+	var handler http.Handler = mux
+	if cfg.TracingExporter != config.TracingNone {
+		handler = tracing.Handler(handler, "/healthz", "/readyz", "/metrics")
+	}
+	handler = withActor(handler, cfg)
+	// Outermost, so every log line of a request carries its ID
+	handler = logging.RequestID(handler)
+
+	server := &http.Server{
+		Addr:        ":" + cfg.Port,
+		Handler:     handler,
+		ReadTimeout: cfg.HTTPReadTimeout,
+		// Websocket subscriptions are not affected, the upgrade clears the deadlines
+		WriteTimeout: cfg.HTTPWriteTimeout,
+		IdleTimeout:  cfg.HTTPIdleTimeout,
+	}
+	server.RegisterOnShutdown(closeSubscriptions)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	if cfg.PlaygroundEnabled {
+		slog.Info("GraphQL playground available", "url", "http://localhost:"+cfg.Port+"/")
+	} else {
+		slog.Info("GraphQL API listening", "url", "http://localhost:"+cfg.Port+"/query")
+	}
+
+	select {
+	case err := <-serveErr:
+		// e.g. the port is taken; still stop the workers before closing the pool
+		_ = bg.Stop(context.Background())
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	// Report unready first and keep serving for a moment, so load balancers stop sending new traffic
+	checker.SetDraining()
+	if cfg.ShutdownDrainDelay > 0 {
+		slog.Info("draining: /readyz reports unready", "shutdown_in", cfg.ShutdownDrainDelay.String())
+		time.Sleep(cfg.ShutdownDrainDelay)
+	}
+	return shutdown(server, bg, cfg.ShutdownTimeout)
+}
+
+// rpcLedger serves the reads of the JSON-RPC facade from Postgres and applies its transfers through
+// signer, so they are logged and counted like the transfers of the API.
+type rpcLedger struct {
+	*ledger.PostgresStore
+	signer ledger.SignedTransferer
+}
+
+func (l *rpcLedger) TransferSigned(ctx context.Context, t ledger.SignedTransfer) (int64, error) {
+	return l.signer.TransferSigned(ctx, t)
+}
+
+// setupTracing installs the tracer provider selected by cfg. The returned func flushes pending spans.
+func setupTracing(ctx context.Context, cfg *config.Config) (func(), error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.TracingExporter {
+	case config.TracingStdout:
+		exporter, err = tracing.StdoutExporter()
+	case config.TracingOTLP:
+		exporter, err = tracing.OTLPExporter(ctx, cfg.TracingEndpoint)
+	default:
+		return func() {}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	shutdown, err := tracing.Setup(ctx, exporter)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("tracing enabled", "exporter", cfg.TracingExporter)
+	return func() {
+		// ctx is canceled by now
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			slog.Error("failed to flush spans", "error", err)
+		}
+	}, nil
+}
 
+// migrationsCheck fails while the schema is not at the version this binary expects.
+func migrationsCheck(migrator *migrations.Migrator) health.Check {
+	return func(ctx context.Context) error {
+		current, err := migrator.Current(ctx)
+		if err != nil {
+			return err
+		}
+		if current != migrator.Latest() {
+			return fmt.Errorf("schema is at version %d, expected %d", current, migrator.Latest())
+		}
+		return nil
+	}
+}
+
+// workersCheck fails when a background worker has stopped.
+func workersCheck(bg *workers) health.Check {
+	return func(ctx context.Context) error {
+		var stopped []string
+		for name, running := range bg.Running() {
+			if !running {
+				stopped = append(stopped, name)
+			}
+		}
+		if len(stopped) > 0 {
+			slices.Sort(stopped)
+			return fmt.Errorf("not running: %s", strings.Join(stopped, ", "))
+		}
+		return nil
+	}
+}
+
+// shutdown stops accepting connections and waits, up to timeout in total, for in-flight requests
+// and then for background workers. Websocket subscriptions are closed as soon as it starts.
+func shutdown(server *http.Server, bg *workers, timeout time.Duration) error {
+	slog.Info("shutting down, waiting for in-flight requests", "timeout", timeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	if err := server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to drain HTTP connections: %w", err))
+	}
+	if err := bg.Stop(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	slog.Info("shutdown complete")
+	return nil
+}
+
+// openStore opens the ledger store selected by cfg. The returned func releases it.
+func openStore(cfg *config.Config) (ledger.Store, func(), error) {
+	if cfg.Store == config.StoreMemory {
+		// Demo mode: no database, data is lost on restart
+		g, err := loadGenesis(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		mem := ledger.NewMemoryStore()
+		if err := genesis.Seed(context.Background(), mem, g); err != nil {
+			return nil, nil, err
+		}
+		slog.Warn("running with in-memory store (demo mode), data will NOT be persisted")
+		return mem, func() {}, nil
+	}
+
+	db, err := connectDatabase(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pg := ledger.NewPostgresStore(db)
+	pg.Mode = cfg.TxMode
+	pg.Retry = ledger.RetryPolicy{
+		MaxAttempts: cfg.TxMaxAttempts,
+		BaseDelay:   cfg.TxRetryBaseDelay,
+		MaxDelay:    cfg.TxRetryMaxDelay,
+	}
+	pg.HotWallets = cfg.HotWallets
+	return pg, func() { db.Close() }, nil
+}
+
+// migrateUp applies pending schema migrations.
+func migrateUp(db *sql.DB) error {
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up(context.Background())
+	for _, m := range applied {
+		slog.Info("applied migration", "version", m.Version, "name", m.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	return nil
+}
+
+// connectDatabase opens the database with the configured pool and waits until it accepts connections.
+func connectDatabase(cfg *config.Config) (*sql.DB, error) {
 	// Use cfg.DatabaseURL to connect with database
-	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	// Statements are traced when tracing is enabled
+	db, err := tracing.OpenPostgres(withStatementTimeout(cfg.DatabaseURL, cfg.DBStatementTimeout))
 	if err != nil {
-		log.Fatal("Error opening database:", err)
+		return nil, fmt.Errorf("error opening database: %w", err)
 	}
-	defer db.Close()
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
 
 	// Wait for connection with DB
-	fmt.Println("Trying to connect with database...")
+	slog.Info("trying to connect with database")
 
 	// Check connection (PING) - check if bd is still alive
-	// Try 10 times every 2 seconds
-	for i := 0; i < 10; i++ {
+	// Try DB_CONNECT_ATTEMPTS times every DB_CONNECT_RETRY_DELAY (10 times every 2 seconds by default)
+	attempts := cfg.DBConnectAttempts
+	for i := 0; i < attempts; i++ {
 		err = db.Ping()
 		if err == nil {
 			break
 		}
-		fmt.Printf("... database is not ready (%d/10). Waiting 2s...\n", i+1)
-		time.Sleep(2 * time.Second)
+		if i < attempts-1 {
+			slog.Warn("database is not ready", "attempt", i+1, "attempts", attempts, "retry_in", cfg.DBConnectRetryDelay.String(), "error", err)
+			time.Sleep(cfg.DBConnectRetryDelay)
+		}
 	}
 
-	// If there is no connection after 20s:
+	// If there is no connection after all attempts:
 	if err != nil {
-		log.Fatal("Couldn't connect several times.", err)
+		db.Close()
+		return nil, fmt.Errorf("couldn't connect several times: %w", err)
 	}
 
-	log.Println("Successfully connected to the database!")
-
-	// Send bd to Transfer function
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{
-		Resolvers: &graph.Resolver{
-			DB: db,
-		},
-	}))
+	slog.Info("connected to the database")
+	return db, nil
+}
 
-	http.Handle("/", playground.Handler("GraphQL playground", "/query"))
-	http.Handle("/query", srv)
+// withStatementTimeout adds statement_timeout to a connection string. lib/pq passes unknown
+// parameters to PostgreSQL as run-time settings, so every pooled connection gets it.
+func withStatementTimeout(databaseURL string, timeout time.Duration) string {
+	if timeout <= 0 {
+		return databaseURL
+	}
+	ms := strconv.FormatInt(timeout.Milliseconds(), 10)
 
-	log.Printf("connect to http://localhost:%s/ for GraphQL playground", cfg.Port)
-	log.Fatal(http.ListenAndServe(":"+cfg.Port, nil))
+	if u, err := url.Parse(databaseURL); err == nil && u.Scheme != "" {
+		q := u.Query()
+		q.Set("statement_timeout", ms)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	// key=value form
+	return databaseURL + " statement_timeout=" + ms
 }
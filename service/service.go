@@ -0,0 +1,323 @@
+// Package service is the API logic shared by the GraphQL resolvers and the REST handlers:
+// address normalization, argument checks and paging. Storage and the transfer rules stay in ledger.
+package service
+
+import (
+	"btp-transfer/ledger"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Codes of errors returned by the service itself, next to the ledger ones (see ledger.Code)
+const (
+	CodeBadUserInput               = "BAD_USER_INPUT"
+	CodeTransferHistoryUnavailable = "TRANSFER_HISTORY_UNAVAILABLE"
+	CodeWalletMetadataUnavailable  = "WALLET_METADATA_UNAVAILABLE"
+	CodeForbidden                  = "FORBIDDEN"
+)
+
+// MaxPage caps the number of transfers or wallets returned at once
+const MaxPage = 100
+
+// Limits of wallet metadata, and of the search query
+const (
+	maxLabelLength     = 100
+	maxTags            = 20
+	maxTagLength       = 50
+	maxAttributesBytes = 4096
+	maxQueryLength     = 100
+)
+
+// Error is an error with a stable code, returned to clients as is.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func errorf(code, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Code maps an error of the service or the ledger to its stable code and details.
+// ok is false for errors that are not part of the API contract (e.g. database failures).
+func Code(err error) (code string, details map[string]any, ok bool) {
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) {
+		return serviceErr.Code, nil, true
+	}
+	return ledger.Code(err)
+}
+
+// Service serves the operations of the API.
+type Service struct {
+	Store ledger.Store
+	// History serves transfer histories; nil when the store keeps none (memory)
+	History ledger.History
+	// Directory keeps wallet metadata and searches wallets; nil when the store keeps none (memory)
+	Directory ledger.Directory
+	// TrustPrincipal is set when the principal of a request is authenticated by the gateway. Without it
+	// nobody can prove to own a wallet, so UpdateWallet is refused.
+	TrustPrincipal bool
+	// Operators are the principals allowed to use the operator queries (see RequireOperator). They are
+	// only recognized with TrustPrincipal.
+	Operators []string
+}
+
+// Wallet is the current state of a wallet.
+type Wallet struct {
+	Address string `json:"address"`
+	Balance int64  `json:"balance"`
+	// Metadata is set when it was read together with the wallet, nil otherwise
+	Metadata *ledger.Metadata `json:"-"`
+}
+
+// TransferPage is one page of the transfer history of a wallet, newest first.
+type TransferPage struct {
+	Transfers   []ledger.Entry `json:"transfers"`
+	HasNextPage bool           `json:"has_next_page"`
+	// EndCursor is passed as after to get the next page, empty on an empty page
+	EndCursor string `json:"end_cursor,omitempty"`
+}
+
+// Transfer moves amount between two wallets and returns the sender's new balance.
+func (s *Service) Transfer(ctx context.Context, fromAddress, toAddress string, amount int64) (int64, error) {
+	// Assure that 0xABC and 0xabc are pointing to the same address
+	return s.Store.Transfer(ctx, Normalize(fromAddress), Normalize(toAddress), amount)
+}
+
+// Wallet returns an existing wallet.
+func (s *Service) Wallet(ctx context.Context, address string) (*Wallet, error) {
+	address = Normalize(address)
+	balance, err := s.Store.Balance(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	return &Wallet{Address: address, Balance: balance}, nil
+}
+
+// Transfers returns up to first ledger entries of a wallet, after the cursor of a previous page
+// when after is not empty. The wallet is not required to exist.
+func (s *Service) Transfers(ctx context.Context, address string, first int, after string) (*TransferPage, error) {
+	if s.History == nil {
+		return nil, errorf(CodeTransferHistoryUnavailable, "transfer history is only kept by the postgres store")
+	}
+	if first <= 0 || first > MaxPage {
+		return nil, errorf(CodeBadUserInput, "first must be between 1 and %d, got %d", MaxPage, first)
+	}
+	var before int64
+	if after != "" {
+		id, ok := parseCursor(after)
+		if !ok {
+			return nil, errorf(CodeBadUserInput, "invalid cursor %q", after)
+		}
+		before = id
+	}
+
+	// One more than asked for tells whether there is a next page
+	entries, err := s.History.WalletEntries(ctx, Normalize(address), before, first+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &TransferPage{Transfers: entries, HasNextPage: len(entries) > first}
+	if page.HasNextPage {
+		page.Transfers = entries[:first]
+	}
+	if page.Transfers == nil {
+		page.Transfers = []ledger.Entry{}
+	}
+	if n := len(page.Transfers); n > 0 {
+		page.EndCursor = Cursor(page.Transfers[n-1].ID)
+	}
+	return page, nil
+}
+
+// WalletPage is one page of the wallets found by SearchWallets, by address.
+type WalletPage struct {
+	Wallets     []Wallet
+	HasNextPage bool
+	// EndCursor is passed as after to get the next page, empty on an empty page
+	EndCursor string
+}
+
+// RequireOperator fails with FORBIDDEN unless the principal of the request is one of the operators,
+// authenticated by the gateway.
+func (s *Service) RequireOperator(ctx context.Context) error {
+	if !s.TrustPrincipal {
+		return errorf(CodeForbidden, "operator queries can only be used behind a gateway that authenticates callers (TRUST_PRINCIPAL_HEADER)")
+	}
+	if principal := ledger.ActorFrom(ctx).Principal; !slices.Contains(s.Operators, principal) {
+		return errorf(CodeForbidden, "%s is not an operator", principal)
+	}
+	return nil
+}
+
+// UpdateWallet changes the metadata of an existing wallet. Only its owner may: the gateway must
+// report the wallet address as the principal of the request (see ledger.Actor and TrustPrincipal).
+func (s *Service) UpdateWallet(ctx context.Context, address string, update ledger.MetadataUpdate) (*Wallet, error) {
+	if s.Directory == nil {
+		return nil, errorf(CodeWalletMetadataUnavailable, "wallet metadata is only kept by the postgres store")
+	}
+	if !s.TrustPrincipal {
+		return nil, errorf(CodeForbidden, "wallet metadata can only be changed behind a gateway that authenticates callers (TRUST_PRINCIPAL_HEADER)")
+	}
+	address = Normalize(address)
+	if Normalize(ledger.ActorFrom(ctx).Principal) != address {
+		return nil, errorf(CodeForbidden, "only the owner of %s can change its metadata", address)
+	}
+	if update.Label != nil {
+		label := strings.TrimSpace(*update.Label)
+		if utf8.RuneCountInString(label) > maxLabelLength {
+			return nil, errorf(CodeBadUserInput, "label must be at most %d characters", maxLabelLength)
+		}
+		update.Label = &label
+	}
+	if update.Tags != nil {
+		tags, err := normalizeTags(update.Tags)
+		if err != nil {
+			return nil, err
+		}
+		update.Tags = tags
+	}
+	if update.Attributes != nil {
+		encoded, err := json.Marshal(update.Attributes)
+		if err != nil {
+			return nil, errorf(CodeBadUserInput, "attributes must be a JSON object")
+		}
+		if len(encoded) > maxAttributesBytes {
+			return nil, errorf(CodeBadUserInput, "attributes must be at most %d bytes of JSON, got %d", maxAttributesBytes, len(encoded))
+		}
+	}
+
+	metadata, err := s.Directory.UpdateMetadata(ctx, address, update)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := s.Store.Balance(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	return &Wallet{Address: address, Balance: balance, Metadata: &metadata}, nil
+}
+
+// SearchWallets returns up to first wallets matching filter, by address, after the cursor of
+// a previous page when after is not empty.
+func (s *Service) SearchWallets(ctx context.Context, filter ledger.WalletFilter, first int, after string) (*WalletPage, error) {
+	if s.Directory == nil {
+		return nil, errorf(CodeWalletMetadataUnavailable, "wallet search needs the postgres store")
+	}
+	if first <= 0 || first > MaxPage {
+		return nil, errorf(CodeBadUserInput, "first must be between 1 and %d, got %d", MaxPage, first)
+	}
+	filter.Query = strings.TrimSpace(filter.Query)
+	if utf8.RuneCountInString(filter.Query) > maxQueryLength {
+		return nil, errorf(CodeBadUserInput, "query must be at most %d characters", maxQueryLength)
+	}
+	tags, err := normalizeTags(filter.Tags)
+	if err != nil {
+		return nil, err
+	}
+	filter.Tags = tags
+	if filter.MinBalance != nil && filter.MaxBalance != nil && *filter.MinBalance > *filter.MaxBalance {
+		return nil, errorf(CodeBadUserInput, "minBalance %d is above maxBalance %d", *filter.MinBalance, *filter.MaxBalance)
+	}
+	var afterAddress string
+	if after != "" {
+		address, ok := parseWalletCursor(after)
+		if !ok {
+			return nil, errorf(CodeBadUserInput, "invalid cursor %q", after)
+		}
+		afterAddress = address
+	}
+
+	// One more than asked for tells whether there is a next page
+	matches, err := s.Directory.SearchWallets(ctx, filter, afterAddress, first+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &WalletPage{HasNextPage: len(matches) > first}
+	if page.HasNextPage {
+		matches = matches[:first]
+	}
+	page.Wallets = make([]Wallet, len(matches))
+	for i := range matches {
+		page.Wallets[i] = Wallet{Address: matches[i].Address, Balance: matches[i].Balance, Metadata: &matches[i].Metadata}
+	}
+	if n := len(page.Wallets); n > 0 {
+		page.EndCursor = WalletCursor(page.Wallets[n-1].Address)
+	}
+	return page, nil
+}
+
+// normalizeTags returns tags trimmed, lowercase and without duplicates, so they match whatever their case.
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) > maxTags {
+		return nil, errorf(CodeBadUserInput, "at most %d tags are allowed, got %d", maxTags, len(tags))
+	}
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, errorf(CodeBadUserInput, "tags must be 1 to %d characters, got %q", maxTagLength, tag)
+		}
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
+}
+
+// Normalize returns the form addresses are stored in: 0xABC and 0xabc are the same wallet.
+func Normalize(address string) string {
+	return strings.ToLower(address)
+}
+
+// cursorPrefix keeps cursors opaque to clients, they are not meant to be built by hand
+const cursorPrefix = "transfer:"
+
+// Cursor returns the cursor of the ledger entry with the given ID.
+func Cursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatInt(id, 10)))
+}
+
+// walletCursorPrefix tells wallet cursors from transfer ones
+const walletCursorPrefix = "wallet:"
+
+// WalletCursor returns the cursor of the wallet with the given address, in a list of wallets.
+func WalletCursor(address string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(walletCursorPrefix + address))
+}
+
+// parseWalletCursor returns the address of a cursor made by WalletCursor.
+func parseWalletCursor(cursor string) (string, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", false
+	}
+	address, ok := strings.CutPrefix(string(raw), walletCursorPrefix)
+	return address, ok && address != ""
+}
+
+// parseCursor returns the entry ID of a cursor made by Cursor.
+func parseCursor(cursor string) (int64, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, false
+	}
+	digits, ok := strings.CutPrefix(string(raw), cursorPrefix)
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(digits, 10, 64)
+	return id, err == nil && id > 0
+}
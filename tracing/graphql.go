@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GraphQL returns a gqlgen extension tracing operations and resolver fields.
+// The operation span starts when the request was read and has child spans for parsing and
+// validation, so the time spent before the resolvers run is visible as well.
+func GraphQL() graphql.HandlerExtension {
+	return graphqlTracer{tracer: otel.Tracer(instrumentationName)}
+}
+
+type graphqlTracer struct {
+	tracer trace.Tracer
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
+} = graphqlTracer{}
+
+func (graphqlTracer) ExtensionName() string {
+	return "Tracing"
+}
+
+func (graphqlTracer) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptResponse wraps the operation in a span. Subscriptions are not traced,
+// the interceptor runs once per event for them.
+func (t graphqlTracer) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	opCtx := graphql.GetOperationContext(ctx)
+	if opCtx.Operation == nil || opCtx.Operation.Operation == ast.Subscription {
+		return next(ctx)
+	}
+
+	kind := string(opCtx.Operation.Operation)
+	name := kind
+	attrs := []attribute.KeyValue{semconv.GraphQLOperationTypeKey.String(kind)}
+	if opCtx.Operation.Name != "" {
+		name += " " + opCtx.Operation.Name
+		attrs = append(attrs, semconv.GraphQLOperationName(opCtx.Operation.Name))
+	}
+	ctx, span := t.tracer.Start(ctx, name,
+		trace.WithTimestamp(opCtx.Stats.OperationStart),
+		trace.WithAttributes(attrs...),
+	)
+	defer span.End()
+
+	// Parsing and validation are over by now, they are recorded with their own timestamps
+	t.phase(ctx, "graphql.parse", opCtx.Stats.Parsing)
+	t.phase(ctx, "graphql.validate", opCtx.Stats.Validation)
+
+	resp := next(ctx)
+	if resp != nil && len(resp.Errors) > 0 {
+		span.SetStatus(codes.Error, resp.Errors.Error())
+	}
+	return resp
+}
+
+// phase records a finished phase of the operation as a span.
+func (t graphqlTracer) phase(ctx context.Context, name string, timing graphql.TraceTiming) {
+	if timing.Start.IsZero() {
+		return
+	}
+	_, span := t.tracer.Start(ctx, name, trace.WithTimestamp(timing.Start))
+	span.End(trace.WithTimestamp(timing.End))
+}
+
+// InterceptField traces fields with a resolver; plain struct fields would only add noise.
+func (t graphqlTracer) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+	ctx, span := t.tracer.Start(ctx, fc.Object+"."+fc.Field.Name,
+		trace.WithAttributes(attribute.String("graphql.field.path", fc.Path().String())),
+	)
+	defer span.End()
+
+	res, err := next(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return res, err
+}
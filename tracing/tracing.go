@@ -0,0 +1,108 @@
+// Package tracing sets up OpenTelemetry tracing of requests from HTTP down to SQL.
+//
+// A request gets a span from Handler, its GraphQL operation and resolver fields get spans from the
+// GraphQL extension, and every SQL statement run on behalf of it gets one from a database opened
+// with OpenPostgres. Incoming W3C traceparent/tracestate headers continue the caller's trace.
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is reported unless OTEL_SERVICE_NAME is set.
+const ServiceName = "btp-transfer"
+
+// instrumentationName names the tracer of this package
+const instrumentationName = "btp-transfer/tracing"
+
+// Setup installs a global tracer provider sending spans to exporter, and the W3C trace context
+// and baggage propagators. The returned func flushes the pending spans and stops the provider.
+func Setup(ctx context.Context, exporter sdktrace.SpanExporter) (func(context.Context) error, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(ServiceName)),
+		// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults above
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return provider.Shutdown, nil
+}
+
+// StdoutExporter prints spans as indented JSON, for local use.
+func StdoutExporter() (sdktrace.SpanExporter, error) {
+	return stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+}
+
+// OTLPExporter sends spans to an OTLP/HTTP collector at endpoint, e.g. http://localhost:4318.
+// Headers (e.g. for authentication) can be set with OTEL_EXPORTER_OTLP_HEADERS.
+func OTLPExporter(ctx context.Context, endpoint string) (sdktrace.SpanExporter, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// Handler starts a span for every request served by next, named after the method and path.
+// Probes and metric scrapes under skip are not traced.
+func Handler(next http.Handler, skip ...string) http.Handler {
+	skipped := make(map[string]bool, len(skip))
+	for _, path := range skip {
+		skipped[path] = true
+	}
+	return otelhttp.NewHandler(next, "http",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return !skipped[r.URL.Path]
+		}),
+	)
+}
+
+// OpenPostgres opens a PostgreSQL database (lib/pq, registered as "postgres") that traces
+// its statements, transaction begins and commits. Statements run outside of a traced request,
+// e.g. pool pings, don't start traces of their own.
+func OpenPostgres(dsn string) (*sql.DB, error) {
+	return otelsql.Open("postgres", dsn,
+		otelsql.WithAttributes(semconv.DBSystemNamePostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitConnectorConnect: true,
+			OmitRows:             true,
+			SpanFilter:           hasParentSpan,
+		}),
+	)
+}
+
+func hasParentSpan(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+	return trace.SpanContextFromContext(ctx).IsValid()
+}
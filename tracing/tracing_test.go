@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"btp-transfer/graph"
+	"btp-transfer/ledger"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// 1. Request to resolver
+// Goal: The incoming traceparent is continued by the HTTP, operation, phase and resolver spans.
+func TestTrace_HTTPToResolver(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	const (
+		alice   = "0x0000000000000000000000000000000000000001"
+		bob     = "0x0000000000000000000000000000000000000002"
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	)
+	mem := ledger.NewMemoryStore()
+	if err := mem.CreateWallet(context.Background(), alice, 10); err != nil {
+		t.Fatalf("CreateWallet failed: %v", err)
+	}
+	h := Handler(graph.NewHandler(&graph.Resolver{Store: mem}, graph.Options{
+		Limits:     graph.Limits{MaxDepth: 10, MaxComplexity: 100, MaxBodyBytes: 1 << 20},
+		Extensions: []graphql.HandlerExtension{GraphQL()},
+	}), "/healthz")
+
+	body := fmt.Sprintf(`{"query": "mutation Pay { transfer(from_address: \"%s\", to_address: \"%s\", amount: 1) }"}`, alice, bob)
+	req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+		if got := span.SpanContext().TraceID().String(); got != traceID {
+			t.Errorf("Span %s is in trace %s, expected %s", span.Name(), got, traceID)
+		}
+	}
+	for _, name := range []string{"POST /query", "mutation Pay", "graphql.parse", "graphql.validate", "Mutation.transfer"} {
+		if _, ok := spans[name]; !ok {
+			t.Errorf("Expected span %q, got %v", name, spanNames(recorder.Ended()))
+		}
+	}
+	if _, ok := spans["GET /healthz"]; ok {
+		t.Error("Expected probes not to be traced")
+	}
+
+	// Nesting: resolver in operation in HTTP request
+	op, field := spans["mutation Pay"], spans["Mutation.transfer"]
+	if op != nil && field != nil && field.Parent().SpanID() != op.SpanContext().SpanID() {
+		t.Error("Expected resolver span to be a child of the operation span")
+	}
+	if root := spans["POST /query"]; root != nil && op != nil && op.Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Error("Expected operation span to be a child of the HTTP span")
+	}
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+	return names
+}
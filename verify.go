@@ -0,0 +1,176 @@
+package main
+
+import (
+	"btp-transfer/config"
+	"btp-transfer/genesis"
+	"btp-transfer/ledger"
+	"btp-transfer/metrics"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// runVerify checks the ledger invariants and writes a JSON report (see ledger.Report).
+// It fails when any discrepancy is found, so it can be used in scripts and cron jobs.
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	output := fs.String("output", "", "file the report is written to instead of stdout")
+	cfg, err := parseConfig(fs, args, func(fs *flag.FlagSet, cfg *config.Config) {
+		fs.StringVar(&cfg.GenesisFile, "genesis", cfg.GenesisFile, "genesis file, checked against the one the database was started from (env GENESIS_FILE)")
+	})
+	if err != nil {
+		return err
+	}
+
+	store, closeStore, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	pg, ok := store.(*ledger.PostgresStore)
+	if !ok {
+		return fmt.Errorf("verify needs the postgres store")
+	}
+	ctx := context.Background()
+	supply, err := expectedSupply(ctx, pg, cfg)
+	if err != nil {
+		return err
+	}
+	report, err := pg.Verify(ctx, supply)
+	if err != nil {
+		return err
+	}
+
+	if err := writeReport(report, *output); err != nil {
+		return err
+	}
+	if !report.OK {
+		return fmt.Errorf("ledger verification found %d discrepancies", len(report.Discrepancies))
+	}
+	return nil
+}
+
+// expectedSupply returns the supply of the genesis recorded in the database, so a database started
+// from a custom genesis verifies without passing the file again. A given genesis file must be that
+// genesis, as at server start; without a recorded genesis the configured (or built-in) one is assumed.
+func expectedSupply(ctx context.Context, pg *ledger.PostgresStore, cfg *config.Config) (int64, error) {
+	recorded, err := genesis.Recorded(ctx, pg.DB)
+	if err != nil {
+		return 0, err
+	}
+	if recorded != nil && cfg.GenesisFile == "" {
+		return recorded.Supply, nil
+	}
+	g, err := loadGenesis(cfg)
+	if err != nil {
+		return 0, err
+	}
+	if recorded != nil && recorded.Hash != g.Hash() {
+		return 0, &genesis.MismatchError{Recorded: recorded.Hash, Given: g.Hash()}
+	}
+	return g.Supply(), nil
+}
+
+// writeReport writes report as indented JSON to path, or to stdout when path is empty.
+func writeReport(report *ledger.Report, path string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// verifyPeriodically runs verify every interval until ctx is canceled, logging and exporting each report.
+func verifyPeriodically(ctx context.Context, interval time.Duration, verify func(ctx context.Context) (*ledger.Report, error), metric *metrics.Metrics) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		report, err := verify(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("ledger verification failed", "error", err)
+			}
+			continue
+		}
+		metric.LedgerVerified(report)
+		logReport(report, time.Since(start))
+	}
+}
+
+// minReportAge is how long a report is reused by verifyLedger at least, whatever VERIFY_INTERVAL is.
+const minReportAge = time.Minute
+
+// verifier runs one verification of the ledger at a time, as each one scans whole tables. Queries reuse
+// the last report (e.g. of the periodic job) while it is younger than maxAge.
+type verifier struct {
+	verify func(ctx context.Context) (*ledger.Report, error)
+	maxAge time.Duration
+	// running holds a token while a verification runs
+	running chan struct{}
+	// last and lastAt are guarded by running
+	last   *ledger.Report
+	lastAt time.Time
+}
+
+func newVerifier(verify func(ctx context.Context) (*ledger.Report, error), maxAge time.Duration) *verifier {
+	return &verifier{verify: verify, maxAge: maxAge, running: make(chan struct{}, 1)}
+}
+
+// Verify returns the last report if it is recent enough, or waits for its turn and verifies the ledger.
+func (v *verifier) Verify(ctx context.Context) (*ledger.Report, error) {
+	return v.do(ctx, true)
+}
+
+// Run verifies the ledger once the verification running, if any, is done.
+func (v *verifier) Run(ctx context.Context) (*ledger.Report, error) {
+	return v.do(ctx, false)
+}
+
+func (v *verifier) do(ctx context.Context, reuse bool) (*ledger.Report, error) {
+	select {
+	case v.running <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-v.running }()
+
+	if reuse && v.last != nil && time.Since(v.lastAt) < v.maxAge {
+		return v.last, nil
+	}
+	report, err := v.verify(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.last, v.lastAt = report, time.Now()
+	return report, nil
+}
+
+// logReport logs the outcome of a verification, one line per discrepancy.
+func logReport(report *ledger.Report, took time.Duration) {
+	if report.OK {
+		slog.Info("ledger verified", "wallets", report.Wallets, "entries", report.Entries, "balances", report.Balances,
+			"duration_ms", float64(took.Microseconds())/1000)
+		return
+	}
+	slog.Error("ledger verification found discrepancies", "discrepancies", len(report.Discrepancies))
+	for _, d := range report.Discrepancies {
+		slog.Error("ledger discrepancy", "check", d.Check, "address", d.Address, "detail", d.Detail)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"btp-transfer/ledger"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 1. Shared verification
+// Goal: Concurrent queries run one verification and share its report; Run always verifies again.
+func TestVerifier(t *testing.T) {
+	var runs atomic.Int32
+	release := make(chan struct{})
+	v := newVerifier(func(ctx context.Context) (*ledger.Report, error) {
+		runs.Add(1)
+		<-release
+		return &ledger.Report{OK: true}, nil
+	}, time.Minute)
+
+	var wg sync.WaitGroup
+	reports := make([]*ledger.Report, 5)
+	for i := range reports {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reports[i], _ = v.Verify(context.Background())
+		}()
+	}
+	close(release)
+	wg.Wait()
+	if runs.Load() != 1 {
+		t.Errorf("Expected 1 verification, got %d", runs.Load())
+	}
+	for _, report := range reports {
+		if report != reports[0] {
+			t.Errorf("Expected the same report for every query, got %p and %p", report, reports[0])
+		}
+	}
+
+	if _, err := v.Run(context.Background()); err != nil || runs.Load() != 2 {
+		t.Errorf("Expected Run to verify again, got %d verifications (%v)", runs.Load(), err)
+	}
+
+	// A caller waiting for its turn gives up with its context
+	v.running <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := v.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline while waiting, got %v", err)
+	}
+}
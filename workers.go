@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// workers runs the background jobs of the server and stops them on shutdown,
+// before the database pool they use is closed.
+type workers struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	running map[string]bool
+	closers []namedCloser
+}
+
+type namedCloser struct {
+	name  string
+	close func() error
+}
+
+func newWorkers() *workers {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workers{ctx: ctx, cancel: cancel, running: map[string]bool{}}
+}
+
+// Go runs a job until the context it gets is canceled by Stop.
+func (w *workers) Go(name string, run func(ctx context.Context)) {
+	w.mu.Lock()
+	w.running[name] = true
+	w.mu.Unlock()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer func() {
+			w.mu.Lock()
+			w.running[name] = false
+			w.mu.Unlock()
+		}()
+		run(w.ctx)
+	}()
+}
+
+// OnStop registers a component that runs on its own (like the Batcher) to be closed by Stop.
+// Closers run in reverse order of registration, after all jobs started with Go have returned.
+func (w *workers) OnStop(name string, close func() error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.running[name] = true
+	w.closers = append(w.closers, namedCloser{name, close})
+}
+
+// Running reports which jobs and components are still running.
+func (w *workers) Running() map[string]bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	running := make(map[string]bool, len(w.running))
+	for name, ok := range w.running {
+		running[name] = ok
+	}
+	return running
+}
+
+// Stop cancels the jobs and waits for them, then closes the registered components.
+// It gives up waiting when ctx is done; jobs still running are abandoned.
+func (w *workers) Stop(ctx context.Context) error {
+	w.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("background jobs did not stop in time: %w", ctx.Err())
+	}
+
+	w.mu.Lock()
+	closers := w.closers
+	w.mu.Unlock()
+
+	var errs []error
+	for i := len(closers) - 1; i >= 0; i-- {
+		c := closers[i]
+		closed := make(chan error, 1)
+		go func() { closed <- c.close() }()
+		select {
+		case err := <-closed:
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to stop %s: %w", c.name, err))
+			}
+			slog.Info("stopped", "component", c.name)
+		case <-ctx.Done():
+			return errors.Join(append(errs, fmt.Errorf("%s did not stop in time: %w", c.name, ctx.Err()))...)
+		}
+		w.mu.Lock()
+		w.running[c.name] = false
+		w.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
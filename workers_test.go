@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// 1. Stopping order
+// Goal: Jobs are canceled and awaited first, then components are closed in reverse order.
+func TestWorkers_Stop(t *testing.T) {
+	bg := newWorkers()
+	var order []string
+
+	jobDone := make(chan struct{})
+	bg.Go("job", func(ctx context.Context) {
+		<-ctx.Done()
+		order = append(order, "job")
+		close(jobDone)
+	})
+	bg.OnStop("first", func() error { order = append(order, "first"); return nil })
+	bg.OnStop("second", func() error { order = append(order, "second"); return nil })
+
+	if running := bg.Running(); !running["job"] || !running["first"] {
+		t.Errorf("Expected everything running, got %v", running)
+	}
+
+	if err := bg.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	<-jobDone
+	if got := len(order); got != 3 || order[0] != "job" || order[1] != "second" || order[2] != "first" {
+		t.Errorf("Unexpected stopping order: %v", order)
+	}
+	for name, running := range bg.Running() {
+		if running {
+			t.Errorf("%s still reported as running", name)
+		}
+	}
+}
+
+// 2. Deadline
+// Goal: A job ignoring cancellation doesn't block the shutdown past its deadline.
+func TestWorkers_StopDeadline(t *testing.T) {
+	bg := newWorkers()
+	release := make(chan struct{})
+	defer close(release)
+	bg.Go("stuck", func(ctx context.Context) { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := bg.Stop(ctx); err == nil {
+		t.Error("Expected error for a job that didn't stop")
+	}
+}